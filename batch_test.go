@@ -1,6 +1,10 @@
 package dynamo
 
 import (
+	"context"
+	"errors"
+	"reflect"
+	"strconv"
 	"testing"
 	"time"
 )
@@ -90,3 +94,230 @@ func TestBatchGetWrite(t *testing.T) {
 		t.Error("expected 0 results, got", len(results))
 	}
 }
+
+func TestBatchGetInput(t *testing.T) {
+	table := Table{name: "Test"}
+	keys := make([]Keyed, 0, 150)
+	for i := 0; i < 150; i++ {
+		keys = append(keys, Keys{i, "x"})
+	}
+	bg := table.Batch("UserID", "Time").Get(keys...).Project("Msg", "Count", "'Size'.x")
+	if bg.err != nil {
+		t.Fatal("unexpected error:", bg.err)
+	}
+
+	in := bg.input(0)
+	kas := in.RequestItems["Test"]
+	if len(kas.Keys) != maxGetOps {
+		t.Error("expected", maxGetOps, "keys in first chunk, got", len(kas.Keys))
+	}
+	if kas.ProjectionExpression == nil || *kas.ProjectionExpression == "" {
+		t.Fatal("missing projection expression")
+	}
+	if len(kas.ExpressionAttributeNames) != 2 {
+		t.Error("expected 2 names, got", kas.ExpressionAttributeNames)
+	}
+
+	in = bg.input(maxGetOps)
+	if n := len(in.RequestItems["Test"].Keys); n != 50 {
+		t.Error("expected 50 keys in second chunk, got", n)
+	}
+	if in := bg.input(len(keys)); in != nil {
+		t.Error("expected nil input after all keys, got", in)
+	}
+}
+
+type keyedWidget struct {
+	UserID int
+	Time   *string
+}
+
+func (w keyedWidget) HashKey() interface{}  { return w.UserID }
+func (w keyedWidget) RangeKey() interface{} { return w.Time }
+
+func TestBatchKeyed(t *testing.T) {
+	table := Table{name: "Test"}
+	now := "2020-01-01"
+	keys := []Keyed{
+		keyedWidget{UserID: 1, Time: &now},
+		// typed nil range keys are omitted
+		keyedWidget{UserID: 2},
+	}
+
+	bg := table.Batch("UserID", "Time").Get(keys...)
+	if bg.err != nil {
+		t.Fatal("unexpected error:", bg.err)
+	}
+	got := bg.input(0).RequestItems["Test"].Keys
+	if len(got) != 2 || *got[0]["Time"].S != now || got[1]["Time"] != nil {
+		t.Error("bad keys:", got)
+	}
+
+	bw := table.Batch("UserID", "Time").Write().Delete(keys...)
+	if bw.err != nil {
+		t.Fatal("unexpected error:", bw.err)
+	}
+	del := bw.ops[1].DeleteRequest.Key
+	if *del["UserID"].N != "2" || del["Time"] != nil {
+		t.Error("bad delete key:", del)
+	}
+}
+
+func TestBatchGetConsistent(t *testing.T) {
+	// each batch targets a single table, so consistency is set per batch
+	users := Table{name: "Users"}.Batch("ID").Get(Keys{1}).Consistent(true)
+	logs := Table{name: "Logs"}.Batch("ID").Get(Keys{1})
+
+	if kas := users.input(0).RequestItems["Users"]; kas.ConsistentRead == nil || !*kas.ConsistentRead {
+		t.Error("expected consistent read, got", kas.ConsistentRead)
+	}
+	if kas := logs.input(0).RequestItems["Logs"]; kas.ConsistentRead != nil {
+		t.Error("expected eventually consistent read, got", *kas.ConsistentRead)
+	}
+}
+
+func TestBatchGetAdaptiveChunks(t *testing.T) {
+	type item struct {
+		UserID int
+	}
+
+	client := newMockClient(0)
+	client.maxBatchResults = 30
+	db := NewFromIface(client)
+	db.SetRetryConfig(RetryConfig{BaseDelay: time.Millisecond})
+
+	keys := make([]Keyed, 150)
+	for i := range keys {
+		keys[i] = Keys{i}
+	}
+	var results []item
+	if err := db.Table("Test").Batch("UserID").Get(keys...).All(&results); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if len(results) != len(keys) {
+		t.Error("expected", len(keys), "results, got", len(results))
+	}
+	seen := make(map[int]bool)
+	for _, r := range results {
+		seen[r.UserID] = true
+	}
+	if len(seen) != len(keys) {
+		t.Error("expected", len(keys), "unique results, got", len(seen))
+	}
+	// partial responses halve the request size, complete ones double it,
+	// and unprocessed keys are retried first
+	expect := []int{100, 50, 25, 15, 50, 20}
+	if !reflect.DeepEqual(client.batchSizes, expect) {
+		t.Errorf("bad batch sizes. %v ≠ %v", client.batchSizes, expect)
+	}
+}
+
+func TestBatchWritePutSlice(t *testing.T) {
+	type item struct {
+		UserID int
+	}
+
+	client := newMockClient(0)
+	client.maxBatchResults = 20
+	db := NewFromIface(client)
+	db.SetRetryConfig(RetryConfig{BaseDelay: time.Millisecond})
+
+	items := make([]item, 60)
+	for i := range items {
+		items[i] = item{UserID: i}
+	}
+	wrote, err := db.Table("Test").Batch("UserID").Write().Put(items, item{UserID: 60}).Run()
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+	if wrote != 61 {
+		t.Error("expected 61 writes, got", wrote)
+	}
+	// each chunk of 25 has 5 unprocessed items retried
+	expect := []int{25, 5, 25, 5, 11}
+	if !reflect.DeepEqual(client.batchSizes, expect) {
+		t.Errorf("bad batch sizes. %v ≠ %v", client.batchSizes, expect)
+	}
+}
+
+func TestBatchWriteItemCollectionMetrics(t *testing.T) {
+	type item struct {
+		Idx int
+		N   int
+	}
+
+	client := newMockClient(0)
+	client.maxBatchResults = 20
+	db := NewFromIface(client)
+	db.SetRetryConfig(RetryConfig{BaseDelay: time.Millisecond})
+
+	items := make([]item, 30)
+	for i := range items {
+		items[i] = item{Idx: i % 10, N: i}
+	}
+	var icm ItemCollectionMetrics
+	_, err := db.Table("Test").Batch("Idx", "N").Write().Put(items).ReturnItemCollectionMetrics(&icm).Run()
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+	// 30 puts over 3 requests, but only 10 collections
+	if len(icm.Collections) != 10 {
+		t.Fatalf("expected 10 collections, got %d: %v", len(icm.Collections), icm.Collections)
+	}
+	for i, coll := range icm.Collections {
+		if coll.TableName != "Test" {
+			t.Error("bad table name:", coll.TableName)
+		}
+		if got := *coll.Key["Idx"].N; got != strconv.Itoa(i) {
+			t.Errorf("bad key at %d: %s", i, got)
+		}
+		// collections 0-4 were last written by the retry of unprocessed items, 5-9 by the final request
+		want := [2]float64{3, 4}
+		if i < 5 {
+			want = [2]float64{2, 3}
+		}
+		if coll.SizeEstimateRangeGB != want {
+			t.Errorf("bad size estimate for collection %d. want: %v, got: %v", i, want, coll.SizeEstimateRangeGB)
+		}
+	}
+}
+
+func TestBatchWriteUnprocessed(t *testing.T) {
+	type item struct {
+		UserID int
+	}
+
+	client := newMockClient(0)
+	client.maxBatchResults = 20
+	db := NewFromIface(client)
+	db.SetRetryConfig(RetryConfig{BaseDelay: time.Hour, NoJitter: true})
+
+	items := make([]item, 60)
+	for i := range items {
+		items[i] = item{UserID: i}
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	wrote, err := db.Table("Test").Batch("UserID").Write().Put(items).RunWithContext(ctx)
+	if wrote != 20 {
+		t.Error("expected 20 writes, got", wrote)
+	}
+	var bwe *BatchWriteError
+	if !errors.As(err, &bwe) {
+		t.Fatal("expected *BatchWriteError, got", err)
+	}
+	// the next retry would pass the deadline, so it gives up right away
+	if bwe.Err != nil {
+		t.Error("expected to give up, got", bwe.Err)
+	}
+	// 5 unprocessed from the first chunk, plus the 35 never sent
+	if len(bwe.Unprocessed) != 40 {
+		t.Fatal("expected 40 unprocessed, got", len(bwe.Unprocessed))
+	}
+	for i, req := range bwe.Unprocessed {
+		if got, want := *req.PutRequest.Item["UserID"].N, strconv.Itoa(i+20); got != want {
+			t.Errorf("bad unprocessed item at %d: %s ≠ %s", i, got, want)
+		}
+	}
+}
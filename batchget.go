@@ -2,10 +2,11 @@ package dynamo
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
 	"github.com/aws/aws-sdk-go/service/dynamodb"
-	"github.com/cenkalti/backoff"
 )
 
 // DynamoDB API limit, 100 operations per request
@@ -39,6 +40,9 @@ func (table Table) Batch(hashAndRangeKeyName ...string) Batch {
 }
 
 // BatchGet is a BatchGetItem operation.
+// Keys are requested 100 at a time. When DynamoDB only returns part of a request,
+// usually because the response hit its 16MB size limit, the remaining keys are retried
+// and later requests ask for half as many keys at a time, growing back after each complete response.
 type BatchGet struct {
 	batch      Batch
 	reqs       []*Query
@@ -46,6 +50,9 @@ type BatchGet struct {
 	consistent bool
 	err        error
 	cc         *ConsumedCapacity
+	opts       []request.Option
+
+	subber
 }
 
 // Get creates a new batch get item request with the given keys.
@@ -70,7 +77,7 @@ func (bg *BatchGet) And(keys ...Keyed) *BatchGet {
 func (bg *BatchGet) add(keys []Keyed) {
 	for _, key := range keys {
 		get := bg.batch.table.Get(bg.batch.hashKey, key.HashKey())
-		if rk := key.RangeKey(); bg.batch.rangeKey != "" && rk != nil {
+		if rk := key.RangeKey(); bg.batch.rangeKey != "" && !isNil(rk) {
 			get.Range(bg.batch.rangeKey, Equal, rk)
 			bg.setError(get.err)
 		}
@@ -78,6 +85,25 @@ func (bg *BatchGet) add(keys []Keyed) {
 	}
 }
 
+// Project limits the result attributes to the given paths.
+// Paths are attribute names separated by dots for nested maps, each optionally
+// followed by list indexes, like "Address.Zip" or "Items[0].Name".
+// Names that are reserved words are automatically escaped.
+// Use single quotes to escape complex names like 'User'.'Count'.
+func (bg *BatchGet) Project(paths ...string) *BatchGet {
+	var expr string
+	for i, p := range paths {
+		if i != 0 {
+			expr += ", "
+		}
+		name, err := bg.escape(p)
+		bg.setError(err)
+		expr += name
+	}
+	bg.projection = expr
+	return bg
+}
+
 // Consistent will, if on is true, make this batch use a strongly consistent read.
 // Reads are eventually consistent by default.
 // Strongly consistent reads are more resource-heavy than eventually consistent reads.
@@ -92,9 +118,16 @@ func (bg *BatchGet) ConsumedCapacity(cc *ConsumedCapacity) *BatchGet {
 	return bg
 }
 
+// RequestOptions adds options, such as custom request handlers, to every request made by this batch.
+// They are passed to the AWS SDK's WithContext methods.
+func (bg *BatchGet) RequestOptions(opts ...request.Option) *BatchGet {
+	bg.opts = append(bg.opts, opts...)
+	return bg
+}
+
 // All executes this request and unmarshals all results to out, which must be a pointer to a slice.
 func (bg *BatchGet) All(out interface{}) error {
-	iter := newBGIter(bg, unmarshalAppend, bg.err)
+	iter := newBGIter(bg, bg.batch.table.db.decoder().unmarshalAppend, bg.err)
 	for iter.Next(out) {
 	}
 	return iter.Err()
@@ -102,7 +135,7 @@ func (bg *BatchGet) All(out interface{}) error {
 
 // AllWithContext executes this request and unmarshals all results to out, which must be a pointer to a slice.
 func (bg *BatchGet) AllWithContext(ctx aws.Context, out interface{}) error {
-	iter := newBGIter(bg, unmarshalAppend, bg.err)
+	iter := newBGIter(bg, bg.batch.table.db.decoder().unmarshalAppend, bg.err)
 	for iter.NextWithContext(ctx, out) {
 	}
 	return iter.Err()
@@ -110,7 +143,7 @@ func (bg *BatchGet) AllWithContext(ctx aws.Context, out interface{}) error {
 
 // Iter returns a results iterator for this batch.
 func (bg *BatchGet) Iter() Iter {
-	return newBGIter(bg, unmarshalItem, bg.err)
+	return newBGIter(bg, bg.batch.table.db.decoder().unmarshalItem, bg.err)
 }
 
 func (bg *BatchGet) input(start int) *dynamodb.BatchGetItemInput {
@@ -121,31 +154,26 @@ func (bg *BatchGet) input(start int) *dynamodb.BatchGetItemInput {
 	if end > len(bg.reqs) {
 		end = len(bg.reqs)
 	}
+	keys := make([]map[string]*dynamodb.AttributeValue, 0, end-start)
+	for _, get := range bg.reqs[start:end] {
+		keys = append(keys, get.keys())
+	}
+	return bg.inputKeys(keys)
+}
 
+func (bg *BatchGet) inputKeys(keys []map[string]*dynamodb.AttributeValue) *dynamodb.BatchGetItemInput {
 	in := &dynamodb.BatchGetItemInput{
 		RequestItems: make(map[string]*dynamodb.KeysAndAttributes, 1),
 	}
 
-	if bg.projection != "" {
-		for _, get := range bg.reqs[start:end] {
-			get.Project(get.projection)
-			bg.setError(get.err)
-		}
-	}
-	if bg.cc != nil {
-		in.ReturnConsumedCapacity = aws.String(dynamodb.ReturnConsumedCapacityIndexes)
-	}
+	in.ReturnConsumedCapacity = returnConsumedCapacity(bg.cc, bg.batch.table.db.readLimited())
 
-	var kas *dynamodb.KeysAndAttributes
-	for _, get := range bg.reqs[start:end] {
-		if kas == nil {
-			kas = get.keysAndAttribs()
-			continue
-		}
-		kas.Keys = append(kas.Keys, get.keys())
+	kas := &dynamodb.KeysAndAttributes{
+		Keys: keys,
 	}
 	if bg.projection != "" {
 		kas.ProjectionExpression = &bg.projection
+		kas.ExpressionAttributeNames = bg.nameExpr
 	}
 	if bg.consistent {
 		kas.ConsistentRead = &bg.consistent
@@ -168,8 +196,10 @@ type bgIter struct {
 	err       error
 	idx       int
 	total     int
-	processed int
-	backoff   *backoff.ExponentialBackOff
+	processed int // number of keys in bg.reqs that have been requested
+	pending   []map[string]*dynamodb.AttributeValue
+	chunk     int
+	backoff   *jitterBackOff
 	unmarshal unmarshalFunc
 }
 
@@ -177,7 +207,8 @@ func newBGIter(bg *BatchGet, fn unmarshalFunc, err error) *bgIter {
 	iter := &bgIter{
 		bg:        bg,
 		err:       err,
-		backoff:   backoff.NewExponentialBackOff(),
+		chunk:     maxGetOps,
+		backoff:   bg.batch.table.db.retryCfg.backoff(),
 		unmarshal: fn,
 	}
 	iter.backoff.MaxElapsedTime = 0
@@ -201,78 +232,110 @@ func (itr *bgIter) NextWithContext(ctx aws.Context, out interface{}) bool {
 	tableName := itr.bg.batch.table.Name()
 
 	// can we use results we already have?
-	if itr.output != nil && itr.idx < len(itr.output.Responses[tableName]) {
-		items := itr.output.Responses[tableName]
-		item := items[itr.idx]
-		itr.err = itr.unmarshal(item, out)
-		itr.idx++
-		itr.total++
-		return itr.err == nil
+	if itr.output == nil || itr.idx >= len(itr.output.Responses[tableName]) {
+		if !itr.fetch(ctx, tableName) {
+			return false
+		}
 	}
 
-	// new bg
-	if itr.input == nil {
-		itr.input = itr.bg.input(itr.processed)
+	item := itr.output.Responses[tableName][itr.idx]
+	if itr.err = itr.unmarshal(item, out); itr.err != nil {
+		itr.err = unmarshalError(nil, int64(itr.total), item, itr.err)
 	}
+	itr.idx++
+	itr.total++
+	return itr.err == nil
+}
 
-	if itr.output != nil && itr.idx >= len(itr.output.Responses[tableName]) {
-		var unprocessed int
-		if itr.output.UnprocessedKeys != nil && itr.output.UnprocessedKeys[tableName] != nil {
-			unprocessed = len(itr.output.UnprocessedKeys[tableName].Keys)
-		}
-		itr.processed += len(itr.input.RequestItems[tableName].Keys) - unprocessed
-		// have we exhausted all results?
-		if len(itr.output.UnprocessedKeys) == 0 {
-			// yes, try to get next inner batch of 100 items
-			if itr.input = itr.bg.input(itr.processed); itr.input == nil {
-				// we're done, no more input
-				if itr.err == nil && itr.total == 0 {
-					itr.err = ErrNotFound
-				}
-				return false
+// fetch requests batches until one returns results, or there are no more keys.
+func (itr *bgIter) fetch(ctx aws.Context, tableName string) bool {
+	for {
+		keys, retrying := itr.nextKeys()
+		if keys == nil {
+			// we're done, no more input
+			if itr.total == 0 {
+				itr.err = ErrNotFound
 			}
-		} else {
-			// no, prepare a new request with the remaining keys
-			itr.input.RequestItems = itr.output.UnprocessedKeys
+			return false
+		}
+		if retrying {
 			// we need to sleep here a bit as per the official docs
-			if err := aws.SleepWithContext(ctx, itr.backoff.NextBackOff()); err != nil {
-				// timed out
-				itr.err = err
+			if itr.err = aws.SleepWithContext(ctx, itr.backoff.NextBackOff()); itr.err != nil {
 				return false
 			}
 		}
-		itr.idx = 0
-	}
 
-	itr.err = retry(ctx, func() error {
-		var err error
-		itr.output, err = itr.bg.batch.table.db.client.BatchGetItemWithContext(ctx, itr.input)
-		return err
-	})
-	if itr.err != nil {
-		return false
-	}
-	if itr.bg.cc != nil {
-		for _, cc := range itr.output.ConsumedCapacity {
-			addConsumedCapacity(itr.bg.cc, cc)
+		if itr.err = ctx.Err(); itr.err != nil {
+			return false
 		}
-	}
 
-	items := itr.output.Responses[tableName]
-	if len(items) == 0 {
-		if len(itr.output.UnprocessedKeys) == 0 {
-			if itr.total == 0 {
-				itr.err = ErrNotFound
+		itr.input = itr.bg.inputKeys(keys)
+		itr.err = itr.bg.batch.table.db.retry(ctx, func() error {
+			if err := itr.bg.batch.table.db.reads.wait(ctx); err != nil {
+				return err
 			}
+			var err error
+			start := time.Now()
+			itr.output, err = itr.bg.batch.table.db.client.BatchGetItemWithContext(ctx, itr.input, itr.bg.opts...)
+			itr.bg.batch.table.db.observe(ctx, "BatchGetItem", itr.input, itr.output, err, start)
+			return err
+		})
+		if itr.err != nil {
 			return false
 		}
-		// need to retry to get more keys
-		return itr.NextWithContext(ctx, out)
+		if itr.bg.cc != nil {
+			for _, cc := range itr.output.ConsumedCapacity {
+				addConsumedCapacity(itr.bg.cc, cc)
+			}
+		}
+		itr.bg.batch.table.db.reads.spend(itr.output.ConsumedCapacity...)
+
+		itr.idx = 0
+		items := itr.output.Responses[tableName]
+		if unprocessed := itr.output.UnprocessedKeys[tableName]; unprocessed != nil && len(unprocessed.Keys) > 0 {
+			itr.pending = append(itr.pending, unprocessed.Keys...)
+			// a partial response usually means we hit the response size limit,
+			// so ask for fewer keys at a time from now on
+			if len(items) > 0 && itr.chunk > 1 {
+				itr.chunk /= 2
+			}
+		} else if itr.chunk < maxGetOps {
+			// everything fit, so try asking for more again
+			itr.chunk *= 2
+			if itr.chunk > maxGetOps {
+				itr.chunk = maxGetOps
+			}
+		}
+		if len(items) > 0 {
+			return true
+		}
 	}
-	itr.err = itr.unmarshal(items[itr.idx], out)
-	itr.idx++
-	itr.total++
-	return itr.err == nil
+}
+
+// nextKeys returns the keys to request next, up to the current chunk size.
+// Unprocessed keys from earlier requests are retried first, in which case retrying is true.
+func (itr *bgIter) nextKeys() (keys []map[string]*dynamodb.AttributeValue, retrying bool) {
+	if len(itr.pending) > 0 {
+		n := itr.chunk
+		if n > len(itr.pending) {
+			n = len(itr.pending)
+		}
+		keys, itr.pending = itr.pending[:n], itr.pending[n:]
+		return keys, true
+	}
+
+	if itr.processed >= len(itr.bg.reqs) {
+		return nil, false
+	}
+	end := itr.processed + itr.chunk
+	if end > len(itr.bg.reqs) {
+		end = len(itr.bg.reqs)
+	}
+	for _, get := range itr.bg.reqs[itr.processed:end] {
+		keys = append(keys, get.keys())
+	}
+	itr.processed = end
+	return keys, false
 }
 
 // Err returns the error encountered, if any.
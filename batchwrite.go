@@ -1,9 +1,13 @@
 package dynamo
 
 import (
+	"fmt"
 	"math"
+	"reflect"
+	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
 	"github.com/aws/aws-sdk-go/service/dynamodb"
 	"github.com/cenkalti/backoff"
 )
@@ -17,6 +21,8 @@ type BatchWrite struct {
 	ops   []*dynamodb.WriteRequest
 	err   error
 	cc    *ConsumedCapacity
+	opts  []request.Option
+	icm   *ItemCollectionMetrics
 }
 
 // Write creates a new batch write request, to which
@@ -29,22 +35,45 @@ func (b Batch) Write() *BatchWrite {
 }
 
 // Put adds put operations for items to this batch.
+// An item that is a slice or array adds a put for each of its elements,
+// so a whole slice can be written with Put(items).
+// Items larger than DynamoDB's 400KB limit cause Run to return an *ItemTooLargeError without writing anything.
+// Batch writes can't have conditions, so items with a version field cause an error; use Table.Put for those.
 func (bw *BatchWrite) Put(items ...interface{}) *BatchWrite {
 	for _, item := range items {
-		encoded, err := marshalItem(item)
-		bw.setError(err)
-		bw.ops = append(bw.ops, &dynamodb.WriteRequest{PutRequest: &dynamodb.PutRequest{
-			Item: encoded,
-		}})
+		if rv := reflect.ValueOf(item); rv.Kind() == reflect.Slice || rv.Kind() == reflect.Array {
+			for i := 0; i < rv.Len(); i++ {
+				bw.put(rv.Index(i).Interface())
+			}
+			continue
+		}
+		bw.put(item)
 	}
 	return bw
 }
 
+func (bw *BatchWrite) put(item interface{}) {
+	encoded, err := marshalItem(item)
+	bw.setError(err)
+	if name, _, ok, err := versionField(item); ok {
+		bw.setError(fmt.Errorf("dynamo: batch write: %T has version field %s, which requires a conditional put", item, name))
+	} else {
+		bw.setError(err)
+	}
+	encoded = bw.batch.table.db.emptyStringMode().item(encoded)
+	if err == nil {
+		bw.setError(checkItemSize(encoded))
+	}
+	bw.ops = append(bw.ops, &dynamodb.WriteRequest{PutRequest: &dynamodb.PutRequest{
+		Item: encoded,
+	}})
+}
+
 // Delete adds delete operations for the given keys to this batch.
 func (bw *BatchWrite) Delete(keys ...Keyed) *BatchWrite {
 	for _, key := range keys {
 		del := bw.batch.table.Delete(bw.batch.hashKey, key.HashKey())
-		if rk := key.RangeKey(); bw.batch.rangeKey != "" && rk != nil {
+		if rk := key.RangeKey(); bw.batch.rangeKey != "" && !isNil(rk) {
 			del.Range(bw.batch.rangeKey, rk)
 			bw.setError(del.err)
 		}
@@ -61,16 +90,58 @@ func (bw *BatchWrite) ConsumedCapacity(cc *ConsumedCapacity) *BatchWrite {
 	return bw
 }
 
+// RequestOptions adds options, such as custom request handlers, to every request made by this batch.
+// They are passed to the AWS SDK's WithContext methods.
+func (bw *BatchWrite) RequestOptions(opts ...request.Option) *BatchWrite {
+	bw.opts = append(bw.opts, opts...)
+	return bw
+}
+
+// ReturnItemCollectionMetrics will record the estimated sizes of the item collections affected by this batch in icm.
+// Metrics are accumulated across every request made by the batch, keeping the latest estimate for each collection.
+// They are only returned for tables with local secondary indexes.
+func (bw *BatchWrite) ReturnItemCollectionMetrics(icm *ItemCollectionMetrics) *BatchWrite {
+	bw.icm = icm
+	return bw
+}
+
+// BatchWriteError is returned by BatchWrite's Run when a batch stops before writing everything.
+// Operations in earlier requests may have been written already.
+type BatchWriteError struct {
+	// Unprocessed holds the write requests that were not written:
+	// the items DynamoDB left unprocessed and the operations that were never sent.
+	Unprocessed []*dynamodb.WriteRequest
+	// Err is the error that stopped the batch, or nil if it gave up retrying unprocessed items.
+	Err error
+}
+
+func (e *BatchWriteError) Error() string {
+	if e.Err == nil {
+		return fmt.Sprintf("dynamo: batch write: gave up with %d unprocessed items", len(e.Unprocessed))
+	}
+	return fmt.Sprintf("dynamo: batch write: %d unprocessed items: %v", len(e.Unprocessed), e.Err)
+}
+
+// Unwrap returns the error that stopped the batch.
+func (e *BatchWriteError) Unwrap() error {
+	return e.Err
+}
+
 // Run executes this batch.
 // For batches with more than 25 operations, an error could indicate that
-// some records have been written and some have not. Consult the wrote
-// return amount to figure out which operations have succeeded.
+// some records have been written and some have not.
+// The wrote return amount only counts how many operations succeeded, not which ones;
+// use errors.As with a *BatchWriteError to find the operations that were not written.
+// Unprocessed items are retried with exponential backoff according to the DB's RetryConfig,
+// giving up after its maximum elapsed time.
 func (bw *BatchWrite) Run() (wrote int, err error) {
 	ctx, cancel := defaultContext()
 	defer cancel()
 	return bw.RunWithContext(ctx)
 }
 
+// RunWithContext executes this batch.
+// See Run for details on partial writes.
 func (bw *BatchWrite) RunWithContext(ctx aws.Context) (wrote int, err error) {
 	if bw.err != nil {
 		return 0, bw.err
@@ -79,7 +150,7 @@ func (bw *BatchWrite) RunWithContext(ctx aws.Context) (wrote int, err error) {
 	// TODO: this could be made to be more efficient,
 	// by combining unprocessed items with the next request.
 
-	boff := backoff.WithContext(backoff.NewExponentialBackOff(), ctx)
+	boff := backoff.WithContext(bw.batch.table.db.retryCfg.backoff(), ctx)
 	batches := int(math.Ceil(float64(len(bw.ops)) / maxWriteOps))
 	for i := 0; i < batches; i++ {
 		start, end := i*maxWriteOps, (i+1)*maxWriteOps
@@ -88,33 +159,55 @@ func (bw *BatchWrite) RunWithContext(ctx aws.Context) (wrote int, err error) {
 		}
 		ops := bw.ops[start:end]
 		for {
+			if err := ctx.Err(); err != nil {
+				return wrote, bw.unprocessed(ops, end, err)
+			}
+
 			var res *dynamodb.BatchWriteItemOutput
 			req := bw.input(ops)
-			err := retry(ctx, func() error {
+			err := bw.batch.table.db.retry(ctx, func() error {
+				if err := bw.batch.table.db.writes.wait(ctx); err != nil {
+					return err
+				}
 				var err error
-				res, err = bw.batch.table.db.client.BatchWriteItemWithContext(ctx, req)
+				start := time.Now()
+				res, err = bw.batch.table.db.client.BatchWriteItemWithContext(ctx, req, bw.opts...)
+				bw.batch.table.db.observe(ctx, "BatchWriteItem", req, res, err, start)
 				return err
 			})
 			if err != nil {
-				return wrote, err
+				return wrote, bw.unprocessed(ops, end, err)
 			}
 			if bw.cc != nil {
 				for _, cc := range res.ConsumedCapacity {
 					addConsumedCapacity(bw.cc, cc)
 				}
 			}
+			bw.batch.table.db.writes.spend(res.ConsumedCapacity...)
+			if bw.icm != nil {
+				for table, metrics := range res.ItemCollectionMetrics {
+					for _, icm := range metrics {
+						addItemCollectionMetrics(bw.icm, table, icm)
+					}
+				}
+			}
 
 			unprocessed := res.UnprocessedItems[bw.batch.table.Name()]
 			wrote += len(ops) - len(unprocessed)
 			if len(unprocessed) == 0 {
+				boff.Reset()
 				break
 			}
 			ops = unprocessed
 
 			// need to sleep when re-requesting, per spec
-			if err := aws.SleepWithContext(ctx, boff.NextBackOff()); err != nil {
+			next := boff.NextBackOff()
+			if next == backoff.Stop {
+				return wrote, bw.unprocessed(ops, end, nil)
+			}
+			if err := aws.SleepWithContext(ctx, next); err != nil {
 				// timed out
-				return wrote, err
+				return wrote, bw.unprocessed(ops, end, err)
 			}
 		}
 	}
@@ -122,14 +215,23 @@ func (bw *BatchWrite) RunWithContext(ctx aws.Context) (wrote int, err error) {
 	return wrote, nil
 }
 
+// unprocessed returns a *BatchWriteError for the pending ops and every operation after end.
+func (bw *BatchWrite) unprocessed(ops []*dynamodb.WriteRequest, end int, err error) error {
+	left := make([]*dynamodb.WriteRequest, 0, len(ops)+len(bw.ops)-end)
+	left = append(left, ops...)
+	left = append(left, bw.ops[end:]...)
+	return &BatchWriteError{Unprocessed: left, Err: err}
+}
+
 func (bw *BatchWrite) input(ops []*dynamodb.WriteRequest) *dynamodb.BatchWriteItemInput {
 	input := &dynamodb.BatchWriteItemInput{
 		RequestItems: map[string][]*dynamodb.WriteRequest{
 			bw.batch.table.Name(): ops,
 		},
 	}
-	if bw.cc != nil {
-		input.ReturnConsumedCapacity = aws.String(dynamodb.ReturnConsumedCapacityIndexes)
+	input.ReturnConsumedCapacity = returnConsumedCapacity(bw.cc, bw.batch.table.db.writeLimited())
+	if bw.icm != nil {
+		input.ReturnItemCollectionMetrics = aws.String(dynamodb.ReturnItemCollectionMetricsSize)
 	}
 	return input
 }
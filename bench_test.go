@@ -61,7 +61,7 @@ func BenchmarkDecodeSimple(b *testing.B) {
 
 	var out simpleObject
 	for n := 0; n < b.N; n++ {
-		unmarshalItem(av, &out)
+		decoder{}.unmarshalItem(av, &out)
 	}
 }
 
@@ -74,7 +74,7 @@ func BenchmarkDecodeSimpleMap(b *testing.B) {
 
 	var out map[string]interface{}
 	for n := 0; n < b.N; n++ {
-		unmarshalItem(av, &out)
+		decoder{}.unmarshalItem(av, &out)
 	}
 }
 
@@ -89,7 +89,7 @@ func BenchmarkDecodeVeryComplex(b *testing.B) {
 
 	var out fancyObject
 	for n := 0; n < b.N; n++ {
-		unmarshalItem(av, &out)
+		decoder{}.unmarshalItem(av, &out)
 	}
 }
 
@@ -98,7 +98,7 @@ func BenchmarkDecodeVeryComplexMap(b *testing.B) {
 
 	var out map[string]interface{}
 	for n := 0; n < b.N; n++ {
-		unmarshalItem(av, &out)
+		decoder{}.unmarshalItem(av, &out)
 	}
 }
 
@@ -14,7 +14,8 @@ type ConditionCheck struct {
 	rangeKey   string
 	rangeValue *dynamodb.AttributeValue
 
-	condition string
+	condition    string
+	condFailItem bool
 	subber
 
 	err error
@@ -28,7 +29,7 @@ func (table Table) Check(hashKey string, value interface{}) *ConditionCheck {
 		table:   table,
 		hashKey: hashKey,
 	}
-	check.hashValue, check.err = marshal(value, "")
+	check.hashValue, check.err = marshalValue("hash key value", value, "")
 	return check
 }
 
@@ -36,7 +37,7 @@ func (table Table) Check(hashKey string, value interface{}) *ConditionCheck {
 func (check *ConditionCheck) Range(rangeKey string, value interface{}) *ConditionCheck {
 	check.rangeKey = rangeKey
 	var err error
-	check.rangeValue, err = marshal(value, "")
+	check.rangeValue, err = marshalValue("range key value", value, "")
 	check.setError(err)
 	return check
 }
@@ -57,6 +58,14 @@ func (check *ConditionCheck) If(expr string, args ...interface{}) *ConditionChec
 	return check
 }
 
+// IncludeItemInCondCheckFail specifies whether the item should be returned if the condition fails.
+// DynamoDB only supports this for condition checks in a write transaction.
+// Use TxCondCheckFailed to get the item from the transaction's error.
+func (check *ConditionCheck) IncludeItemInCondCheckFail(enabled bool) *ConditionCheck {
+	check.condFailItem = enabled
+	return check
+}
+
 // IfExists sets this check to succeed if the item exists.
 func (check *ConditionCheck) IfExists() *ConditionCheck {
 	return check.If("attribute_exists($)", check.hashKey)
@@ -75,11 +84,14 @@ func (check *ConditionCheck) writeTxItem() (*dynamodb.TransactWriteItem, error)
 		TableName: aws.String(check.table.name),
 		Key:       check.keys(),
 		ExpressionAttributeNames:  check.nameExpr,
-		ExpressionAttributeValues: check.valueExpr,
+		ExpressionAttributeValues: check.table.db.emptyStringMode().values(check.valueExpr),
 	}
 	if check.condition != "" {
 		item.ConditionExpression = aws.String(check.condition)
 	}
+	if check.condFailItem {
+		item.ReturnValuesOnConditionCheckFailure = aws.String(dynamodb.ReturnValuesOnConditionCheckFailureAllOld)
+	}
 	return &dynamodb.TransactWriteItem{
 		ConditionCheck: item,
 	}, nil
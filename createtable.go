@@ -6,6 +6,7 @@ import (
 	"reflect"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/service/dynamodb"
@@ -223,18 +224,38 @@ func (ct *CreateTable) Run() error {
 	return ct.RunWithContext(ctx)
 }
 
+// RunWithContext creates this table or returns and error.
 func (ct *CreateTable) RunWithContext(ctx aws.Context) error {
 	if ct.err != nil {
 		return ct.err
 	}
 
 	input := ct.input()
-	return retry(ctx, func() error {
-		_, err := ct.db.client.CreateTableWithContext(ctx, input)
+	return ct.db.retry(ctx, func() error {
+		start := time.Now()
+		out, err := ct.db.client.CreateTableWithContext(ctx, input)
+		ct.db.observe(ctx, "CreateTable", input, out, err, start)
 		return err
 	})
 }
 
+// Wait creates this table and blocks until it and all of its indexes are active.
+func (ct *CreateTable) Wait() error {
+	ctx, cancel := defaultContext()
+	defer cancel()
+	return ct.WaitWithContext(ctx)
+}
+
+// WaitWithContext creates this table and blocks until it and all of its indexes are active.
+// See Table.WaitWithContext.
+func (ct *CreateTable) WaitWithContext(ctx aws.Context) error {
+	if err := ct.RunWithContext(ctx); err != nil {
+		return err
+	}
+
+	return ct.db.Table(ct.tableName).WaitWithContext(ctx)
+}
+
 func (ct *CreateTable) from(rv reflect.Value) error {
 	switch rv.Kind() {
 	case reflect.Struct: // ok
@@ -249,8 +270,7 @@ func (ct *CreateTable) from(rv reflect.Value) error {
 		fv := rv.Field(i)
 
 		name, _, _ := fieldInfo(field)
-		if name == "-" {
-			// skip
+		if isSkipped(field) {
 			continue
 		}
 
@@ -410,14 +430,14 @@ func typeOf(rv reflect.Value, tag string) string {
 		switch x := rv.Interface().(type) {
 		case Marshaler:
 			if av, err := x.MarshalDynamo(); err == nil {
-				if iface, err := av2iface(av); err == nil {
+				if iface, err := (decoder{}).av2iface(av); err == nil {
 					return typeOf(reflect.ValueOf(iface), tag)
 				}
 			}
 		case dynamodbattribute.Marshaler:
 			av := &dynamodb.AttributeValue{}
 			if err := x.MarshalDynamoDBAttributeValue(av); err == nil {
-				if iface, err := av2iface(av); err == nil {
+				if iface, err := (decoder{}).av2iface(av); err == nil {
 					return typeOf(reflect.ValueOf(iface), tag)
 				}
 			}
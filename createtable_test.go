@@ -155,3 +155,15 @@ func TestCreateTableUintUnixTime(t *testing.T) {
 		t.Error("unexpected input (unixtime tag)", input2)
 	}
 }
+
+func TestCreateTableWait(t *testing.T) {
+	client := &mockClient{statuses: []string{"", "CREATING", "CREATING", "ACTIVE"}}
+	db := NewFromIface(client)
+	db.SetRetryConfig(RetryConfig{BaseDelay: time.Millisecond, MaxDelay: time.Millisecond})
+	if err := db.CreateTable("Test", UserAction{}).Wait(); err != nil {
+		t.Error("unexpected error:", err)
+	}
+	if len(client.statuses) != 1 {
+		t.Error("expected to poll until active, statuses left:", client.statuses)
+	}
+}
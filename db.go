@@ -2,30 +2,63 @@
 package dynamo
 
 import (
+	"encoding/base64"
+	"encoding/json"
 	"fmt"
+	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/client"
 	"github.com/aws/aws-sdk-go/service/dynamodb"
 	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+	"github.com/aws/aws-sdk-go/service/dynamodbstreams"
+	"github.com/aws/aws-sdk-go/service/dynamodbstreams/dynamodbstreamsiface"
 )
 
 // DB is a DynamoDB client.
 type DB struct {
-	client dynamodbiface.DynamoDBAPI
+	client      dynamodbiface.DynamoDBAPI
+	streams     dynamodbstreamsiface.DynamoDBStreamsAPI
+	retryCfg    RetryConfig
+	shouldRetry func(error) bool
+	hook        Hook
+	logger      Logger
+
+	emptyStrings EmptyStringMode
+	useNumber    bool
+	reads        *rateLimiter
+	writes       *rateLimiter
 }
 
 // New creates a new client with the given configuration.
+// Settings such as the region, credentials, and HTTP client come from p, usually a *session.Session,
+// and are overridden by cfgs in order, the same as the AWS SDK's service constructors.
+// To use DynamoDB Local or another custom endpoint, set the Endpoint of a config:
+//
+//	db := dynamo.New(session.New(), &aws.Config{
+//		Region:   aws.String("us-west-2"),
+//		Endpoint: aws.String("http://localhost:8000"),
+//	})
 func New(p client.ConfigProvider, cfgs ...*aws.Config) *DB {
 	db := &DB{
-		dynamodb.New(p, cfgs...),
+		client:   dynamodb.New(p, cfgs...),
+		streams:  dynamodbstreams.New(p, cfgs...),
+		retryCfg: DefaultRetryConfig,
 	}
 	return db
 }
 
 // NewFromIface creates a new client with the given interface.
+// This is useful for testing with a mock client, or for adapting another client,
+// such as one built with aws-sdk-go-v2, to this package.
+// Only the WithContext variants of the DynamoDBAPI methods are called,
+// so an adapter can embed dynamodbiface.DynamoDBAPI and implement just the operations it needs.
+// To read table streams, also provide a streams client with SetStreamsClient.
 func NewFromIface(client dynamodbiface.DynamoDBAPI) *DB {
-	return &DB{client}
+	return &DB{
+		client:   client,
+		retryCfg: DefaultRetryConfig,
+	}
 }
 
 // Client returns this DB's internal client used to make API requests.
@@ -33,6 +66,18 @@ func (db *DB) Client() dynamodbiface.DynamoDBAPI {
 	return db.client
 }
 
+// StreamsClient returns this DB's internal client used to read DynamoDB Streams.
+// It is nil for DBs created with NewFromIface, unless set with SetStreamsClient.
+func (db *DB) StreamsClient() dynamodbstreamsiface.DynamoDBStreamsAPI {
+	return db.streams
+}
+
+// SetStreamsClient changes the client used to read DynamoDB Streams.
+// It should be called before making requests, as it is not safe to change concurrently.
+func (db *DB) SetStreamsClient(client dynamodbstreamsiface.DynamoDBStreamsAPI) {
+	db.streams = client
+}
+
 // ListTables is a request to list tables.
 // See: http://docs.aws.amazon.com/amazondynamodb/latest/APIReference/API_ListTables.html
 type ListTables struct {
@@ -104,8 +149,11 @@ func (itr *ltIter) NextWithContext(ctx aws.Context, out interface{}) bool {
 		}
 	}
 
-	itr.err = retry(ctx, func() error {
-		res, err := itr.lt.db.client.ListTables(itr.input())
+	input := itr.input()
+	itr.err = itr.lt.db.retry(ctx, func() error {
+		start := time.Now()
+		res, err := itr.lt.db.client.ListTablesWithContext(ctx, input)
+		itr.lt.db.observe(ctx, "ListTables", input, res, err, start)
 		if err != nil {
 			return err
 		}
@@ -161,4 +209,59 @@ type PagingIter interface {
 
 // PagingKey is a key used for splitting up partial results.
 // Get a PagingKey from a PagingIter and pass it to StartFrom in Query or Scan.
+// PagingKey implements encoding.TextMarshaler and encoding.TextUnmarshaler,
+// encoding to an opaque URL-safe string that can be handed to clients and
+// passed back to StartFromToken in Query or Scan.
 type PagingKey map[string]*dynamodb.AttributeValue
+
+// pagingAttr is the encoded form of a key attribute in a paging token.
+type pagingAttr struct {
+	S *string `json:",omitempty"`
+	N *string `json:",omitempty"`
+	B []byte  `json:",omitempty"`
+}
+
+// MarshalText encodes this key as an opaque URL-safe string.
+// A nil or empty key encodes to an empty string.
+func (pk PagingKey) MarshalText() ([]byte, error) {
+	if len(pk) == 0 {
+		return []byte{}, nil
+	}
+	attrs := make(map[string]pagingAttr, len(pk))
+	for name, av := range pk {
+		if av == nil || (av.S == nil && av.N == nil && av.B == nil) {
+			return nil, fmt.Errorf("dynamo: paging key: unsupported type for attribute %s: %s", name, avTypeName(av))
+		}
+		attrs[name] = pagingAttr{S: av.S, N: av.N, B: av.B}
+	}
+	data, err := json.Marshal(attrs)
+	if err != nil {
+		return nil, err
+	}
+	text := make([]byte, base64.RawURLEncoding.EncodedLen(len(data)))
+	base64.RawURLEncoding.Encode(text, data)
+	return text, nil
+}
+
+// UnmarshalText decodes a key encoded with MarshalText.
+// An empty string decodes to a nil key.
+func (pk *PagingKey) UnmarshalText(text []byte) error {
+	if len(text) == 0 {
+		*pk = nil
+		return nil
+	}
+	data := make([]byte, base64.RawURLEncoding.DecodedLen(len(text)))
+	if _, err := base64.RawURLEncoding.Decode(data, text); err != nil {
+		return fmt.Errorf("dynamo: invalid paging token: %v", err)
+	}
+	var attrs map[string]pagingAttr
+	if err := json.Unmarshal(data, &attrs); err != nil {
+		return fmt.Errorf("dynamo: invalid paging token: %v", err)
+	}
+	key := make(PagingKey, len(attrs))
+	for name, attr := range attrs {
+		key[name] = &dynamodb.AttributeValue{S: attr.S, N: attr.N, B: attr.B}
+	}
+	*pk = key
+	return nil
+}
@@ -2,12 +2,19 @@ package dynamo
 
 import (
 	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
 	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
 )
 
 var (
@@ -19,7 +26,14 @@ const offlineSkipMsg = "DYNAMO_TEST_REGION not set"
 
 func init() {
 	if region := os.Getenv("DYNAMO_TEST_REGION"); region != "" {
-		testDB = New(session.New(), &aws.Config{Region: aws.String(region)})
+		var endpoint *string
+		if dte := os.Getenv("DYNAMO_TEST_ENDPOINT"); dte != "" {
+			endpoint = aws.String(dte)
+		}
+		testDB = New(session.New(), &aws.Config{
+			Region:   aws.String(region),
+			Endpoint: endpoint,
+		})
 	}
 	if table := os.Getenv("DYNAMO_TEST_TABLE"); table != "" {
 		testTable = table
@@ -35,6 +49,281 @@ type widget struct {
 	Meta   map[string]string
 }
 
+// mockClient is a fake DynamoDB client for offline tests.
+// It pages through items in order, using the "Idx" attribute as the key.
+// Unimplemented methods will panic.
+type mockClient struct {
+	dynamodbiface.DynamoDBAPI
+	// mu guards the recorded requests for parallel scans
+	mu    sync.Mutex
+	items []map[string]*dynamodb.AttributeValue
+	// batchSizes records the number of keys or writes in every BatchGetItem or BatchWriteItem request
+	batchSizes []int
+	// maxBatchResults limits how many BatchGetItem or BatchWriteItem operations are processed per request,
+	// leaving the rest unprocessed; 0 means no limit
+	maxBatchResults int
+	// limits records the Limit of every request, or 0 if unset
+	limits []int64
+	tables []string
+	// statuses are returned by successive DescribeTable calls,
+	// with "" meaning the table doesn't exist
+	statuses []string
+	// ttl is set by UpdateTimeToLive; nil means no TTL description is returned
+	ttl *dynamodb.TimeToLiveSpecification
+	// params records the Parameters of the last ExecuteStatement request
+	params []*dynamodb.AttributeValue
+}
+
+func newMockClient(n int) *mockClient {
+	m := &mockClient{}
+	for i := 0; i < n; i++ {
+		m.items = append(m.items, map[string]*dynamodb.AttributeValue{
+			"Idx": {N: aws.String(strconv.Itoa(i))},
+		})
+	}
+	return m
+}
+
+func (m *mockClient) page(startKey map[string]*dynamodb.AttributeValue, limit *int64) (items []map[string]*dynamodb.AttributeValue, lek map[string]*dynamodb.AttributeValue) {
+	start := 0
+	if startKey != nil {
+		idx, _ := strconv.Atoi(*startKey["Idx"].N)
+		start = idx + 1
+	}
+	end := len(m.items)
+	m.mu.Lock()
+	m.limits = append(m.limits, aws.Int64Value(limit))
+	m.mu.Unlock()
+	if limit != nil && start+int(*limit) < end {
+		end = start + int(*limit)
+	}
+	items = m.items[start:end]
+	if end < len(m.items) {
+		lek = map[string]*dynamodb.AttributeValue{"Idx": m.items[end-1]["Idx"]}
+	}
+	return items, lek
+}
+
+// QueryWithContext pages through items. Any filter expression keeps only items with an even Idx.
+// If consumed capacity is requested, each page consumes 0.5 units.
+func (m *mockClient) QueryWithContext(_ aws.Context, in *dynamodb.QueryInput, _ ...request.Option) (*dynamodb.QueryOutput, error) {
+	items, lek := m.page(in.ExclusiveStartKey, in.Limit)
+	scanned := len(items)
+	if in.FilterExpression != nil {
+		var even []map[string]*dynamodb.AttributeValue
+		for _, item := range items {
+			if idx, _ := strconv.Atoi(*item["Idx"].N); idx%2 == 0 {
+				even = append(even, item)
+			}
+		}
+		items = even
+	}
+	out := &dynamodb.QueryOutput{
+		Items:            items,
+		Count:            aws.Int64(int64(len(items))),
+		ScannedCount:     aws.Int64(int64(scanned)),
+		LastEvaluatedKey: lek,
+	}
+	if in.ReturnConsumedCapacity != nil {
+		out.ConsumedCapacity = &dynamodb.ConsumedCapacity{
+			TableName:     in.TableName,
+			CapacityUnits: aws.Float64(0.5),
+		}
+	}
+	return out, nil
+}
+
+func (m *mockClient) ScanWithContext(_ aws.Context, in *dynamodb.ScanInput, _ ...request.Option) (*dynamodb.ScanOutput, error) {
+	items, lek := m.page(in.ExclusiveStartKey, in.Limit)
+	return &dynamodb.ScanOutput{
+		Items:            items,
+		Count:            aws.Int64(int64(len(items))),
+		LastEvaluatedKey: lek,
+	}, nil
+}
+
+// BatchGetItemWithContext returns each requested key as an item.
+func (m *mockClient) BatchGetItemWithContext(_ aws.Context, in *dynamodb.BatchGetItemInput, _ ...request.Option) (*dynamodb.BatchGetItemOutput, error) {
+	out := &dynamodb.BatchGetItemOutput{
+		Responses:       make(map[string][]map[string]*dynamodb.AttributeValue),
+		UnprocessedKeys: make(map[string]*dynamodb.KeysAndAttributes),
+	}
+	for table, kas := range in.RequestItems {
+		m.batchSizes = append(m.batchSizes, len(kas.Keys))
+		keys := kas.Keys
+		if m.maxBatchResults > 0 && len(keys) > m.maxBatchResults {
+			out.UnprocessedKeys[table] = &dynamodb.KeysAndAttributes{Keys: keys[m.maxBatchResults:]}
+			keys = keys[:m.maxBatchResults]
+		}
+		out.Responses[table] = keys
+	}
+	return out, nil
+}
+
+// BatchWriteItemWithContext accepts every write, up to maxBatchResults per request.
+func (m *mockClient) BatchWriteItemWithContext(_ aws.Context, in *dynamodb.BatchWriteItemInput, _ ...request.Option) (*dynamodb.BatchWriteItemOutput, error) {
+	out := &dynamodb.BatchWriteItemOutput{
+		UnprocessedItems: make(map[string][]*dynamodb.WriteRequest),
+	}
+	for table, reqs := range in.RequestItems {
+		m.batchSizes = append(m.batchSizes, len(reqs))
+		if m.maxBatchResults > 0 && len(reqs) > m.maxBatchResults {
+			out.UnprocessedItems[table] = reqs[m.maxBatchResults:]
+			reqs = reqs[:m.maxBatchResults]
+		}
+		if aws.StringValue(in.ReturnItemCollectionMetrics) == dynamodb.ReturnItemCollectionMetricsSize {
+			if out.ItemCollectionMetrics == nil {
+				out.ItemCollectionMetrics = make(map[string][]*dynamodb.ItemCollectionMetrics)
+			}
+			for _, req := range reqs {
+				if req.PutRequest != nil {
+					out.ItemCollectionMetrics[table] = append(out.ItemCollectionMetrics[table], m.collectionMetrics(req.PutRequest.Item))
+				}
+			}
+		}
+	}
+	return out, nil
+}
+
+// collectionMetrics returns fake metrics for the item collection keyed by item's Idx,
+// estimating its size as the number of requests made so far.
+func (m *mockClient) collectionMetrics(item map[string]*dynamodb.AttributeValue) *dynamodb.ItemCollectionMetrics {
+	size := float64(len(m.batchSizes))
+	return &dynamodb.ItemCollectionMetrics{
+		ItemCollectionKey:   map[string]*dynamodb.AttributeValue{"Idx": item["Idx"]},
+		SizeEstimateRangeGB: aws.Float64Slice([]float64{size, size + 1}),
+	}
+}
+
+// GetItemWithContext returns the item with a matching Idx, if any.
+func (m *mockClient) GetItemWithContext(_ aws.Context, in *dynamodb.GetItemInput, _ ...request.Option) (*dynamodb.GetItemOutput, error) {
+	out := &dynamodb.GetItemOutput{}
+	if key := in.Key["Idx"]; key != nil && key.N != nil {
+		for _, item := range m.items {
+			if *item["Idx"].N == *key.N {
+				out.Item = item
+			}
+		}
+	}
+	return out, nil
+}
+
+func (m *mockClient) UpdateTimeToLiveWithContext(_ aws.Context, in *dynamodb.UpdateTimeToLiveInput, _ ...request.Option) (*dynamodb.UpdateTimeToLiveOutput, error) {
+	m.ttl = in.TimeToLiveSpecification
+	return &dynamodb.UpdateTimeToLiveOutput{TimeToLiveSpecification: in.TimeToLiveSpecification}, nil
+}
+
+func (m *mockClient) DescribeTimeToLiveWithContext(_ aws.Context, in *dynamodb.DescribeTimeToLiveInput, _ ...request.Option) (*dynamodb.DescribeTimeToLiveOutput, error) {
+	out := &dynamodb.DescribeTimeToLiveOutput{}
+	if m.ttl != nil {
+		status := dynamodb.TimeToLiveStatusDisabled
+		if aws.BoolValue(m.ttl.Enabled) {
+			status = dynamodb.TimeToLiveStatusEnabled
+		}
+		out.TimeToLiveDescription = &dynamodb.TimeToLiveDescription{
+			AttributeName:    m.ttl.AttributeName,
+			TimeToLiveStatus: aws.String(status),
+		}
+	}
+	return out, nil
+}
+
+func (m *mockClient) PutItemWithContext(_ aws.Context, in *dynamodb.PutItemInput, _ ...request.Option) (*dynamodb.PutItemOutput, error) {
+	out := &dynamodb.PutItemOutput{}
+	if aws.StringValue(in.ReturnItemCollectionMetrics) == dynamodb.ReturnItemCollectionMetricsSize {
+		out.ItemCollectionMetrics = m.collectionMetrics(in.Item)
+	}
+	return out, nil
+}
+
+// ExecuteStatementWithContext pages through items two at a time, using the last Idx as the NextToken.
+// If the first parameter is a number, only the item with that Idx is returned.
+func (m *mockClient) ExecuteStatementWithContext(_ aws.Context, in *dynamodb.ExecuteStatementInput, _ ...request.Option) (*dynamodb.ExecuteStatementOutput, error) {
+	const pageSize = 2
+	m.params = in.Parameters
+	if len(in.Parameters) > 0 && in.Parameters[0].N != nil {
+		out := &dynamodb.ExecuteStatementOutput{}
+		for _, item := range m.items {
+			if *item["Idx"].N == *in.Parameters[0].N {
+				out.Items = append(out.Items, item)
+			}
+		}
+		return out, nil
+	}
+	start := 0
+	if in.NextToken != nil {
+		idx, _ := strconv.Atoi(*in.NextToken)
+		start = idx + 1
+	}
+	end := start + pageSize
+	if end > len(m.items) {
+		end = len(m.items)
+	}
+	out := &dynamodb.ExecuteStatementOutput{Items: m.items[start:end]}
+	if end < len(m.items) {
+		out.NextToken = m.items[end-1]["Idx"].N
+	}
+	return out, nil
+}
+
+func (m *mockClient) ListTablesWithContext(_ aws.Context, in *dynamodb.ListTablesInput, _ ...request.Option) (*dynamodb.ListTablesOutput, error) {
+	const pageSize = 2
+	start := 0
+	if in.ExclusiveStartTableName != nil {
+		for i, name := range m.tables {
+			if name == *in.ExclusiveStartTableName {
+				start = i + 1
+			}
+		}
+	}
+	end := start + pageSize
+	if end > len(m.tables) {
+		end = len(m.tables)
+	}
+	out := &dynamodb.ListTablesOutput{TableNames: aws.StringSlice(m.tables[start:end])}
+	if end < len(m.tables) {
+		out.LastEvaluatedTableName = aws.String(m.tables[end-1])
+	}
+	return out, nil
+}
+
+func (m *mockClient) CreateTableWithContext(_ aws.Context, in *dynamodb.CreateTableInput, _ ...request.Option) (*dynamodb.CreateTableOutput, error) {
+	return &dynamodb.CreateTableOutput{}, nil
+}
+
+func (m *mockClient) DescribeTableWithContext(_ aws.Context, in *dynamodb.DescribeTableInput, _ ...request.Option) (*dynamodb.DescribeTableOutput, error) {
+	status := m.statuses[0]
+	if len(m.statuses) > 1 {
+		m.statuses = m.statuses[1:]
+	}
+	if status == "" {
+		return nil, awserr.NewRequestFailure(awserr.New(dynamodb.ErrCodeResourceNotFoundException, "not found", nil), 400, "")
+	}
+	return &dynamodb.DescribeTableOutput{
+		Table: &dynamodb.TableDescription{
+			TableName:   in.TableName,
+			TableStatus: aws.String(status),
+			ItemCount:   aws.Int64(int64(len(m.items))),
+		},
+	}, nil
+}
+
+func (m *mockClient) DeleteTableWithContext(_ aws.Context, in *dynamodb.DeleteTableInput, _ ...request.Option) (*dynamodb.DeleteTableOutput, error) {
+	if m.statuses[0] == "" {
+		return nil, awserr.NewRequestFailure(awserr.New(dynamodb.ErrCodeResourceNotFoundException, "not found", nil), 400, "")
+	}
+	return &dynamodb.DeleteTableOutput{}, nil
+}
+
+func (m *mockClient) UpdateTableWithContext(_ aws.Context, in *dynamodb.UpdateTableInput, _ ...request.Option) (*dynamodb.UpdateTableOutput, error) {
+	return &dynamodb.UpdateTableOutput{
+		TableDescription: &dynamodb.TableDescription{
+			TableName:   in.TableName,
+			TableStatus: aws.String(dynamodb.TableStatusUpdating),
+		},
+	}, nil
+}
+
 func isConditionalCheckErr(err error) bool {
 	if ae, ok := err.(awserr.RequestFailure); ok {
 		return ae.Code() == "ConditionalCheckFailedException"
@@ -42,6 +331,22 @@ func isConditionalCheckErr(err error) bool {
 	return false
 }
 
+func TestNewFromIface(t *testing.T) {
+	client := &mockClient{tables: []string{"A", "B", "C", "D", "E"}}
+	db := NewFromIface(client)
+	if db.Client() != client {
+		t.Error("Client() should return the given client")
+	}
+
+	tables, err := db.ListTables().All()
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+	if !reflect.DeepEqual(tables, client.tables) {
+		t.Error("bad tables:", tables, "≠", client.tables)
+	}
+}
+
 func TestListTables(t *testing.T) {
 	if testDB == nil {
 		t.Skip(offlineSkipMsg)
@@ -65,3 +370,45 @@ func TestListTables(t *testing.T) {
 		t.Error("couldn't find testTable", testTable, "in:", tables)
 	}
 }
+
+func TestPagingKeyText(t *testing.T) {
+	key := PagingKey{
+		"UserID": &dynamodb.AttributeValue{N: aws.String("613")},
+		"Time":   &dynamodb.AttributeValue{S: aws.String("2019-12-25T00:00:00Z")},
+		"Blob":   &dynamodb.AttributeValue{B: []byte{0, 1, 2, 0xff}},
+	}
+	text, err := key.MarshalText()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.ContainsAny(string(text), "+/=") {
+		t.Error("token is not URL-safe:", string(text))
+	}
+
+	var decoded PagingKey
+	if err := decoded.UnmarshalText(text); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(key, decoded) {
+		t.Error("bad round-trip:", key, "≠", decoded)
+	}
+
+	// empty keys and empty tokens
+	text, err = PagingKey(nil).MarshalText()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(text) != 0 {
+		t.Error("expected empty token, got:", string(text))
+	}
+	if err := decoded.UnmarshalText(text); err != nil {
+		t.Fatal(err)
+	}
+	if decoded != nil {
+		t.Error("expected nil key, got:", decoded)
+	}
+
+	if err := decoded.UnmarshalText([]byte("!!!")); err == nil {
+		t.Error("expected error for invalid token")
+	}
+}
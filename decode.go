@@ -2,7 +2,9 @@ package dynamo
 
 import (
 	"encoding"
+	"encoding/json"
 	"fmt"
+	"math/big"
 	"reflect"
 	"strconv"
 	"time"
@@ -17,25 +19,179 @@ type Unmarshaler interface {
 	UnmarshalDynamo(av *dynamodb.AttributeValue) error
 }
 
-// Unmarshal decodes a DynamoDB item into out, which must be a pointer.
+// UnmarshalItem decodes a DynamoDB item into out, which must be a pointer.
+// It is the counterpart of MarshalItem, useful for decoding the output of requests made with the raw client.
+// Items can be decoded into *map[string]interface{} or *interface{},
+// converting values the same way as encoding/json: numbers become float64,
+// lists and sets become slices, and maps become map[string]interface{}.
+// To keep numbers exact, decode them into json.Number, such as a *map[string]json.Number.
+// Results unmarshaled by a DB can decode numbers into interface{} as json.Number too, see DB.UseNumber.
+// If out implements Unmarshaler, UnmarshalDynamo is given the item as a map (M) value.
 func UnmarshalItem(item map[string]*dynamodb.AttributeValue, out interface{}) error {
-	return unmarshalItem(item, out)
+	return decoder{}.unmarshalItem(item, out)
 }
 
 // Unmarshal decodes a DynamoDB value into out, which must be a pointer.
+// It is the counterpart of Marshal.
 func Unmarshal(av *dynamodb.AttributeValue, out interface{}) error {
 	rv := reflect.ValueOf(out)
-	return unmarshalReflect(av, rv)
+	if rv.Kind() == reflect.Ptr {
+		if rv.IsNil() {
+			return fmt.Errorf("dynamo: unmarshal: nil pointer: %T", out)
+		}
+		return decoder{}.unmarshalReflect(av, rv.Elem())
+	}
+	return decoder{}.unmarshalReflect(av, rv)
+}
+
+// UseNumber makes this DB decode numbers into interface{} values as json.Number instead of float64,
+// like the UseNumber method of encoding/json's Decoder.
+// This keeps numbers exact, such as IDs with more digits than a float64 can hold,
+// when unmarshaling into map[string]interface{} and the like. Number sets become []json.Number.
+// It applies to every result this DB unmarshals, but not to the package-level UnmarshalItem and Unmarshal.
+// It should be called before making requests, as it is not safe to change concurrently.
+func (db *DB) UseNumber(use bool) {
+	db.useNumber = use
+}
+
+// decoder returns the decoder used to unmarshal this DB's results.
+func (db *DB) decoder() decoder {
+	if db == nil {
+		return decoder{}
+	}
+	return decoder{useNumber: db.useNumber}
+}
+
+// decoder holds options for unmarshaling.
+type decoder struct {
+	// useNumber decodes numbers into interface{} as json.Number.
+	useNumber bool
 }
 
 // used in iterators for unmarshaling one item
 type unmarshalFunc func(map[string]*dynamodb.AttributeValue, interface{}) error
 
+// UnmarshalError is returned by iterators and All when a result can't be unmarshaled.
+// Results unmarshaled before the error are kept.
+type UnmarshalError struct {
+	// Index is the position of the item in the results, starting from 0.
+	Index int64
+	// Item is the raw item that couldn't be unmarshaled.
+	Item map[string]*dynamodb.AttributeValue
+	// Err is the underlying error.
+	Err error
+}
+
+func (e *UnmarshalError) Error() string {
+	return fmt.Sprintf("dynamo: unmarshal item %d: %v", e.Index, e.Err)
+}
+
+// Unwrap returns the underlying error.
+func (e *UnmarshalError) Unwrap() error {
+	return e.Err
+}
+
+// unmarshalError wraps err from unmarshaling the item at idx.
+// If handler is not nil, it decides the error returned; nil means the item should be skipped.
+func unmarshalError(handler func(*UnmarshalError) error, idx int64, item map[string]*dynamodb.AttributeValue, err error) error {
+	uerr := &UnmarshalError{Index: idx, Item: item, Err: err}
+	if handler != nil {
+		return handler(uerr)
+	}
+	return uerr
+}
+
+// unmarshalRaw is an unmarshalFunc that stores item as-is in out, which must be *map[string]*dynamodb.AttributeValue.
+func unmarshalRaw(item map[string]*dynamodb.AttributeValue, out interface{}) error {
+	*out.(*map[string]*dynamodb.AttributeValue) = item
+	return nil
+}
+
 var nilTum encoding.TextUnmarshaler
 var tumType = reflect.TypeOf(&nilTum).Elem()
 
+// bigFloatPrec is the precision used to decode big.Floats without one set,
+// enough for the 38 significant digits of a DynamoDB number.
+const bigFloatPrec = 128
+
+// unmarshalBig parses the number n directly into x, a *big.Int, *big.Float, or *big.Rat.
+func unmarshalBig(n string, x interface{}) error {
+	var ok bool
+	switch x := x.(type) {
+	case *big.Int:
+		_, ok = x.SetString(n, 10)
+	case *big.Float:
+		if x.Prec() == 0 {
+			x.SetPrec(bigFloatPrec)
+		}
+		_, ok = x.SetString(n)
+	case *big.Rat:
+		_, ok = x.SetString(n)
+	}
+	if !ok {
+		return fmt.Errorf("dynamo: cannot unmarshal number %s into %T", n, x)
+	}
+	return nil
+}
+
+// setMapKey decodes the map key k into the value pointed to by kp,
+// which must be a string, an integer, or implement encoding.TextUnmarshaler.
+func setMapKey(kp reflect.Value, k string) error {
+	if kp.Type().Implements(tumType) {
+		tm := kp.Interface().(encoding.TextUnmarshaler)
+		if err := tm.UnmarshalText([]byte(k)); err != nil {
+			return fmt.Errorf("dynamo: unmarshal map: key error: %v", err)
+		}
+		return nil
+	}
+	kv := kp.Elem()
+	switch kv.Kind() {
+	case reflect.String:
+		kv.SetString(k)
+	case reflect.Int, reflect.Int64, reflect.Int32, reflect.Int16, reflect.Int8:
+		n, err := strconv.ParseInt(k, 10, kv.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("dynamo: unmarshal map: key error: %v", err)
+		}
+		kv.SetInt(n)
+	case reflect.Uint, reflect.Uint64, reflect.Uint32, reflect.Uint16, reflect.Uint8:
+		n, err := strconv.ParseUint(k, 10, kv.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("dynamo: unmarshal map: key error: %v", err)
+		}
+		kv.SetUint(n)
+	default:
+		return fmt.Errorf("dynamo: unmarshal map: key must be a string, integer, or encoding.TextUnmarshaler: %v", kv.Type())
+	}
+	return nil
+}
+
+// unmarshalTimeLayout unmarshals a string formatted with layout into rv,
+// a time.Time or *time.Time. Other values are unmarshaled as usual.
+func (d decoder) unmarshalTimeLayout(av *dynamodb.AttributeValue, rv reflect.Value, layout string) error {
+	if av.S == nil {
+		return d.unmarshalReflect(av, rv)
+	}
+	var t *time.Time
+	switch x := rv.Addr().Interface().(type) {
+	case *time.Time:
+		t = x
+	case **time.Time:
+		*x = new(time.Time)
+		t = *x
+	default:
+		return d.unmarshalReflect(av, rv)
+	}
+	parsed, err := time.Parse(layout, *av.S)
+	if err != nil {
+		return fmt.Errorf("dynamo: cannot unmarshal %q into time.Time with format %q: %v", *av.S, layout, err)
+	}
+	*t = parsed
+	return nil
+}
+
 // unmarshals one value
-func unmarshalReflect(av *dynamodb.AttributeValue, rv reflect.Value) error {
+func (d decoder) unmarshalReflect(av *dynamodb.AttributeValue, rv reflect.Value) error {
 	// first try interface unmarshal stuff
 	if rv.CanInterface() {
 		var iface interface{}
@@ -61,6 +217,19 @@ func unmarshalReflect(av *dynamodb.AttributeValue, rv reflect.Value) error {
 		case *dynamodb.AttributeValue:
 			*x = *av
 			return nil
+		case *big.Int, *big.Float, *big.Rat:
+			if av.N != nil {
+				return unmarshalBig(*av.N, x)
+			}
+		case *json.Number:
+			// keep the exact number, which may not fit in a float64
+			if av.N != nil {
+				*x = json.Number(*av.N)
+				return nil
+			}
+		}
+
+		switch x := iface.(type) {
 		case Unmarshaler:
 			return x.UnmarshalDynamo(av)
 		case dynamodbattribute.Unmarshaler:
@@ -82,7 +251,7 @@ func unmarshalReflect(av *dynamodb.AttributeValue, rv reflect.Value) error {
 		pt := reflect.New(rv.Type().Elem())
 		rv.Set(pt)
 		if av.NULL == nil || !(*av.NULL) {
-			return unmarshalReflect(av, rv.Elem())
+			return d.unmarshalReflect(av, rv.Elem())
 		}
 		return nil
 	case reflect.Bool:
@@ -131,7 +300,7 @@ func unmarshalReflect(av *dynamodb.AttributeValue, rv reflect.Value) error {
 		if av.M == nil {
 			return fmt.Errorf("dynamo: cannot unmarshal %s data into struct", avTypeName(av))
 		}
-		if err := unmarshalItem(av.M, rv.Addr().Interface()); err != nil {
+		if err := d.unmarshalItem(av.M, rv.Addr().Interface()); err != nil {
 			return err
 		}
 		return nil
@@ -161,16 +330,11 @@ func unmarshalReflect(av *dynamodb.AttributeValue, rv reflect.Value) error {
 			kv := kp.Elem()
 			for k, v := range av.M {
 				innerRV := reflect.New(rv.Type().Elem())
-				if err := unmarshalReflect(v, innerRV.Elem()); err != nil {
+				if err := d.unmarshalReflect(v, innerRV.Elem()); err != nil {
 					return err
 				}
-				if kp.Type().Implements(tumType) {
-					tm := kp.Interface().(encoding.TextUnmarshaler)
-					if err := tm.UnmarshalText([]byte(k)); err != nil {
-						return fmt.Errorf("dynamo: unmarshal map: key error: %v", err)
-					}
-				} else {
-					kv.SetString(k)
+				if err := setMapKey(kp, k); err != nil {
+					return err
 				}
 				rv.SetMapIndex(kv, innerRV.Elem())
 			}
@@ -193,8 +357,8 @@ func unmarshalReflect(av *dynamodb.AttributeValue, rv reflect.Value) error {
 		case av.NS != nil:
 			kv := reflect.New(rv.Type().Key()).Elem()
 			for _, n := range av.NS {
-				if err := unmarshalReflect(&dynamodb.AttributeValue{N: n}, kv); err != nil {
-					return nil
+				if err := d.unmarshalReflect(&dynamodb.AttributeValue{N: n}, kv); err != nil {
+					return err
 				}
 				rv.SetMapIndex(kv, truthy)
 			}
@@ -202,6 +366,9 @@ func unmarshalReflect(av *dynamodb.AttributeValue, rv reflect.Value) error {
 		case av.BS != nil:
 			for _, bb := range av.BS {
 				kv := reflect.New(rv.Type().Key()).Elem()
+				if kv.Kind() != reflect.Array || kv.Len() != len(bb) {
+					return fmt.Errorf("dynamo: unmarshal map (BS): can't fit %d bytes into key type %s", len(bb), kv.Type())
+				}
 				for i, b := range bb {
 					kv.Index(i).Set(reflect.ValueOf(b))
 				}
@@ -211,7 +378,7 @@ func unmarshalReflect(av *dynamodb.AttributeValue, rv reflect.Value) error {
 		}
 		return fmt.Errorf("dynamo: cannot unmarshal %s data into map", avTypeName(av))
 	case reflect.Slice:
-		return unmarshalSlice(av, rv)
+		return d.unmarshalSlice(av, rv)
 	case reflect.Array:
 		arr := reflect.New(rv.Type()).Elem()
 		elemtype := arr.Type().Elem()
@@ -225,7 +392,7 @@ func unmarshalReflect(av *dynamodb.AttributeValue, rv reflect.Value) error {
 		case av.L != nil:
 			for i, innerAV := range av.L {
 				innerRV := reflect.New(elemtype).Elem()
-				if err := unmarshalReflect(innerAV, innerRV); err != nil {
+				if err := d.unmarshalReflect(innerAV, innerRV); err != nil {
 					return err
 				}
 				arr.Index(i).Set(innerRV)
@@ -236,7 +403,7 @@ func unmarshalReflect(av *dynamodb.AttributeValue, rv reflect.Value) error {
 	case reflect.Interface:
 		// interface{}
 		if rv.NumMethod() == 0 {
-			iface, err := av2iface(av)
+			iface, err := d.av2iface(av)
 			if err != nil {
 				return err
 			}
@@ -254,7 +421,7 @@ func unmarshalReflect(av *dynamodb.AttributeValue, rv reflect.Value) error {
 }
 
 // unmarshal for when rv's Kind is Slice
-func unmarshalSlice(av *dynamodb.AttributeValue, rv reflect.Value) error {
+func (d decoder) unmarshalSlice(av *dynamodb.AttributeValue, rv reflect.Value) error {
 	switch {
 	case av.B != nil:
 		rv.SetBytes(av.B)
@@ -264,7 +431,7 @@ func unmarshalSlice(av *dynamodb.AttributeValue, rv reflect.Value) error {
 		slicev := reflect.MakeSlice(rv.Type(), 0, len(av.L))
 		for _, innerAV := range av.L {
 			innerRV := reflect.New(rv.Type().Elem()).Elem()
-			if err := unmarshalReflect(innerAV, innerRV); err != nil {
+			if err := d.unmarshalReflect(innerAV, innerRV); err != nil {
 				return err
 			}
 			slicev = reflect.Append(slicev, innerRV)
@@ -277,7 +444,7 @@ func unmarshalSlice(av *dynamodb.AttributeValue, rv reflect.Value) error {
 		slicev := reflect.MakeSlice(rv.Type(), 0, len(av.L))
 		for _, b := range av.BS {
 			innerRV := reflect.New(rv.Type().Elem()).Elem()
-			if err := unmarshalReflect(&dynamodb.AttributeValue{B: b}, innerRV); err != nil {
+			if err := d.unmarshalReflect(&dynamodb.AttributeValue{B: b}, innerRV); err != nil {
 				return err
 			}
 			slicev = reflect.Append(slicev, innerRV)
@@ -288,7 +455,7 @@ func unmarshalSlice(av *dynamodb.AttributeValue, rv reflect.Value) error {
 		slicev := reflect.MakeSlice(rv.Type(), 0, len(av.L))
 		for _, str := range av.SS {
 			innerRV := reflect.New(rv.Type().Elem()).Elem()
-			if err := unmarshalReflect(&dynamodb.AttributeValue{S: str}, innerRV); err != nil {
+			if err := d.unmarshalReflect(&dynamodb.AttributeValue{S: str}, innerRV); err != nil {
 				return err
 			}
 			slicev = reflect.Append(slicev, innerRV)
@@ -299,7 +466,7 @@ func unmarshalSlice(av *dynamodb.AttributeValue, rv reflect.Value) error {
 		slicev := reflect.MakeSlice(rv.Type(), 0, len(av.L))
 		for _, n := range av.NS {
 			innerRV := reflect.New(rv.Type().Elem()).Elem()
-			if err := unmarshalReflect(&dynamodb.AttributeValue{N: n}, innerRV); err != nil {
+			if err := d.unmarshalReflect(&dynamodb.AttributeValue{N: n}, innerRV); err != nil {
 				return err
 			}
 			slicev = reflect.Append(slicev, innerRV)
@@ -310,45 +477,14 @@ func unmarshalSlice(av *dynamodb.AttributeValue, rv reflect.Value) error {
 	return fmt.Errorf("dynamo: cannot unmarshal %s data into slice", avTypeName(av))
 }
 
-func fieldsInStruct(rv reflect.Value) map[string]reflect.Value {
-	if rv.Kind() == reflect.Ptr {
-		return fieldsInStruct(rv.Elem())
-	}
-
-	fields := make(map[string]reflect.Value)
-	for i := 0; i < rv.Type().NumField(); i++ {
-		field := rv.Type().Field(i)
-		fv := rv.Field(i)
-
-		name, _, _ := fieldInfo(field)
-		if name == "-" {
-			// skip
-			continue
-		}
-
-		// embed anonymous structs
-		if fv.Type().Kind() == reflect.Struct && field.Anonymous {
-			innerFields := fieldsInStruct(fv)
-			for k, v := range innerFields {
-				// don't clobber top-level fields
-				if _, exists := fields[k]; exists {
-					continue
-				}
-				fields[k] = v
-			}
-			continue
-		}
-
-		fields[name] = fv
-	}
-	return fields
-}
-
 // unmarshals a struct
-func unmarshalItem(item map[string]*dynamodb.AttributeValue, out interface{}) error {
-	if out, ok := out.(*map[string]*dynamodb.AttributeValue); ok {
-		*out = item
+func (d decoder) unmarshalItem(item map[string]*dynamodb.AttributeValue, out interface{}) error {
+	switch x := out.(type) {
+	case *map[string]*dynamodb.AttributeValue:
+		*x = item
 		return nil
+	case Unmarshaler:
+		return x.UnmarshalDynamo(&dynamodb.AttributeValue{M: item})
 	}
 
 	rv := reflect.ValueOf(out)
@@ -359,41 +495,62 @@ func unmarshalItem(item map[string]*dynamodb.AttributeValue, out interface{}) er
 	switch rv.Elem().Kind() {
 	case reflect.Ptr:
 		rv.Elem().Set(reflect.New(rv.Elem().Type().Elem()))
-		return unmarshalItem(item, rv.Elem().Interface())
+		return d.unmarshalItem(item, rv.Elem().Interface())
 	case reflect.Struct:
 		var err error
 		rv.Elem().Set(reflect.Zero(rv.Type().Elem()))
-		fields := fieldsInStruct(rv.Elem())
-		for name, fv := range fields {
-			if av, ok := item[name]; ok {
-				if innerErr := unmarshalReflect(av, fv); innerErr != nil {
-					err = innerErr
+		for _, field := range structFields(rv.Elem().Type()) {
+			av, ok := item[field.name]
+			if !ok {
+				continue
+			}
+			fv, innerErr := field.settable(rv.Elem())
+			if innerErr == nil {
+				if layout, ok := timeLayout(field.special); ok {
+					innerErr = d.unmarshalTimeLayout(av, fv, layout)
+				} else {
+					innerErr = d.unmarshalReflect(av, fv)
 				}
 			}
+			if innerErr != nil {
+				err = innerErr
+			}
 		}
 		return err
 	case reflect.Map:
 		mapv := rv.Elem()
-		if mapv.Type().Key().Kind() != reflect.String {
-			return fmt.Errorf("dynamo: unmarshal: map key must be a string: %T", mapv.Interface())
-		}
 		if mapv.IsNil() {
 			mapv.Set(reflect.MakeMap(mapv.Type()))
 		}
 
+		kp := reflect.New(mapv.Type().Key())
 		for k, av := range item {
 			innerRV := reflect.New(mapv.Type().Elem()).Elem()
-			if err := unmarshalReflect(av, innerRV); err != nil {
+			if err := d.unmarshalReflect(av, innerRV); err != nil {
+				return err
+			}
+			if err := setMapKey(kp, k); err != nil {
 				return err
 			}
-			mapv.SetMapIndex(reflect.ValueOf(k), innerRV)
+			mapv.SetMapIndex(kp.Elem(), innerRV)
 		}
 		return nil
+	case reflect.Interface:
+		if rv.Elem().NumMethod() != 0 {
+			break
+		}
+		// like encoding/json, decode into a map[string]interface{}
+		iface, err := d.av2iface(&dynamodb.AttributeValue{M: item})
+		if err != nil {
+			return err
+		}
+		rv.Elem().Set(reflect.ValueOf(iface))
+		return nil
 	}
 	return fmt.Errorf("dynamo: unmarshal: unsupported type: %T", out)
 }
 
-func unmarshalAppend(item map[string]*dynamodb.AttributeValue, out interface{}) error {
+func (d decoder) unmarshalAppend(item map[string]*dynamodb.AttributeValue, out interface{}) error {
 	rv := reflect.ValueOf(out)
 	if rv.Kind() != reflect.Ptr || rv.Elem().Kind() != reflect.Slice {
 		return fmt.Errorf("dynamo: unmarshal append: result argument must be a slice pointer")
@@ -401,7 +558,7 @@ func unmarshalAppend(item map[string]*dynamodb.AttributeValue, out interface{})
 
 	slicev := rv.Elem()
 	innerRV := reflect.New(slicev.Type().Elem())
-	if err := unmarshalItem(item, innerRV.Interface()); err != nil {
+	if err := d.unmarshalItem(item, innerRV.Interface()); err != nil {
 		return err
 	}
 	slicev = reflect.Append(slicev, innerRV.Elem())
@@ -411,7 +568,7 @@ func unmarshalAppend(item map[string]*dynamodb.AttributeValue, out interface{})
 }
 
 // av2iface converts an av into interface{}.
-func av2iface(av *dynamodb.AttributeValue) (interface{}, error) {
+func (d decoder) av2iface(av *dynamodb.AttributeValue) (interface{}, error) {
 	switch {
 	case av.B != nil:
 		return av.B, nil
@@ -420,13 +577,16 @@ func av2iface(av *dynamodb.AttributeValue) (interface{}, error) {
 	case av.BOOL != nil:
 		return *av.BOOL, nil
 	case av.N != nil:
+		if d.useNumber {
+			return json.Number(*av.N), nil
+		}
 		return strconv.ParseFloat(*av.N, 64)
 	case av.S != nil:
 		return *av.S, nil
 	case av.L != nil:
 		list := make([]interface{}, 0, len(av.L))
 		for _, item := range av.L {
-			iface, err := av2iface(item)
+			iface, err := d.av2iface(item)
 			if err != nil {
 				return nil, err
 			}
@@ -434,6 +594,13 @@ func av2iface(av *dynamodb.AttributeValue) (interface{}, error) {
 		}
 		return list, nil
 	case av.NS != nil:
+		if d.useNumber {
+			set := make([]json.Number, 0, len(av.NS))
+			for _, n := range av.NS {
+				set = append(set, json.Number(*n))
+			}
+			return set, nil
+		}
 		set := make([]float64, 0, len(av.NS))
 		for _, n := range av.NS {
 			f, err := strconv.ParseFloat(*n, 64)
@@ -452,7 +619,7 @@ func av2iface(av *dynamodb.AttributeValue) (interface{}, error) {
 	case av.M != nil:
 		m := make(map[string]interface{}, len(av.M))
 		for k, v := range av.M {
-			iface, err := av2iface(v)
+			iface, err := d.av2iface(v)
 			if err != nil {
 				return nil, err
 			}
@@ -1,9 +1,14 @@
 package dynamo
 
 import (
+	"encoding/json"
+	"math/big"
 	"reflect"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/service/dynamodb"
 )
 
@@ -26,7 +31,7 @@ func TestUnmarshalAppend(t *testing.T) {
 	}
 
 	for range [15]struct{}{} {
-		err := unmarshalAppend(item, &results)
+		err := decoder{}.unmarshalAppend(item, &results)
 		if err != nil {
 			t.Fatal(err)
 		}
@@ -41,7 +46,7 @@ func TestUnmarshalAppend(t *testing.T) {
 	var mapResults []map[string]interface{}
 
 	for range [15]struct{}{} {
-		err := unmarshalAppend(item, &mapResults)
+		err := decoder{}.unmarshalAppend(item, &mapResults)
 		if err != nil {
 			t.Fatal(err)
 		}
@@ -57,7 +62,7 @@ func TestUnmarshalAppend(t *testing.T) {
 func TestUnmarshal(t *testing.T) {
 	for _, tc := range encodingTests {
 		rv := reflect.New(reflect.TypeOf(tc.in))
-		err := unmarshalReflect(tc.out, rv.Elem())
+		err := decoder{}.unmarshalReflect(tc.out, rv.Elem())
 		if err != nil {
 			t.Errorf("%s: unexpected error: %v", tc.name, err)
 		}
@@ -71,7 +76,7 @@ func TestUnmarshal(t *testing.T) {
 func TestUnmarshalItem(t *testing.T) {
 	for _, tc := range itemEncodingTests {
 		rv := reflect.New(reflect.TypeOf(tc.in))
-		err := unmarshalItem(tc.out, rv.Interface())
+		err := decoder{}.unmarshalItem(tc.out, rv.Interface())
 		if err != nil {
 			t.Errorf("%s: unexpected error: %v", tc.name, err)
 		}
@@ -94,6 +99,7 @@ func TestUnmarshalNULL(t *testing.T) {
 		"DoublePtr": &dynamodb.AttributeValue{NULL: &tru},
 		"Map":       &dynamodb.AttributeValue{NULL: &tru},
 		"Interface": &dynamodb.AttributeValue{NULL: &tru},
+		"StructPtr": &dynamodb.AttributeValue{NULL: &tru},
 	}
 
 	type resultType struct {
@@ -104,6 +110,7 @@ func TestUnmarshalNULL(t *testing.T) {
 		DoublePtr **int
 		Map       map[string]int
 		Interface interface{}
+		StructPtr *nestedInner
 	}
 
 	// dirty result, we want this to be reset
@@ -117,6 +124,7 @@ func TestUnmarshalNULL(t *testing.T) {
 			"A": 1,
 		},
 		Interface: "interface{}",
+		StructPtr: &nestedInner{Value: "dirty"},
 	}
 
 	if err := UnmarshalItem(item, &result); err != nil {
@@ -127,3 +135,249 @@ func TestUnmarshalNULL(t *testing.T) {
 		t.Error("unmarshal null: bad result:", result, "≠", resultType{})
 	}
 }
+
+func TestUnmarshalTimeEitherForm(t *testing.T) {
+	type timeItem struct {
+		Str  time.Time
+		Unix time.Time  `dynamo:",unixtime"`
+		Ptr  *time.Time `dynamo:",unixtime"`
+	}
+	want := time.Date(2019, 4, 1, 12, 30, 0, 0, time.UTC)
+	item := map[string]*dynamodb.AttributeValue{
+		// swapped from what would be encoded
+		"Str":  &dynamodb.AttributeValue{N: aws.String("1554121800")},
+		"Unix": &dynamodb.AttributeValue{S: aws.String("2019-04-01T12:30:00Z")},
+		"Ptr":  &dynamodb.AttributeValue{N: aws.String("1554121800")},
+	}
+	var got timeItem
+	if err := UnmarshalItem(item, &got); err != nil {
+		t.Fatal(err)
+	}
+	if !got.Str.Equal(want) || !got.Unix.Equal(want) || got.Ptr == nil || !got.Ptr.Equal(want) {
+		t.Errorf("bad result: %#v", got)
+	}
+}
+
+func TestUnmarshalTimeFormat(t *testing.T) {
+	type dayItem struct {
+		Day time.Time `dynamo:",format=2006-01-02"`
+	}
+	var got dayItem
+	err := UnmarshalItem(map[string]*dynamodb.AttributeValue{
+		"Day": {S: aws.String("2019-04-01T12:30:00Z")},
+	}, &got)
+	const want = `dynamo: cannot unmarshal "2019-04-01T12:30:00Z" into time.Time with format "2006-01-02": `
+	if err == nil || !strings.HasPrefix(err.Error(), want) {
+		t.Errorf("bad error. want: %s, got: %v", want, err)
+	}
+
+	// numbers are still read as unix time
+	if err := UnmarshalItem(map[string]*dynamodb.AttributeValue{
+		"Day": {N: aws.String("1554076800")},
+	}, &got); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+	if want := time.Date(2019, 4, 1, 0, 0, 0, 0, time.UTC); !got.Day.Equal(want) {
+		t.Error("bad time:", got.Day)
+	}
+}
+
+func TestUnmarshalMapKeyErrors(t *testing.T) {
+	var small map[int8]string
+	err := Unmarshal(&dynamodb.AttributeValue{M: map[string]*dynamodb.AttributeValue{
+		"300": {S: aws.String("too big")},
+	}}, &small)
+	if err == nil {
+		t.Error("expected error for out of range key")
+	}
+
+	var floats map[float64]string
+	err = Unmarshal(&dynamodb.AttributeValue{M: map[string]*dynamodb.AttributeValue{
+		"1.5": {S: aws.String("unsupported")},
+	}}, &floats)
+	if err == nil {
+		t.Error("expected error for float key")
+	}
+}
+
+func TestUnmarshalSetErrors(t *testing.T) {
+	var nums map[int]bool
+	err := Unmarshal(&dynamodb.AttributeValue{NS: []*string{aws.String("1"), aws.String("1.5")}}, &nums)
+	if err == nil {
+		t.Error("expected error unmarshaling non-integer NS into map[int]bool")
+	}
+
+	var bins map[[2]byte]struct{}
+	err = Unmarshal(&dynamodb.AttributeValue{BS: [][]byte{{'a', 'b', 'c'}}}, &bins)
+	if err == nil {
+		t.Error("expected error unmarshaling BS with wrong length into map[[2]byte]struct{}")
+	}
+
+	var ok map[[2]byte]struct{}
+	err = Unmarshal(&dynamodb.AttributeValue{BS: [][]byte{{'a', 'b'}}}, &ok)
+	if err != nil {
+		t.Error("unexpected error:", err)
+	}
+	if _, found := ok[[2]byte{'a', 'b'}]; !found || len(ok) != 1 {
+		t.Error("bad result:", ok)
+	}
+}
+
+func TestUnmarshalItemInterface(t *testing.T) {
+	item := map[string]*dynamodb.AttributeValue{
+		"S":    &dynamodb.AttributeValue{S: aws.String("hello")},
+		"N":    &dynamodb.AttributeValue{N: aws.String("42.5")},
+		"BOOL": &dynamodb.AttributeValue{BOOL: aws.Bool(true)},
+		"B":    &dynamodb.AttributeValue{B: []byte("hi")},
+		"SS":   &dynamodb.AttributeValue{SS: []*string{aws.String("a"), aws.String("b")}},
+		"NS":   &dynamodb.AttributeValue{NS: []*string{aws.String("1"), aws.String("2")}},
+		"L":    &dynamodb.AttributeValue{L: []*dynamodb.AttributeValue{{N: aws.String("1")}}},
+		"M":    &dynamodb.AttributeValue{M: map[string]*dynamodb.AttributeValue{"x": {S: aws.String("y")}}},
+		"NULL": &dynamodb.AttributeValue{NULL: aws.Bool(true)},
+	}
+	expected := map[string]interface{}{
+		"S":    "hello",
+		"N":    42.5,
+		"BOOL": true,
+		"B":    []byte("hi"),
+		"SS":   []string{"a", "b"},
+		"NS":   []float64{1, 2},
+		"L":    []interface{}{1.0},
+		"M":    map[string]interface{}{"x": "y"},
+		"NULL": nil,
+	}
+
+	var m map[string]interface{}
+	if err := UnmarshalItem(item, &m); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(m, expected) {
+		t.Errorf("bad result: %#v ≠ %#v", m, expected)
+	}
+
+	var iface interface{}
+	if err := UnmarshalItem(item, &iface); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(iface, expected) {
+		t.Errorf("bad result: %#v ≠ %#v", iface, expected)
+	}
+}
+
+func TestUnmarshalUnexportedEmbeddedPointer(t *testing.T) {
+	var out struct {
+		*embedded
+	}
+	item := map[string]*dynamodb.AttributeValue{
+		"Embedded": &dynamodb.AttributeValue{BOOL: aws.Bool(true)},
+	}
+	if err := UnmarshalItem(item, &out); err == nil {
+		t.Error("expected error")
+	}
+}
+
+func TestUnmarshalBig(t *testing.T) {
+	const n = "12345678901234567890123456789.0123456789"
+	item := map[string]*dynamodb.AttributeValue{
+		"Int":      {N: aws.String("123456789012345678901234567890123456789")},
+		"Float":    {N: aws.String(n)},
+		"Rat":      {N: aws.String(n)},
+		"FloatPtr": {N: aws.String("0.1")},
+		"Text":     {S: aws.String("99")},
+	}
+	var out struct {
+		Int      big.Int
+		Float    big.Float
+		Rat      *big.Rat
+		FloatPtr *big.Float
+		Text     *big.Int
+	}
+	if err := UnmarshalItem(item, &out); err != nil {
+		t.Fatal(err)
+	}
+	if got := out.Int.String(); got != *item["Int"].N {
+		t.Error("bad int:", got)
+	}
+	// parsed directly, not through float64
+	if got := out.Float.Text('f', 10); got != n {
+		t.Error("bad float:", got)
+	}
+	if want, _ := new(big.Rat).SetString(n); out.Rat.Cmp(want) != 0 {
+		t.Error("bad rat:", out.Rat)
+	}
+	if out.FloatPtr.Prec() != bigFloatPrec {
+		t.Error("bad float precision:", out.FloatPtr.Prec())
+	}
+	// numbers previously stored as text still work
+	if out.Text.Int64() != 99 {
+		t.Error("bad text int:", out.Text)
+	}
+
+	var bad big.Int
+	if err := Unmarshal(&dynamodb.AttributeValue{N: aws.String("1.5")}, &bad); err == nil {
+		t.Error("expected error decoding 1.5 into big.Int")
+	}
+}
+
+func TestUnmarshalJSONNumber(t *testing.T) {
+	const id = "1234567890123456789" // more than float64 can hold exactly
+	item := map[string]*dynamodb.AttributeValue{
+		"ID":   {N: aws.String(id)},
+		"IDs":  {NS: aws.StringSlice([]string{id})},
+		"Meta": {M: map[string]*dynamodb.AttributeValue{"n": {N: aws.String(id)}}},
+	}
+	var out struct {
+		ID   json.Number
+		IDs  []json.Number
+		Meta map[string]json.Number
+	}
+	if err := UnmarshalItem(item, &out); err != nil {
+		t.Fatal(err)
+	}
+	if out.ID != id {
+		t.Error("bad number:", out.ID)
+	}
+	if len(out.IDs) != 1 || out.IDs[0] != id {
+		t.Error("bad number set:", out.IDs)
+	}
+	if out.Meta["n"] != id {
+		t.Error("bad number map:", out.Meta)
+	}
+
+	// and back again
+	encoded, err := MarshalItem(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := encoded["ID"]; got.N == nil || *got.N != id {
+		t.Error("bad marshaled number:", got)
+	}
+}
+
+func TestUseNumber(t *testing.T) {
+	const id = "1234567890123456789" // more than float64 can hold exactly
+	client := newMockClient(1)
+	client.items[0]["ID"] = &dynamodb.AttributeValue{N: aws.String(id)}
+	client.items[0]["IDs"] = &dynamodb.AttributeValue{NS: aws.StringSlice([]string{id})}
+	db := NewFromIface(client)
+
+	var results []map[string]interface{}
+	if err := db.Table("Test").Scan().All(&results); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := results[0]["ID"].(float64); !ok {
+		t.Errorf("expected float64 by default, got %T", results[0]["ID"])
+	}
+
+	db.UseNumber(true)
+	results = nil
+	if err := db.Table("Test").Scan().All(&results); err != nil {
+		t.Fatal(err)
+	}
+	if got := results[0]["ID"]; got != json.Number(id) {
+		t.Errorf("bad number: %#v", got)
+	}
+	if got := results[0]["IDs"]; !reflect.DeepEqual(got, []json.Number{id}) {
+		t.Errorf("bad number set: %#v", got)
+	}
+}
@@ -1,7 +1,10 @@
 package dynamo
 
 import (
+	"time"
+
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
 	"github.com/aws/aws-sdk-go/service/dynamodb"
 )
 
@@ -18,10 +21,13 @@ type Delete struct {
 	rangeValue *dynamodb.AttributeValue
 
 	subber
-	condition string
+	condition    string
+	condFailItem bool
 
-	err error
-	cc  *ConsumedCapacity
+	err  error
+	cc   *ConsumedCapacity
+	opts []request.Option
+	icm  *ItemCollectionMetrics
 }
 
 // Delete creates a new request to delete an item.
@@ -32,7 +38,7 @@ func (table Table) Delete(name string, value interface{}) *Delete {
 		table:   table,
 		hashKey: name,
 	}
-	d.hashValue, d.err = marshal(value, "")
+	d.hashValue, d.err = marshalValue("hash key value", value, "")
 	return d
 }
 
@@ -42,7 +48,7 @@ func (table Table) Delete(name string, value interface{}) *Delete {
 func (d *Delete) Range(name string, value interface{}) *Delete {
 	var err error
 	d.rangeKey = name
-	d.rangeValue, err = marshal(value, "")
+	d.rangeValue, err = marshalValue("range key value", value, "")
 	d.setError(err)
 	return d
 }
@@ -63,12 +69,34 @@ func (d *Delete) If(expr string, args ...interface{}) *Delete {
 	return d
 }
 
+// IncludeItemInCondCheckFail specifies whether the item should be returned if the condition fails.
+// DynamoDB only supports this for deletes in a write transaction.
+// Use TxCondCheckFailed to get the item from the transaction's error.
+func (d *Delete) IncludeItemInCondCheckFail(enabled bool) *Delete {
+	d.condFailItem = enabled
+	return d
+}
+
 // ConsumedCapacity will measure the throughput capacity consumed by this operation and add it to cc.
 func (d *Delete) ConsumedCapacity(cc *ConsumedCapacity) *Delete {
 	d.cc = cc
 	return d
 }
 
+// RequestOptions adds options, such as custom request handlers, to every request made by this delete.
+// They are passed to the AWS SDK's WithContext methods.
+func (d *Delete) RequestOptions(opts ...request.Option) *Delete {
+	d.opts = append(d.opts, opts...)
+	return d
+}
+
+// ReturnItemCollectionMetrics will record the estimated size of the item collection affected by this operation in icm.
+// This is only returned for tables with local secondary indexes.
+func (d *Delete) ReturnItemCollectionMetrics(icm *ItemCollectionMetrics) *Delete {
+	d.icm = icm
+	return d
+}
+
 // Run executes this delete request.
 func (d *Delete) Run() error {
 	ctx, cancel := defaultContext()
@@ -76,6 +104,7 @@ func (d *Delete) Run() error {
 	return d.RunWithContext(ctx)
 }
 
+// RunWithContext executes this delete request.
 func (d *Delete) RunWithContext(ctx aws.Context) error {
 	d.returnType = "NONE"
 	_, err := d.run(ctx)
@@ -83,13 +112,15 @@ func (d *Delete) RunWithContext(ctx aws.Context) error {
 }
 
 // OldValue executes this delete request, unmarshaling the previous value to out.
-// Returns ErrNotFound is there was no previous value.
+// Returns ErrNotFound if there was no previous value.
 func (d *Delete) OldValue(out interface{}) error {
 	ctx, cancel := defaultContext()
 	defer cancel()
 	return d.OldValueWithContext(ctx, out)
 }
 
+// OldValueWithContext executes this delete request, unmarshaling the previous value to out.
+// Returns ErrNotFound if there was no previous value.
 func (d *Delete) OldValueWithContext(ctx aws.Context, out interface{}) error {
 	d.returnType = "ALL_OLD"
 	output, err := d.run(ctx)
@@ -99,7 +130,7 @@ func (d *Delete) OldValueWithContext(ctx aws.Context, out interface{}) error {
 	case output.Attributes == nil:
 		return ErrNotFound
 	}
-	return unmarshalItem(output.Attributes, out)
+	return d.table.db.decoder().unmarshalItem(output.Attributes, out)
 }
 
 func (d *Delete) run(ctx aws.Context) (*dynamodb.DeleteItemOutput, error) {
@@ -109,14 +140,19 @@ func (d *Delete) run(ctx aws.Context) (*dynamodb.DeleteItemOutput, error) {
 
 	input := d.deleteInput()
 	var output *dynamodb.DeleteItemOutput
-	err := retry(ctx, func() error {
+	err := d.table.db.retry(ctx, func() error {
 		var err error
-		output, err = d.table.db.client.DeleteItemWithContext(ctx, input)
+		start := time.Now()
+		output, err = d.table.db.client.DeleteItemWithContext(ctx, input, d.opts...)
+		d.table.db.observe(ctx, "DeleteItem", input, output, err, start)
 		return err
 	})
-	if d.cc != nil {
+	if d.cc != nil && output != nil {
 		addConsumedCapacity(d.cc, output.ConsumedCapacity)
 	}
+	if d.icm != nil && output != nil {
+		addItemCollectionMetrics(d.icm, d.table.name, output.ItemCollectionMetrics)
+	}
 	return output, err
 }
 
@@ -126,7 +162,7 @@ func (d *Delete) deleteInput() *dynamodb.DeleteItemInput {
 		Key:                       d.key(),
 		ReturnValues:              &d.returnType,
 		ExpressionAttributeNames:  d.nameExpr,
-		ExpressionAttributeValues: d.valueExpr,
+		ExpressionAttributeValues: d.table.db.emptyStringMode().values(d.valueExpr),
 	}
 	if d.condition != "" {
 		input.ConditionExpression = &d.condition
@@ -134,6 +170,9 @@ func (d *Delete) deleteInput() *dynamodb.DeleteItemInput {
 	if d.cc != nil {
 		input.ReturnConsumedCapacity = aws.String(dynamodb.ReturnConsumedCapacityIndexes)
 	}
+	if d.icm != nil {
+		input.ReturnItemCollectionMetrics = aws.String(dynamodb.ReturnItemCollectionMetricsSize)
+	}
 	return input
 }
 
@@ -151,6 +190,9 @@ func (d *Delete) writeTxItem() (*dynamodb.TransactWriteItem, error) {
 			ConditionExpression:       input.ConditionExpression,
 		},
 	}
+	if d.condFailItem {
+		item.Delete.ReturnValuesOnConditionCheckFailure = aws.String(dynamodb.ReturnValuesOnConditionCheckFailureAllOld)
+	}
 	return item, nil
 }
 
@@ -42,10 +42,12 @@ type Description struct {
 	LatestStreamLabel string
 }
 
+// Active returns true if this table is ready for use.
 func (d Description) Active() bool {
 	return d.Status == ActiveStatus
 }
 
+// Throughput is a table or index's provisioned throughput.
 type Throughput struct {
 	// Read capacity units.
 	Read int64
@@ -60,6 +62,7 @@ type Throughput struct {
 	DecsToday int64
 }
 
+// Index describes a global or local secondary index.
 type Index struct {
 	Name        string
 	ARN         string
@@ -89,7 +92,7 @@ type Index struct {
 
 func newDescription(table *dynamodb.TableDescription) Description {
 	desc := Description{
-		Name: *table.TableName,
+		Name: aws.StringValue(table.TableName),
 	}
 
 	if table.TableArn != nil {
@@ -123,9 +126,9 @@ func newDescription(table *dynamodb.TableDescription) Description {
 
 	for _, index := range table.GlobalSecondaryIndexes {
 		idx := Index{
-			Name:       *index.IndexName,
-			ARN:        *index.IndexArn,
-			Status:     Status(*index.IndexStatus),
+			Name:       aws.StringValue(index.IndexName),
+			ARN:        aws.StringValue(index.IndexArn),
+			Status:     Status(aws.StringValue(index.IndexStatus)),
 			Throughput: newThroughput(index.ProvisionedThroughput),
 		}
 		if index.Projection != nil && index.Projection.ProjectionType != nil {
@@ -148,8 +151,8 @@ func newDescription(table *dynamodb.TableDescription) Description {
 	}
 	for _, index := range table.LocalSecondaryIndexes {
 		idx := Index{
-			Name:       *index.IndexName,
-			ARN:        *index.IndexArn,
+			Name:       aws.StringValue(index.IndexName),
+			ARN:        aws.StringValue(index.IndexArn),
 			Status:     ActiveStatus, // local secondary index is always active (technically, it has no status)
 			Local:      true,
 			Throughput: desc.Throughput, // has the same throughput as the table
@@ -206,13 +209,16 @@ func (dt *DescribeTable) Run() (Description, error) {
 	return dt.RunWithContext(ctx)
 }
 
+// RunWithContext executes this request and describe the table.
 func (dt *DescribeTable) RunWithContext(ctx aws.Context) (Description, error) {
 	input := dt.input()
 
 	var result *dynamodb.DescribeTableOutput
-	err := retry(ctx, func() error {
+	err := dt.table.db.retry(ctx, func() error {
 		var err error
+		start := time.Now()
 		result, err = dt.table.db.client.DescribeTableWithContext(ctx, input)
+		dt.table.db.observe(ctx, "DescribeTable", input, result, err, start)
 		return err
 	})
 	if err != nil {
@@ -235,8 +241,8 @@ func newThroughput(td *dynamodb.ProvisionedThroughputDescription) Throughput {
 	}
 
 	thru := Throughput{
-		Read:  *td.ReadCapacityUnits,
-		Write: *td.WriteCapacityUnits,
+		Read:  aws.Int64Value(td.ReadCapacityUnits),
+		Write: aws.Int64Value(td.WriteCapacityUnits),
 	}
 	if td.LastIncreaseDateTime != nil {
 		thru.LastInc = *td.LastIncreaseDateTime
@@ -2,6 +2,9 @@ package dynamo
 
 import (
 	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
 )
 
 func TestDescribeTable(t *testing.T) {
@@ -23,3 +26,38 @@ func TestDescribeTable(t *testing.T) {
 		t.Error("bad keys:", desc.HashKey, desc.RangeKey)
 	}
 }
+
+func TestNewDescription(t *testing.T) {
+	// sparse descriptions (e.g. on-demand tables, DynamoDB Local) shouldn't panic
+	desc := newDescription(&dynamodb.TableDescription{
+		TableName:   aws.String("Test"),
+		TableStatus: aws.String(dynamodb.TableStatusActive),
+		ItemCount:   aws.Int64(42),
+		AttributeDefinitions: []*dynamodb.AttributeDefinition{
+			{AttributeName: aws.String("ID"), AttributeType: aws.String("S")},
+			{AttributeName: aws.String("Time"), AttributeType: aws.String("N")},
+		},
+		KeySchema: []*dynamodb.KeySchemaElement{
+			{AttributeName: aws.String("ID"), KeyType: aws.String(dynamodb.KeyTypeHash)},
+			{AttributeName: aws.String("Time"), KeyType: aws.String(dynamodb.KeyTypeRange)},
+		},
+		ProvisionedThroughput: &dynamodb.ProvisionedThroughputDescription{},
+		GlobalSecondaryIndexes: []*dynamodb.GlobalSecondaryIndexDescription{
+			{
+				IndexName: aws.String("Time-index"),
+				KeySchema: []*dynamodb.KeySchemaElement{
+					{AttributeName: aws.String("Time"), KeyType: aws.String(dynamodb.KeyTypeHash)},
+				},
+			},
+		},
+	})
+	if !desc.Active() || desc.Name != "Test" || desc.Items != 42 {
+		t.Error("bad description:", desc)
+	}
+	if desc.HashKey != "ID" || desc.HashKeyType != StringType || desc.RangeKey != "Time" || desc.RangeKeyType != NumberType {
+		t.Error("bad keys:", desc.HashKey, desc.HashKeyType, desc.RangeKey, desc.RangeKeyType)
+	}
+	if len(desc.GSI) != 1 || desc.GSI[0].Name != "Time-index" || desc.GSI[0].HashKeyType != NumberType {
+		t.Error("bad GSI:", desc.GSI)
+	}
+}
@@ -3,10 +3,13 @@ package dynamo
 import (
 	"bytes"
 	"encoding"
+	"encoding/json"
 	"fmt"
+	"math/big"
 	"reflect"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
@@ -20,13 +23,137 @@ type Marshaler interface {
 	MarshalDynamo() (*dynamodb.AttributeValue, error)
 }
 
+// EmptyStringMode is a policy for writing empty strings.
+type EmptyStringMode int
+
+// Empty string modes.
+const (
+	// EmptyStringKeep stores empty strings as they are. This is the default.
+	EmptyStringKeep EmptyStringMode = iota
+	// EmptyStringOmit leaves out attributes and map values that are empty strings,
+	// for tables written before DynamoDB supported them. Empty strings in lists are stored as NULL.
+	EmptyStringOmit
+	// EmptyStringNull stores empty strings as NULL.
+	EmptyStringNull
+)
+
+// SetEmptyStringMode changes how this DB sends empty strings in every request:
+// items given to Put and BatchWrite.Put, values given to Update methods like Set, Append, and SetExpr,
+// arguments to expressions like If and Filter, and PartiQL parameters. Empty strings are kept by default.
+// Update.Set removes a path instead of setting it to an omitted empty string. Other values in expressions
+// and PartiQL parameters can't be left out, so EmptyStringOmit sends them as NULL, like EmptyStringNull.
+// Keys can never be empty strings, and sets never contain them.
+// It should be called before making requests, as it is not safe to change concurrently.
+func (db *DB) SetEmptyStringMode(mode EmptyStringMode) {
+	db.emptyStrings = mode
+}
+
+// emptyStringMode returns the empty string mode of db, which may be nil.
+func (db *DB) emptyStringMode() EmptyStringMode {
+	if db == nil {
+		return EmptyStringKeep
+	}
+	return db.emptyStrings
+}
+
+// item applies this mode to the attributes of item, returning a new item if anything changed.
+func (mode EmptyStringMode) item(item map[string]*dynamodb.AttributeValue) map[string]*dynamodb.AttributeValue {
+	if mode == EmptyStringKeep || item == nil {
+		return item
+	}
+	out := make(map[string]*dynamodb.AttributeValue, len(item))
+	for name, av := range item {
+		if av = mode.value(av); av != nil {
+			out[name] = av
+		}
+	}
+	return out
+}
+
+// values applies this mode to expression attribute values, returning a new map if anything changed.
+// Values can't be omitted from an expression, so omitted empty strings become NULL.
+func (mode EmptyStringMode) values(vals map[string]*dynamodb.AttributeValue) map[string]*dynamodb.AttributeValue {
+	if mode == EmptyStringKeep || vals == nil {
+		return vals
+	}
+	out := make(map[string]*dynamodb.AttributeValue, len(vals))
+	for k, av := range vals {
+		out[k] = mode.arg(av)
+	}
+	return out
+}
+
+// args applies this mode to PartiQL parameters, returning a new slice if anything changed.
+func (mode EmptyStringMode) args(params []*dynamodb.AttributeValue) []*dynamodb.AttributeValue {
+	if mode == EmptyStringKeep || params == nil {
+		return params
+	}
+	out := make([]*dynamodb.AttributeValue, len(params))
+	for i, av := range params {
+		out[i] = mode.arg(av)
+	}
+	return out
+}
+
+// arg applies this mode to a value that can't be omitted, using NULL instead.
+func (mode EmptyStringMode) arg(av *dynamodb.AttributeValue) *dynamodb.AttributeValue {
+	if av = mode.value(av); av == nil {
+		return &dynamodb.AttributeValue{NULL: aws.Bool(true)}
+	}
+	return av
+}
+
+// value applies this mode to av and its contents, returning nil if av should be omitted.
+func (mode EmptyStringMode) value(av *dynamodb.AttributeValue) *dynamodb.AttributeValue {
+	switch {
+	case mode == EmptyStringKeep || av == nil:
+		return av
+	case av.S != nil && *av.S == "":
+		if mode == EmptyStringOmit {
+			return nil
+		}
+		return &dynamodb.AttributeValue{NULL: aws.Bool(true)}
+	case av.M != nil:
+		return &dynamodb.AttributeValue{M: mode.item(av.M)}
+	case av.L != nil:
+		list := make([]*dynamodb.AttributeValue, 0, len(av.L))
+		for _, v := range av.L {
+			if v = mode.value(v); v == nil {
+				// lists can't have holes
+				v = &dynamodb.AttributeValue{NULL: aws.Bool(true)}
+			}
+			list = append(list, v)
+		}
+		return &dynamodb.AttributeValue{L: list}
+	}
+	return av
+}
+
 // MarshalItem converts the given struct into a DynamoDB item.
+// It uses the same encoding rules as this package's requests,
+// which is useful for building requests with the raw client from DB.Client.
+// If v implements Marshaler, MarshalDynamo must return a map (M) value.
+// Fields of anonymous embedded structs and struct pointers are promoted to the top level
+// of the item, following Go's shadowing rules like encoding/json.
 func MarshalItem(v interface{}) (map[string]*dynamodb.AttributeValue, error) {
 	return marshalItem(v)
 }
 
 func marshalItem(v interface{}) (map[string]*dynamodb.AttributeValue, error) {
 	rv := reflect.ValueOf(v)
+	if rv.Kind() == reflect.Ptr && rv.IsNil() {
+		return nil, fmt.Errorf("dynamo: marshal item: nil %T", v)
+	}
+	if m, ok := v.(Marshaler); ok {
+		av, err := m.MarshalDynamo()
+		if err != nil {
+			return nil, err
+		}
+		if av == nil || av.M == nil {
+			return nil, fmt.Errorf("dynamo: marshal item: %T's MarshalDynamo must return a map (M) value", v)
+		}
+		return av.M, nil
+	}
 	switch rv.Type().Kind() {
 	case reflect.Ptr:
 		return marshalItem(rv.Elem().Interface())
@@ -50,57 +177,231 @@ func marshalMap(v interface{}) (map[string]*dynamodb.AttributeValue, error) {
 	return av.M, nil
 }
 
+// MarshalError is returned when a value can't be marshaled,
+// describing which value it was.
+type MarshalError struct {
+	// Value describes the value, such as "hash key value" or "field Name".
+	// Values nested in structs, lists, or maps are described by their path, like "field Items[3].Zip".
+	Value string
+	// Type is the Go type of the value that failed.
+	Type reflect.Type
+	// Err is the underlying error.
+	Err error
+
+	// path leads from the outermost value to the one that failed, like [".Items" "[3]" ".Zip"]
+	path []string
+}
+
+func (e *MarshalError) Error() string {
+	return fmt.Sprintf("dynamo: cannot marshal %s of type %v: %s", e.Value, e.Type, strings.TrimPrefix(e.Err.Error(), "dynamo: "))
+}
+
+// Unwrap returns the underlying error.
+func (e *MarshalError) Unwrap() error {
+	return e.Err
+}
+
+// marshalPathError returns err, from marshaling the value v found at elem of its parent,
+// such as "[3]" for a list element or ".Name" for a field or map value, with elem added to its path.
+func marshalPathError(err error, elem string, v interface{}) error {
+	merr, ok := err.(*MarshalError)
+	if !ok || merr.path == nil {
+		merr = &MarshalError{Type: reflect.TypeOf(v), Err: err}
+	}
+	merr.path = append([]string{elem}, merr.path...)
+	path := strings.Join(merr.path, "")
+	if strings.HasPrefix(path, ".") {
+		merr.Value = "field " + path[1:]
+	} else {
+		merr.Value = "value " + path
+	}
+	return merr
+}
+
+// marshalValue is like marshal, but describes v as what in any error.
+func marshalValue(what string, v interface{}, special string) (*dynamodb.AttributeValue, error) {
+	av, err := marshal(v, special)
+	if err != nil {
+		if merr, ok := err.(*MarshalError); ok && merr.path != nil {
+			// describe the nested value by its path from v
+			merr.Value = what + strings.Join(merr.path, "")
+			return nil, merr
+		}
+		return nil, &MarshalError{Value: what, Type: reflect.TypeOf(v), Err: err}
+	}
+	return av, nil
+}
+
 func marshalStruct(rv reflect.Value) (map[string]*dynamodb.AttributeValue, error) {
 	item := make(map[string]*dynamodb.AttributeValue)
-	var err error
+	for _, field := range structFields(rv.Type()) {
+		fv, ok := field.value(rv)
+		if !ok {
+			// promoted from a nil embedded pointer
+			continue
+		}
+		if field.omitempty && (isZero(fv) || isEmpty(fv)) {
+			continue
+		}
+
+		av, err := marshal(fv.Interface(), field.special)
+		if err != nil {
+			return nil, marshalPathError(err, "."+field.name, fv.Interface())
+		}
+		if av != nil {
+			item[field.name] = av
+		}
+	}
+	return item, nil
+}
 
-	for i := 0; i < rv.Type().NumField(); i++ {
-		field := rv.Type().Field(i)
-		fv := rv.Field(i)
+// structField is a field of a struct type, possibly promoted from an embedded struct.
+type structField struct {
+	name      string
+	special   string
+	omitempty bool
+	index     []int
+	tagged    bool
+	tag       reflect.StructTag
+}
 
-		name, special, omitempty := fieldInfo(field)
-		anonStruct := fv.Type().Kind() == reflect.Struct && field.Anonymous
-		switch {
-		case !fv.CanInterface():
-			if !anonStruct {
-				continue
+// value returns this field of rv, a struct.
+// ok is false if the field is promoted through a nil embedded pointer.
+func (f structField) value(rv reflect.Value) (fv reflect.Value, ok bool) {
+	for i, idx := range f.index {
+		if i > 0 {
+			if rv.Kind() == reflect.Ptr {
+				if rv.IsNil() {
+					return reflect.Value{}, false
+				}
+				rv = rv.Elem()
 			}
-		case name == "-":
-			continue
-		case omitempty:
-			if isZero(fv) {
-				continue
+		}
+		rv = rv.Field(idx)
+	}
+	return rv, true
+}
+
+// settable returns this field of rv, a struct, allocating embedded pointers along the way.
+func (f structField) settable(rv reflect.Value) (reflect.Value, error) {
+	for i, idx := range f.index {
+		if i > 0 {
+			if rv.Kind() == reflect.Ptr {
+				if rv.IsNil() {
+					if !rv.CanSet() {
+						return reflect.Value{}, fmt.Errorf("dynamo: cannot set embedded pointer to unexported struct %v", rv.Type().Elem())
+					}
+					rv.Set(reflect.New(rv.Type().Elem()))
+				}
+				rv = rv.Elem()
 			}
 		}
+		rv = rv.Field(idx)
+	}
+	return rv, nil
+}
 
-		// embed anonymous structs
-		if anonStruct {
-			avs, err := marshalStruct(fv)
-			if err != nil {
-				return nil, err
+var structFieldCache sync.Map // reflect.Type -> []structField
+
+// structFields returns the fields of the struct type t that are encoded,
+// including those promoted from embedded structs and pointers to structs.
+// Like encoding/json, and following Go's rules for promotion, a field hides
+// fields of the same name that are more deeply embedded. When several fields
+// of the same name are at the same depth, a tagged one wins, and if there
+// isn't exactly one, none of them are used.
+func structFields(t reflect.Type) []structField {
+	if fields, ok := structFieldCache.Load(t); ok {
+		return fields.([]structField)
+	}
+
+	type embed struct {
+		typ   reflect.Type
+		index []int
+	}
+	var fields []structField
+	taken := make(map[string]bool)
+	visited := make(map[reflect.Type]bool)
+	next := []embed{{typ: t}}
+	for len(next) > 0 {
+		current := next
+		next = nil
+
+		var found []structField
+		count := make(map[string]int)
+		tagged := make(map[string]int)
+		for _, e := range current {
+			if visited[e.typ] {
+				continue
 			}
-			for k, v := range avs {
-				// don't clobber pre-existing fields
-				if _, exists := item[k]; exists {
+			visited[e.typ] = true
+
+			for i := 0; i < e.typ.NumField(); i++ {
+				f := e.typ.Field(i)
+				if isSkipped(f) {
+					continue
+				}
+				index := make([]int, len(e.index)+1)
+				copy(index, e.index)
+				index[len(e.index)] = i
+
+				if f.Anonymous {
+					ft := f.Type
+					if ft.Kind() == reflect.Ptr {
+						ft = ft.Elem()
+					}
+					if ft.Kind() == reflect.Struct {
+						next = append(next, embed{typ: ft, index: index})
+						continue
+					}
+				}
+				if f.PkgPath != "" {
+					// unexported
+					continue
+				}
+
+				name, special, omitempty := fieldInfo(f)
+				if taken[name] {
+					// hidden by a shallower field
 					continue
 				}
-				item[k] = v
+				field := structField{
+					name:      name,
+					special:   special,
+					omitempty: omitempty,
+					index:     index,
+					tagged:    strings.Split(f.Tag.Get("dynamo"), ",")[0] != "",
+					tag:       f.Tag,
+				}
+				found = append(found, field)
+				count[name]++
+				if field.tagged {
+					tagged[name]++
+				}
 			}
-			continue
 		}
 
-		av, err := marshal(fv.Interface(), special)
-		if err != nil {
-			return nil, err
+		for _, field := range found {
+			if count[field.name] > 1 && (tagged[field.name] != 1 || !field.tagged) {
+				// ambiguous
+				continue
+			}
+			fields = append(fields, field)
 		}
-		if av != nil {
-			item[name] = av
+		for name := range count {
+			taken[name] = true
 		}
 	}
-	return item, err
+
+	actual, _ := structFieldCache.LoadOrStore(t, fields)
+	return actual.([]structField)
 }
 
-// Marshal converts the given value into a DynamoDB attribute value.
+// Marshal converts the given value into a DynamoDB attribute value,
+// using the same encoding rules as this package's requests.
+// Empty strings are kept, see DB.SetEmptyStringMode to change how they are written.
+// Values implementing encoding.TextMarshaler are stored as strings (S).
+// Nil pointers are omitted and return a nil attribute value,
+// unless the pointer type itself implements a marshaling interface.
 func Marshal(v interface{}) (*dynamodb.AttributeValue, error) {
 	return marshal(v, "")
 }
@@ -124,11 +425,39 @@ func marshal(v interface{}, special string) (*dynamodb.AttributeValue, error) {
 		}
 	}
 
+	if layout, ok := timeLayout(special); ok {
+		switch x := v.(type) {
+		case *time.Time:
+			if x != nil {
+				return marshal(*x, special)
+			}
+		case time.Time:
+			return &dynamodb.AttributeValue{S: aws.String(x.Format(layout))}, nil
+		}
+	}
+
 	rv := reflect.ValueOf(v)
 
 	switch x := v.(type) {
 	case *dynamodb.AttributeValue:
 		return x, nil
+	case json.Number:
+		if x == "" {
+			return nil, nil
+		}
+		return &dynamodb.AttributeValue{N: aws.String(string(x))}, nil
+	case big.Int:
+		return marshalBigInt(&x)
+	case *big.Int:
+		return marshalBigInt(x)
+	case big.Float:
+		return marshalBigFloat(&x)
+	case *big.Float:
+		return marshalBigFloat(x)
+	case big.Rat:
+		return marshalBigRat(&x)
+	case *big.Rat:
+		return marshalBigRat(x)
 	case Marshaler:
 		if rv.Kind() == reflect.Ptr && rv.IsNil() {
 			if _, ok := rv.Type().Elem().MethodByName("MarshalDynamo"); ok {
@@ -157,9 +486,6 @@ func marshal(v interface{}, special string) (*dynamodb.AttributeValue, error) {
 		if err != nil {
 			return nil, err
 		}
-		if len(text) == 0 {
-			return nil, nil
-		}
 		return &dynamodb.AttributeValue{S: aws.String(string(text))}, err
 	case nil:
 		return nil, nil
@@ -167,6 +493,54 @@ func marshal(v interface{}, special string) (*dynamodb.AttributeValue, error) {
 	return marshalReflect(rv, special)
 }
 
+func marshalBigInt(x *big.Int) (*dynamodb.AttributeValue, error) {
+	if x == nil {
+		return nil, nil
+	}
+	return &dynamodb.AttributeValue{N: aws.String(x.String())}, nil
+}
+
+func marshalBigFloat(x *big.Float) (*dynamodb.AttributeValue, error) {
+	if x == nil {
+		return nil, nil
+	}
+	if x.IsInf() {
+		return nil, fmt.Errorf("dynamo: marshal big.Float: can't store infinity")
+	}
+	return &dynamodb.AttributeValue{N: aws.String(x.Text('f', -1))}, nil
+}
+
+// marshalBigRat stores x as an exact decimal number,
+// returning an error if it has no finite decimal representation such as 1/3.
+func marshalBigRat(x *big.Rat) (*dynamodb.AttributeValue, error) {
+	if x == nil {
+		return nil, nil
+	}
+	// the decimal expansion of x terminates if its denominator only has factors of 2 and 5,
+	// after as many digits as the larger of their powers
+	denom := new(big.Int).Set(x.Denom())
+	var twos, fives int
+	two, five, mod := big.NewInt(2), big.NewInt(5), new(big.Int)
+	for denom.Cmp(bigOne) != 0 {
+		if mod.Mod(denom, two).Sign() == 0 {
+			denom.Quo(denom, two)
+			twos++
+		} else if mod.Mod(denom, five).Sign() == 0 {
+			denom.Quo(denom, five)
+			fives++
+		} else {
+			return nil, fmt.Errorf("dynamo: marshal big.Rat: %s has no exact decimal representation", x.String())
+		}
+	}
+	digits := twos
+	if fives > digits {
+		digits = fives
+	}
+	return &dynamodb.AttributeValue{N: aws.String(x.FloatString(digits))}, nil
+}
+
+var bigOne = big.NewInt(1)
+
 var nilTm encoding.TextMarshaler
 var tmType = reflect.TypeOf(&nilTm).Elem()
 
@@ -186,11 +560,7 @@ func marshalReflect(rv reflect.Value, special string) (*dynamodb.AttributeValue,
 	case reflect.Float32, reflect.Float64:
 		return &dynamodb.AttributeValue{N: aws.String(strconv.FormatFloat(rv.Float(), 'f', -1, 64))}, nil
 	case reflect.String:
-		s := rv.String()
-		if len(s) == 0 {
-			return nil, nil
-		}
-		return &dynamodb.AttributeValue{S: aws.String(s)}, nil
+		return &dynamodb.AttributeValue{S: aws.String(rv.String())}, nil
 	case reflect.Map:
 		if special == "set" {
 			// sets can't be empty
@@ -215,25 +585,37 @@ func marshalReflect(rv reflect.Value, special string) (*dynamodb.AttributeValue,
 				}
 				return string(txt), nil
 			}
-		} else if ktype.Kind() == reflect.String {
-			keyString = func(k reflect.Value) (string, error) {
-				return k.String(), nil
-			}
 		} else {
-			return nil, fmt.Errorf("dynamo marshal: map key must be string: %T", rv.Interface())
+			switch ktype.Kind() {
+			case reflect.String:
+				keyString = func(k reflect.Value) (string, error) {
+					return k.String(), nil
+				}
+			case reflect.Int, reflect.Int64, reflect.Int32, reflect.Int16, reflect.Int8:
+				keyString = func(k reflect.Value) (string, error) {
+					return strconv.FormatInt(k.Int(), 10), nil
+				}
+			case reflect.Uint, reflect.Uint64, reflect.Uint32, reflect.Uint16, reflect.Uint8:
+				keyString = func(k reflect.Value) (string, error) {
+					return strconv.FormatUint(k.Uint(), 10), nil
+				}
+			default:
+				return nil, fmt.Errorf("dynamo: marshal map: key must be a string, integer, or encoding.TextMarshaler: %T", rv.Interface())
+			}
 		}
 
 		avs := make(map[string]*dynamodb.AttributeValue)
 		for _, key := range rv.MapKeys() {
-			v, err := marshal(rv.MapIndex(key).Interface(), "")
+			kstr, err := keyString(key)
 			if err != nil {
 				return nil, err
 			}
+			elem := rv.MapIndex(key).Interface()
+			v, err := marshal(elem, "")
+			if err != nil {
+				return nil, marshalPathError(err, "."+kstr, elem)
+			}
 			if v != nil {
-				kstr, err := keyString(key)
-				if err != nil {
-					return nil, err
-				}
 				avs[kstr] = v
 			}
 		}
@@ -278,13 +660,13 @@ func marshalReflect(rv reflect.Value, special string) (*dynamodb.AttributeValue,
 			innerVal := rv.Index(i)
 			av, err := marshal(innerVal.Interface(), "")
 			if err != nil {
-				return nil, err
+				return nil, marshalPathError(err, fmt.Sprintf("[%d]", i), innerVal.Interface())
 			}
 			avs = append(avs, av)
 		}
 		return &dynamodb.AttributeValue{L: avs}, nil
 	default:
-		return nil, fmt.Errorf("dynamo marshal: unknown type %s", rv.Type().String())
+		return nil, fmt.Errorf("dynamo: marshal: unsupported type %s", rv.Type().String())
 	}
 }
 
@@ -414,25 +796,40 @@ func marshalSet(rv reflect.Value) (*dynamodb.AttributeValue, error) {
 		}
 	}
 
-	return nil, fmt.Errorf("dynamo marshal: unknown type for sets %s", rv.Type().String())
+	return nil, fmt.Errorf("dynamo: marshal: unsupported type for sets %s", rv.Type().String())
 }
 
 var emptyStructType = reflect.TypeOf(struct{}{})
 
-func marshalSlice(values []interface{}) ([]*dynamodb.AttributeValue, error) {
+// marshalKeySlice marshals key values, omitting those that are nil or empty strings.
+func marshalKeySlice(what string, values []interface{}) ([]*dynamodb.AttributeValue, error) {
 	avs := make([]*dynamodb.AttributeValue, 0, len(values))
 	for _, v := range values {
-		av, err := marshal(v, "")
+		av, err := marshalValue(what, v, "")
 		if err != nil {
 			return nil, err
 		}
-		if av != nil {
+		if !isEmptyKey(av) {
 			avs = append(avs, av)
 		}
 	}
 	return avs, nil
 }
 
+// isEmptyKey returns true if av can't be used as a key value: nil or an empty string.
+func isEmptyKey(av *dynamodb.AttributeValue) bool {
+	return av == nil || (av.S != nil && *av.S == "")
+}
+
+// timeLayout returns the time layout given by a format=layout tag option, if any.
+func timeLayout(special string) (layout string, ok bool) {
+	if !strings.HasPrefix(special, "format=") {
+		return "", false
+	}
+	layout = strings.TrimPrefix(special, "format=")
+	return layout, layout != ""
+}
+
 func fieldInfo(field reflect.StructField) (name, special string, omitempty bool) {
 	tags := strings.Split(field.Tag.Get("dynamo"), ",")
 	if len(tags) == 0 {
@@ -455,6 +852,54 @@ func fieldInfo(field reflect.StructField) (name, special string, omitempty bool)
 	return
 }
 
+// versionField finds the field of the struct v tagged with version, like `dynamo:"Version,version"`,
+// used for optimistic locking. Fields of embedded structs are searched too.
+// ok is false if there is no version field.
+func versionField(v interface{}) (name string, field reflect.Value, ok bool, err error) {
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Ptr {
+		if rv.IsNil() {
+			return "", reflect.Value{}, false, nil
+		}
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return "", reflect.Value{}, false, nil
+	}
+	for _, f := range structFields(rv.Type()) {
+		if f.special != "version" {
+			continue
+		}
+		fv, ok := f.value(rv)
+		if !ok {
+			continue
+		}
+		switch fv.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+			reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			return f.name, fv, true, nil
+		}
+		return "", reflect.Value{}, false, fmt.Errorf("dynamo: version field %s must be an integer, not %s", f.name, fv.Type())
+	}
+	return "", reflect.Value{}, false, nil
+}
+
+// isEmpty returns true for empty (but non-nil) maps and slices,
+// which are omitted by omitempty just like encoding/json.
+func isEmpty(rv reflect.Value) bool {
+	switch rv.Kind() {
+	case reflect.Map, reflect.Slice:
+		return rv.Len() == 0
+	}
+	return false
+}
+
+// isSkipped returns true if field is tagged with `dynamo:"-"`.
+// Like encoding/json, a field can be named "-" with `dynamo:"-,"`.
+func isSkipped(field reflect.StructField) bool {
+	return field.Tag.Get("dynamo") == "-"
+}
+
 type isZeroer interface {
 	IsZero() bool
 }
@@ -564,3 +1009,75 @@ func isNil(v interface{}) bool {
 	// non-pointers or special encoders with a pointer receiver
 	return false
 }
+
+// maxItemSize is the largest item DynamoDB accepts, 400KB.
+const maxItemSize = 400 * 1024
+
+// itemSize estimates the size of item in bytes as DynamoDB counts it:
+// the lengths of its attribute names plus the sizes of their values.
+// See: https://docs.aws.amazon.com/amazondynamodb/latest/developerguide/CapacityUnitCalculations.html
+func itemSize(item map[string]*dynamodb.AttributeValue) int {
+	size := 0
+	for name, av := range item {
+		size += len(name) + avSize(av)
+	}
+	return size
+}
+
+func avSize(av *dynamodb.AttributeValue) int {
+	switch {
+	case av == nil:
+		return 0
+	case av.S != nil:
+		return len(*av.S)
+	case av.N != nil:
+		return numberSize(*av.N)
+	case av.B != nil:
+		return len(av.B)
+	case av.BOOL != nil, av.NULL != nil:
+		return 1
+	case av.SS != nil:
+		size := 0
+		for _, s := range av.SS {
+			size += len(aws.StringValue(s))
+		}
+		return size
+	case av.NS != nil:
+		size := 0
+		for _, n := range av.NS {
+			size += numberSize(aws.StringValue(n))
+		}
+		return size
+	case av.BS != nil:
+		size := 0
+		for _, b := range av.BS {
+			size += len(b)
+		}
+		return size
+	case av.L != nil:
+		// 3 bytes of overhead, plus 1 byte per element
+		size := 3
+		for _, v := range av.L {
+			size += 1 + avSize(v)
+		}
+		return size
+	case av.M != nil:
+		size := 3
+		for name, v := range av.M {
+			size += 1 + len(name) + avSize(v)
+		}
+		return size
+	}
+	return 0
+}
+
+// numberSize approximates the size of a number: 1 byte per 2 significant digits, plus 1.
+func numberSize(n string) int {
+	if i := strings.IndexAny(n, "eE"); i >= 0 {
+		n = n[:i]
+	}
+	n = strings.TrimLeft(n, "+-")
+	n = strings.Replace(n, ".", "", 1)
+	n = strings.Trim(n, "0")
+	return (len(n)+1)/2 + 1
+}
@@ -1,8 +1,13 @@
 package dynamo
 
 import (
+	"errors"
+	"math/big"
 	"reflect"
 	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/service/dynamodb"
 )
 
 func TestMarshal(t *testing.T) {
@@ -30,3 +35,159 @@ func TestMarshalItem(t *testing.T) {
 		}
 	}
 }
+
+func TestMarshalItemOmitEmpty(t *testing.T) {
+	type omitted struct {
+		Str   string            `dynamo:",omitempty"`
+		Slice []int             `dynamo:",omitempty"`
+		Bytes []byte            `dynamo:",omitempty"`
+		Map   map[string]string `dynamo:",omitempty"`
+		Ptr   *int              `dynamo:",omitempty"`
+		Set   []string          `dynamo:",set,omitempty"`
+		Kept  []int
+	}
+	item, err := marshalItem(omitted{
+		Slice: []int{},
+		Bytes: []byte{},
+		Map:   map[string]string{},
+		Set:   []string{},
+		Kept:  []int{},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]*dynamodb.AttributeValue{
+		"Kept": &dynamodb.AttributeValue{L: []*dynamodb.AttributeValue{}},
+	}
+	if !reflect.DeepEqual(item, expected) {
+		t.Errorf("bad result: %#v ≠ %#v", item, expected)
+	}
+}
+
+func TestMarshalRoundTrip(t *testing.T) {
+	in := widget{
+		UserID: 613,
+		Time:   time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC),
+		Msg:    "hello",
+		Meta:   map[string]string{"color": "blue"},
+	}
+	item, err := MarshalItem(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out widget
+	if err := UnmarshalItem(item, &out); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(in, out) {
+		t.Errorf("bad item round trip. %#v ≠ %#v", in, out)
+	}
+
+	av, err := Marshal(in.Meta)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var meta map[string]string
+	if err := Unmarshal(av, &meta); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(in.Meta, meta) {
+		t.Errorf("bad value round trip. %#v ≠ %#v", in.Meta, meta)
+	}
+}
+
+func TestMarshalErrorContext(t *testing.T) {
+	err := Table{name: "Test"}.Get("ID", make(chan int)).Validate()
+	const want = "dynamo: cannot marshal hash key value of type chan int: marshal: unsupported type chan int"
+	if err == nil || err.Error() != want {
+		t.Errorf("bad error. want: %s, got: %v", want, err)
+	}
+	var merr *MarshalError
+	if !errors.As(err, &merr) || merr.Value != "hash key value" || merr.Type != reflect.TypeOf(make(chan int)) {
+		t.Error("expected a *MarshalError, got", err)
+	}
+
+	type inner struct {
+		Fn func()
+	}
+	type outer struct {
+		Inner inner
+	}
+	_, err = marshalItem(struct{ Outer outer }{Outer: outer{Inner: inner{Fn: func() {}}}})
+	const wantField = "dynamo: cannot marshal field Outer.Inner.Fn of type func(): marshal: unsupported type func()"
+	if err == nil || err.Error() != wantField {
+		t.Errorf("bad error. want: %s, got: %v", wantField, err)
+	}
+	if !errors.As(err, &merr) || merr.Value != "field Outer.Inner.Fn" || merr.Type != reflect.TypeOf(func() {}) {
+		t.Error("expected a *MarshalError with the field's path, got", err)
+	}
+
+	type address struct {
+		Zip interface{}
+	}
+	items := []address{{1}, {2}, {3}, {make(chan int)}}
+	_, err = marshalItem(struct{ Items []address }{Items: items})
+	const wantElem = "dynamo: cannot marshal field Items[3].Zip of type chan int: marshal: unsupported type chan int"
+	if err == nil || err.Error() != wantElem {
+		t.Errorf("bad error. want: %s, got: %v", wantElem, err)
+	}
+
+	_, err = marshalItem(map[string]interface{}{"Tags": map[string]interface{}{"bad": []interface{}{"ok", func() {}}}})
+	if !errors.As(err, &merr) || merr.Value != "field Tags.bad[1]" {
+		t.Error("expected a *MarshalError with the map value's path, got", err)
+	}
+
+	_, err = marshalValue("argument 1", items, "")
+	if !errors.As(err, &merr) || merr.Value != "argument 1[3].Zip" {
+		t.Error("expected a *MarshalError with the element's path, got", err)
+	}
+}
+
+func TestItemSize(t *testing.T) {
+	item, err := marshalItem(map[string]interface{}{
+		"S":    "hello",                  // 1 + 5
+		"N":    -123.4500,                // 1 + 4
+		"Bool": true,                     // 4 + 1
+		"L":    []int{1, 2},              // 1 + 3 + (1+2)*2
+		"M":    map[string]int{"a": 100}, // 1 + 3 + 1 + 1 + 2
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := itemSize(item), 6+5+5+10+8; got != want {
+		t.Errorf("bad item size. want: %d, got: %d", want, got)
+	}
+}
+
+func TestMarshalBig(t *testing.T) {
+	bigInt, _ := new(big.Int).SetString("123456789012345678901234567890123456789", 10)
+	bigFloat, _ := new(big.Float).SetPrec(128).SetString("12345678901234567890.123456789")
+	tests := []struct {
+		in  interface{}
+		out string
+	}{
+		{bigInt, "123456789012345678901234567890123456789"},
+		{*big.NewInt(-42), "-42"},
+		{bigFloat, "12345678901234567890.123456789"},
+		{big.NewRat(1, 8), "0.125"},
+		{big.NewRat(-7, 20), "-0.35"},
+		{big.NewRat(10, 1), "10"},
+	}
+	for _, test := range tests {
+		av, err := Marshal(test.in)
+		if err != nil {
+			t.Errorf("%v: unexpected error: %v", test.in, err)
+			continue
+		}
+		if av.N == nil || *av.N != test.out {
+			t.Errorf("%v: bad result: %v ≠ %s", test.in, av, test.out)
+		}
+	}
+
+	if _, err := Marshal(big.NewRat(1, 3)); err == nil {
+		t.Error("expected error for 1/3")
+	}
+	if av, err := Marshal((*big.Int)(nil)); av != nil || err != nil {
+		t.Error("expected nil *big.Int to be omitted, got:", av, err)
+	}
+}
@@ -80,3 +80,39 @@ func TestAWSIfaces(t *testing.T) {
 		t.Error("unmarshal not equal.", result, "≠", officialResult)
 	}
 }
+
+func TestAWSItems(t *testing.T) {
+	w := awsTestWidget{
+		UserID:    555,
+		Time:      time.Now().UTC(),
+		Msg:       "hello",
+		Friends:   []string{"a", "b"},
+		SecretKey: "seeeekret",
+	}
+	item, err := MarshalItem(AWSEncoding(w))
+	if err != nil {
+		t.Fatal(err)
+	}
+	official, err := dynamodbattribute.MarshalMap(w)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(item, official) {
+		t.Error("AWS marshal item not equal")
+	}
+
+	var blank awsTestWidget
+	if err := UnmarshalItem(official, AWSEncoding(&blank)); err != nil {
+		t.Fatal(err)
+	}
+	w.SecretKey = ""
+	if !reflect.DeepEqual(w, blank) {
+		t.Error("AWS unmarshal item not equal")
+		t.Logf("%#v != %#v", w, blank)
+	}
+
+	// Marshalers that don't produce maps can't be items
+	if _, err := MarshalItem(customMarshaler(1)); err == nil {
+		t.Error("expected error marshaling non-map item")
+	}
+}
@@ -2,6 +2,7 @@ package dynamo
 
 import (
 	"encoding"
+	"net"
 	"strconv"
 	"time"
 
@@ -66,6 +67,26 @@ var encodingTests = []struct {
 			"OK": &dynamodb.AttributeValue{BOOL: aws.Bool(true)},
 		}},
 	},
+	{
+		name: "maps with int keys",
+		in: map[int]string{
+			-1: "minus one",
+			42: "answer",
+		},
+		out: &dynamodb.AttributeValue{M: map[string]*dynamodb.AttributeValue{
+			"-1": &dynamodb.AttributeValue{S: aws.String("minus one")},
+			"42": &dynamodb.AttributeValue{S: aws.String("answer")},
+		}},
+	},
+	{
+		name: "maps with uint keys",
+		in: map[uint8]bool{
+			255: true,
+		},
+		out: &dynamodb.AttributeValue{M: map[string]*dynamodb.AttributeValue{
+			"255": &dynamodb.AttributeValue{BOOL: aws.Bool(true)},
+		}},
+	},
 	{
 		name: "empty maps",
 		in: struct {
@@ -178,6 +199,23 @@ var itemEncodingTests = []struct {
 			"A": &dynamodb.AttributeValue{S: aws.String("false")},
 		},
 	},
+	{
+		name: "TextMarshaler types",
+		in: struct {
+			IP   net.IP
+			Ptr  *ptrTextMarshaler
+			Text textMarshaler
+		}{
+			IP:   net.IPv4(192, 168, 0, 1),
+			Ptr:  new(ptrTextMarshaler),
+			Text: true,
+		},
+		out: map[string]*dynamodb.AttributeValue{
+			"IP":   &dynamodb.AttributeValue{S: aws.String("192.168.0.1")},
+			"Ptr":  &dynamodb.AttributeValue{S: aws.String("false")},
+			"Text": &dynamodb.AttributeValue{S: aws.String("true")},
+		},
+	},
 	{
 		name: "rename",
 		in: struct {
@@ -189,6 +227,20 @@ var itemEncodingTests = []struct {
 			"renamed": &dynamodb.AttributeValue{S: aws.String("hello")},
 		},
 	},
+	{
+		name: "field named -",
+		in: struct {
+			A     string `dynamo:"-,"`
+			Other bool
+		}{
+			A:     "hello",
+			Other: true,
+		},
+		out: map[string]*dynamodb.AttributeValue{
+			"-":     &dynamodb.AttributeValue{S: aws.String("hello")},
+			"Other": &dynamodb.AttributeValue{BOOL: aws.Bool(true)},
+		},
+	},
 	{
 		name: "skip",
 		in: struct {
@@ -235,8 +287,9 @@ var itemEncodingTests = []struct {
 			EmptyL: []int{},
 		},
 		out: map[string]*dynamodb.AttributeValue{
-			"OK":     &dynamodb.AttributeValue{S: aws.String("OK")},
-			"EmptyL": &dynamodb.AttributeValue{L: []*dynamodb.AttributeValue{}},
+			"OK":       &dynamodb.AttributeValue{S: aws.String("OK")},
+			"EmptyStr": &dynamodb.AttributeValue{S: aws.String("")},
+			"EmptyL":   &dynamodb.AttributeValue{L: []*dynamodb.AttributeValue{}},
 		},
 	},
 
@@ -265,6 +318,85 @@ var itemEncodingTests = []struct {
 			"Embedded": &dynamodb.AttributeValue{S: aws.String("OK")},
 		},
 	},
+	{
+		name: "embedded struct pointer",
+		in: struct {
+			*ExportedEmbedded
+			Extra string
+		}{
+			ExportedEmbedded: &ExportedEmbedded{Embedded: true},
+			Extra:            "hi",
+		},
+		out: map[string]*dynamodb.AttributeValue{
+			"Embedded": &dynamodb.AttributeValue{BOOL: aws.Bool(true)},
+			"Extra":    &dynamodb.AttributeValue{S: aws.String("hi")},
+		},
+	},
+	{
+		name: "nil embedded struct pointer",
+		in: struct {
+			*ExportedEmbedded
+			Extra string
+		}{
+			Extra: "hi",
+		},
+		out: map[string]*dynamodb.AttributeValue{
+			"Extra": &dynamodb.AttributeValue{S: aws.String("hi")},
+		},
+	},
+	{
+		name: "embedded struct shadowing",
+		in: struct {
+			deeplyEmbedded
+			embedded
+		}{
+			deeplyEmbedded: deeplyEmbedded{embedded: embedded{Embedded: false}},
+			embedded:       embedded{Embedded: true},
+		},
+		out: map[string]*dynamodb.AttributeValue{
+			"Embedded": &dynamodb.AttributeValue{BOOL: aws.Bool(true)},
+		},
+	},
+	{
+		name: "embedded struct conflict",
+		in: struct {
+			embedded
+			otherEmbedded
+			taggedEmbedded
+		}{
+			taggedEmbedded: taggedEmbedded{Tagged: "tag wins"},
+		},
+		out: map[string]*dynamodb.AttributeValue{
+			"Other": &dynamodb.AttributeValue{S: aws.String("tag wins")},
+		},
+	},
+	{
+		name: "nested struct pointers",
+		in: struct {
+			embedded
+			Outer *nestedOuter
+			Nil   *nestedOuter
+		}{
+			embedded: embedded{Embedded: true},
+			Outer: &nestedOuter{
+				Middle: &nestedMiddle{
+					embedded: embedded{Embedded: true},
+					Inner:    &nestedInner{Value: "deep"},
+				},
+			},
+		},
+		out: map[string]*dynamodb.AttributeValue{
+			"Embedded": &dynamodb.AttributeValue{BOOL: aws.Bool(true)},
+			"Outer": &dynamodb.AttributeValue{M: map[string]*dynamodb.AttributeValue{
+				"Middle": &dynamodb.AttributeValue{M: map[string]*dynamodb.AttributeValue{
+					"Embedded": &dynamodb.AttributeValue{BOOL: aws.Bool(true)},
+					"Inner": &dynamodb.AttributeValue{M: map[string]*dynamodb.AttributeValue{
+						"Value": &dynamodb.AttributeValue{S: aws.String("deep")},
+					}},
+				}},
+			}},
+		},
+	},
 	{
 		name: "sets",
 		in: struct {
@@ -425,12 +557,65 @@ var itemEncodingTests = []struct {
 		},
 		out: map[string]*dynamodb.AttributeValue{},
 	},
+	{
+		name: "time.Time (format encoding)",
+		in: struct {
+			Day time.Time `dynamo:",format=2006-01-02"`
+		}{
+			Day: time.Date(2019, 1, 2, 0, 0, 0, 0, time.UTC),
+		},
+		out: map[string]*dynamodb.AttributeValue{
+			"Day": &dynamodb.AttributeValue{S: aws.String("2019-01-02")},
+		},
+	},
+	{
+		name: "*time.Time (format encoding)",
+		in: struct {
+			Day *time.Time `dynamo:",format=20060102"`
+		}{
+			Day: aws.Time(time.Date(2019, 1, 2, 0, 0, 0, 0, time.UTC)),
+		},
+		out: map[string]*dynamodb.AttributeValue{
+			"Day": &dynamodb.AttributeValue{S: aws.String("20190102")},
+		},
+	},
 }
 
 type embedded struct {
 	Embedded bool
 }
 
+// ExportedEmbedded is exported so that it can be allocated when embedded as a pointer.
+type ExportedEmbedded struct {
+	Embedded bool
+}
+
+type deeplyEmbedded struct {
+	embedded
+}
+
+type otherEmbedded struct {
+	Embedded string
+	Other    string
+}
+
+type taggedEmbedded struct {
+	Tagged string `dynamo:"Other"`
+}
+
+type nestedOuter struct {
+	Middle *nestedMiddle
+}
+
+type nestedMiddle struct {
+	embedded
+	Inner *nestedInner
+}
+
+type nestedInner struct {
+	Value string
+}
+
 type customMarshaler int
 
 func (cm customMarshaler) MarshalDynamo() (*dynamodb.AttributeValue, error) {
@@ -1,12 +1,11 @@
 module github.com/guregu/dynamo
 
 require (
-	github.com/aws/aws-sdk-go v1.19.18
+	github.com/aws/aws-sdk-go v1.35.37
 	github.com/cenkalti/backoff v2.1.1+incompatible
 	github.com/davecgh/go-spew v1.1.1 // indirect
 	github.com/gofrs/uuid v3.2.0+incompatible
-	github.com/stretchr/testify v1.3.0 // indirect
-	golang.org/x/net v0.0.0-20190318221613-d196dffd7c2b
+	golang.org/x/net v0.0.0-20201110031124-69a78807bb2b
 )
 
-go 1.12
+go 1.13
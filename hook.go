@@ -0,0 +1,33 @@
+package dynamo
+
+import (
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+)
+
+// Hook is called after every request made to DynamoDB or DynamoDB Streams,
+// including each attempt of a retried request. It is useful for collecting metrics or tracing.
+// op is the name of the API operation, such as "Query" or "GetItem".
+// input is the request's input, such as *dynamodb.QueryInput, and output is its output,
+// which is nil if err is not nil. err is the error returned by the SDK, before it is
+// translated into errors like ConditionFailedError. d is how long the request took.
+type Hook func(ctx aws.Context, op string, input, output interface{}, err error, d time.Duration)
+
+// SetHook sets a function to be called after every request this DB makes.
+// Passing nil removes the hook.
+// It should be called before making requests, as it is not safe to change concurrently.
+func (db *DB) SetHook(hook Hook) {
+	db.hook = hook
+}
+
+// observe calls the hook, if any, for a request that began at start.
+func (db *DB) observe(ctx aws.Context, op string, input, output interface{}, err error, start time.Time) {
+	if db == nil || db.hook == nil {
+		return
+	}
+	if err != nil {
+		output = nil
+	}
+	db.hook(ctx, op, input, output, err, time.Since(start))
+}
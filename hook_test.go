@@ -0,0 +1,61 @@
+package dynamo
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+func TestSetHook(t *testing.T) {
+	mock := newMockClient(3)
+	mock.tables = []string{"A", "B", "C"}
+	db := NewFromIface(mock)
+
+	var ops []string
+	db.SetHook(func(ctx aws.Context, op string, input, output interface{}, err error, d time.Duration) {
+		if err != nil {
+			t.Error("unexpected error:", err)
+		}
+		if d < 0 {
+			t.Error("negative duration:", d)
+		}
+		switch op {
+		case "ListTables":
+			if _, ok := input.(*dynamodb.ListTablesInput); !ok {
+				t.Errorf("bad input type: %T", input)
+			}
+			if _, ok := output.(*dynamodb.ListTablesOutput); !ok {
+				t.Errorf("bad output type: %T", output)
+			}
+		case "Query":
+			if _, ok := input.(*dynamodb.QueryInput); !ok {
+				t.Errorf("bad input type: %T", input)
+			}
+		}
+		ops = append(ops, op)
+	})
+
+	if _, err := db.ListTables().All(); err != nil {
+		t.Fatal(err)
+	}
+	var items []map[string]interface{}
+	if err := db.Table("Test").Get("Idx", 0).All(&items); err != nil {
+		t.Fatal(err)
+	}
+
+	expect := []string{"ListTables", "ListTables", "Query"}
+	if !reflect.DeepEqual(ops, expect) {
+		t.Errorf("bad ops. %v ≠ %v", ops, expect)
+	}
+
+	db.SetHook(nil)
+	if _, err := db.ListTables().All(); err != nil {
+		t.Fatal(err)
+	}
+	if len(ops) != len(expect) {
+		t.Error("hook called after removal")
+	}
+}
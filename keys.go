@@ -1,5 +1,13 @@
 package dynamo
 
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
 // KeyType is used to specify the type of hash and range keys for tables and indexes.
 type KeyType string
 
@@ -12,6 +20,10 @@ const (
 )
 
 // Keyed provides hash key and range key values.
+// Implement it on your own types to pass them directly to Batch.Get and BatchWrite.Delete:
+//	func (u User) HashKey() interface{}  { return u.ID }
+//	func (u User) RangeKey() interface{} { return u.Created }
+// Types with only a hash key can return nil from RangeKey.
 type Keyed interface {
 	HashKey() interface{}
 	RangeKey() interface{}
@@ -28,3 +40,135 @@ func (k Keys) HashKey() interface{} { return k[0] }
 
 // RangeKey returns the range key's value.
 func (k Keys) RangeKey() interface{} { return k[1] }
+
+// primaryKey is a primary key, extracted from a struct.
+type primaryKey struct {
+	hashKey    string
+	hashValue  *dynamodb.AttributeValue
+	rangeKey   string
+	rangeValue *dynamodb.AttributeValue
+}
+
+// structKeys finds the fields of the struct v tagged with hash and range, and marshals their values.
+func structKeys(v interface{}) (primaryKey, error) {
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Ptr && !rv.IsNil() {
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return primaryKey{}, fmt.Errorf("dynamo: key must be a struct, not %T", v)
+	}
+
+	var key primaryKey
+	if err := key.fill(rv); err != nil {
+		return primaryKey{}, err
+	}
+	switch {
+	case key.hashKey == "":
+		return primaryKey{}, fmt.Errorf("dynamo: key %T has no field tagged with hash", v)
+	case isEmptyKey(key.hashValue):
+		return primaryKey{}, fmt.Errorf("dynamo: key %T has an empty hash key %s", v, key.hashKey)
+	case key.rangeKey != "" && isEmptyKey(key.rangeValue):
+		return primaryKey{}, fmt.Errorf("dynamo: key %T has an empty range key %s", v, key.rangeKey)
+	}
+	return key, nil
+}
+
+func (key *primaryKey) fill(rv reflect.Value) error {
+	for _, field := range structFields(rv.Type()) {
+		keyType := keyTypeFromTag(field.tag.Get("dynamo"))
+		if keyType == "" {
+			continue
+		}
+		fv, ok := field.value(rv)
+		if !ok {
+			// promoted from a nil embedded pointer
+			continue
+		}
+		av, err := marshalValue("key field "+field.name, fv.Interface(), field.special)
+		if err != nil {
+			return err
+		}
+		switch {
+		case keyType == dynamodb.KeyTypeHash && key.hashKey == "":
+			key.hashKey, key.hashValue = field.name, av
+		case keyType == dynamodb.KeyTypeRange && key.rangeKey == "":
+			key.rangeKey, key.rangeValue = field.name, av
+		}
+	}
+	return nil
+}
+
+// LastKeyOf returns the key of item for use with StartFrom, so that a query or scan
+// can resume after the last item it processed, without storing its paging key.
+// Item must be a struct, or a pointer to one, with its key fields tagged the same way as for CreateTable.
+// The table's hash key and range key are always included.
+// When resuming a query or scan of an index, also give the index's name to include its keys,
+// found from the index or localIndex struct tags:
+//
+//	type Post struct {
+//		UserID string `dynamo:",hash" index:"Seq-ID-index,range"`
+//		Seq    int64  `dynamo:",range" index:"Seq-ID-index,hash"`
+//	}
+//	key, err := table.LastKeyOf(lastPost, "Seq-ID-index")
+//	err = table.Get("Seq", 1).Index("Seq-ID-index").StartFrom(key).All(&posts)
+func (table Table) LastKeyOf(item interface{}, index ...string) (PagingKey, error) {
+	if len(index) > 1 {
+		return nil, fmt.Errorf("dynamo: last key: only one index can be given, got %d", len(index))
+	}
+	pk, err := structKeys(item)
+	if err != nil {
+		return nil, err
+	}
+	key := PagingKey{pk.hashKey: pk.hashValue}
+	if pk.rangeKey != "" {
+		key[pk.rangeKey] = pk.rangeValue
+	}
+	if len(index) == 0 || index[0] == "" {
+		return key, nil
+	}
+
+	rv := reflect.ValueOf(item)
+	for rv.Kind() == reflect.Ptr {
+		rv = rv.Elem()
+	}
+	found, err := indexKeys(rv, index[0], key)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, fmt.Errorf("dynamo: last key: %T has no fields tagged with index %s", item, index[0])
+	}
+	return key, nil
+}
+
+// indexKeys adds the fields of the struct rv that are keys of the given index to key.
+func indexKeys(rv reflect.Value, index string, key PagingKey) (found bool, err error) {
+	for _, field := range structFields(rv.Type()) {
+		var tags []string
+		for _, name := range []string{"index", "localIndex"} {
+			if values, ok := tagLookup(string(field.tag), name); ok {
+				tags = append(tags, values...)
+			}
+		}
+		for _, tag := range tags {
+			if keyTypeFromTag(tag) == "" || strings.Split(tag, ",")[0] != index {
+				continue
+			}
+			fv, ok := field.value(rv)
+			if !ok {
+				return false, fmt.Errorf("dynamo: last key: index key %s is in a nil embedded struct", field.name)
+			}
+			av, err := marshalValue("index key field "+field.name, fv.Interface(), field.special)
+			if err != nil {
+				return false, err
+			}
+			if isEmptyKey(av) {
+				return false, fmt.Errorf("dynamo: last key: empty index key %s", field.name)
+			}
+			key[field.name] = av
+			found = true
+		}
+	}
+	return found, nil
+}
@@ -0,0 +1,215 @@
+package dynamo
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+// PartiQL is a request to run a PartiQL statement.
+// It uses the ExecuteStatement API.
+// See: https://docs.aws.amazon.com/amazondynamodb/latest/developerguide/ql-reference.html
+type PartiQL struct {
+	db         *DB
+	statement  string
+	params     []*dynamodb.AttributeValue
+	consistent bool
+
+	err  error
+	opts []request.Option
+}
+
+// Exec creates a new request to run the given PartiQL statement.
+// Each ? placeholder in statement is substituted with the corresponding arg, marshaled like any other value.
+// Nil args, and others that marshal to nothing such as nil pointers, are substituted as NULL.
+//
+//	err := db.Exec(`SELECT * FROM "Users" WHERE ID = ?`, id).One(&user)
+func (db *DB) Exec(statement string, args ...interface{}) *PartiQL {
+	p := &PartiQL{
+		db:        db,
+		statement: statement,
+	}
+	for i, arg := range args {
+		av, err := marshalValue(fmt.Sprintf("argument %d", i+1), arg, "")
+		p.setError(err)
+		if av == nil {
+			av = &dynamodb.AttributeValue{NULL: aws.Bool(true)}
+		}
+		p.params = append(p.params, av)
+	}
+	return p
+}
+
+// Consistent will, if on is true, make this statement use a strongly consistent read.
+// Statements are eventually consistent by default.
+func (p *PartiQL) Consistent(on bool) *PartiQL {
+	p.consistent = on
+	return p
+}
+
+// RequestOptions adds options, such as custom request handlers, to every request made by this statement.
+// They are passed to the AWS SDK's WithContext methods.
+func (p *PartiQL) RequestOptions(opts ...request.Option) *PartiQL {
+	p.opts = append(p.opts, opts...)
+	return p
+}
+
+// Run executes this statement, ignoring any results.
+// Use it for INSERT, UPDATE, and DELETE statements.
+func (p *PartiQL) Run() error {
+	ctx, cancel := defaultContext()
+	defer cancel()
+	return p.RunWithContext(ctx)
+}
+
+// RunWithContext executes this statement, ignoring any results.
+func (p *PartiQL) RunWithContext(ctx aws.Context) error {
+	if p.err != nil {
+		return p.err
+	}
+	_, err := p.run(ctx, p.input())
+	return err
+}
+
+// One executes this statement and unmarshals its single result to out.
+// It returns ErrNotFound if there are no results and ErrTooMany if there are more than one.
+func (p *PartiQL) One(out interface{}) error {
+	ctx, cancel := defaultContext()
+	defer cancel()
+	return p.OneWithContext(ctx, out)
+}
+
+// OneWithContext executes this statement and unmarshals its single result to out.
+// It returns ErrNotFound if there are no results and ErrTooMany if there are more than one.
+func (p *PartiQL) OneWithContext(ctx aws.Context, out interface{}) error {
+	var item map[string]*dynamodb.AttributeValue
+	itr := p.Iter()
+	if !itr.NextWithContext(ctx, &item) {
+		if err := itr.Err(); err != nil {
+			return err
+		}
+		return ErrNotFound
+	}
+	var extra map[string]*dynamodb.AttributeValue
+	if itr.NextWithContext(ctx, &extra) {
+		return ErrTooMany
+	}
+	if err := itr.Err(); err != nil {
+		return err
+	}
+	return p.db.decoder().unmarshalItem(item, out)
+}
+
+// All executes this statement and unmarshals all results to out, which must be a pointer to a slice.
+// Pages of results are requested until there are no more.
+func (p *PartiQL) All(out interface{}) error {
+	ctx, cancel := defaultContext()
+	defer cancel()
+	return p.AllWithContext(ctx, out)
+}
+
+// AllWithContext executes this statement and unmarshals all results to out, which must be a pointer to a slice.
+// If ctx is done, no more pages are requested and ctx.Err() is returned.
+func (p *PartiQL) AllWithContext(ctx aws.Context, out interface{}) error {
+	itr := &pqIter{pq: p, unmarshal: p.db.decoder().unmarshalAppend, err: p.err}
+	for itr.NextWithContext(ctx, out) {
+	}
+	return itr.Err()
+}
+
+// Iter returns a results iterator for this statement.
+// Results are fetched lazily one page at a time as the iterator advances.
+func (p *PartiQL) Iter() Iter {
+	return &pqIter{pq: p, unmarshal: p.db.decoder().unmarshalItem, err: p.err}
+}
+
+func (p *PartiQL) run(ctx aws.Context, input *dynamodb.ExecuteStatementInput) (*dynamodb.ExecuteStatementOutput, error) {
+	var out *dynamodb.ExecuteStatementOutput
+	err := p.db.retry(ctx, func() error {
+		var err error
+		start := time.Now()
+		out, err = p.db.client.ExecuteStatementWithContext(ctx, input, p.opts...)
+		p.db.observe(ctx, "ExecuteStatement", input, out, err, start)
+		return err
+	})
+	return out, err
+}
+
+func (p *PartiQL) input() *dynamodb.ExecuteStatementInput {
+	input := &dynamodb.ExecuteStatementInput{
+		Statement:  aws.String(p.statement),
+		Parameters: p.db.emptyStringMode().args(p.params),
+	}
+	if p.consistent {
+		input.ConsistentRead = aws.Bool(true)
+	}
+	return input
+}
+
+func (p *PartiQL) setError(err error) {
+	if p.err == nil {
+		p.err = err
+	}
+}
+
+// pqIter is the iterator for PartiQL statements.
+type pqIter struct {
+	pq     *PartiQL
+	input  *dynamodb.ExecuteStatementInput
+	output *dynamodb.ExecuteStatementOutput
+	idx    int
+	err    error
+
+	unmarshal unmarshalFunc
+}
+
+// Next tries to unmarshal the next result into out.
+// Returns false when it is complete or if it runs into an error.
+func (itr *pqIter) Next(out interface{}) bool {
+	ctx, cancel := defaultContext()
+	defer cancel()
+	return itr.NextWithContext(ctx, out)
+}
+
+// NextWithContext tries to unmarshal the next result into out.
+// Returns false when it is complete or if it runs into an error.
+func (itr *pqIter) NextWithContext(ctx aws.Context, out interface{}) bool {
+	if itr.err != nil {
+		return false
+	}
+
+	for itr.output == nil || itr.idx >= len(itr.output.Items) {
+		if itr.output != nil && itr.output.NextToken == nil {
+			return false
+		}
+		if itr.err = ctx.Err(); itr.err != nil {
+			return false
+		}
+
+		if itr.input == nil {
+			itr.input = itr.pq.input()
+		}
+		if itr.output != nil {
+			itr.input.NextToken = itr.output.NextToken
+		}
+		itr.output, itr.err = itr.pq.run(ctx, itr.input)
+		if itr.err != nil {
+			return false
+		}
+		itr.idx = 0
+	}
+
+	item := itr.output.Items[itr.idx]
+	itr.idx++
+	itr.err = itr.unmarshal(item, out)
+	return itr.err == nil
+}
+
+// Err returns the error encountered, if any.
+// You should check this after Next is finished.
+func (itr *pqIter) Err() error {
+	return itr.err
+}
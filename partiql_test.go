@@ -0,0 +1,91 @@
+package dynamo
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+func TestPartiQL(t *testing.T) {
+	type item struct {
+		Idx int
+	}
+
+	t.Run("all", func(t *testing.T) {
+		mock := newMockClient(5)
+		db := NewFromIface(mock)
+		var items []item
+		if err := db.Exec(`SELECT * FROM "Test"`).All(&items); err != nil {
+			t.Fatal(err)
+		}
+		if len(items) != 5 {
+			t.Fatalf("wrong number of items. want: 5, got: %d", len(items))
+		}
+		for i, it := range items {
+			if it.Idx != i {
+				t.Errorf("bad item at %d: %+v", i, it)
+			}
+		}
+	})
+
+	t.Run("one", func(t *testing.T) {
+		mock := newMockClient(5)
+		db := NewFromIface(mock)
+		var it item
+		err := db.Exec(`SELECT * FROM "Test" WHERE Idx = ? AND Msg = ?`, 3, "hello").One(&it)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if it.Idx != 3 {
+			t.Error("bad item:", it)
+		}
+		want := []*dynamodb.AttributeValue{
+			{N: aws.String("3")},
+			{S: aws.String("hello")},
+		}
+		if !reflect.DeepEqual(mock.params, want) {
+			t.Error("bad params. want:", want, "got:", mock.params)
+		}
+
+		if err := db.Exec(`SELECT * FROM "Test" WHERE Idx = ?`, 42).One(&it); err != ErrNotFound {
+			t.Error("expected ErrNotFound, got:", err)
+		}
+		if err := db.Exec(`SELECT * FROM "Test"`).One(&it); err != ErrTooMany {
+			t.Error("expected ErrTooMany, got:", err)
+		}
+	})
+
+	t.Run("null args", func(t *testing.T) {
+		mock := newMockClient(0)
+		db := NewFromIface(mock)
+		if err := db.Exec(`INSERT INTO "Test" VALUE {'Idx': ?, 'Msg': ?}`, "x", nil).Run(); err != nil {
+			t.Fatal(err)
+		}
+		want := []*dynamodb.AttributeValue{
+			{S: aws.String("x")},
+			{NULL: aws.Bool(true)},
+		}
+		if !reflect.DeepEqual(mock.params, want) {
+			t.Error("bad params. want:", want, "got:", mock.params)
+		}
+	})
+
+	t.Run("empty string mode", func(t *testing.T) {
+		mock := newMockClient(0)
+		db := NewFromIface(mock)
+		db.SetEmptyStringMode(EmptyStringOmit)
+		if err := db.Exec(`INSERT INTO "Test" VALUE {'Idx': ?, 'Msg': ?}`, "x", "").Run(); err != nil {
+			t.Fatal(err)
+		}
+		// parameters can't be omitted
+		want := []*dynamodb.AttributeValue{
+			{S: aws.String("x")},
+			{NULL: aws.Bool(true)},
+		}
+		if !reflect.DeepEqual(mock.params, want) {
+			t.Error("bad params. want:", want, "got:", mock.params)
+		}
+	})
+}
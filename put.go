@@ -1,7 +1,13 @@
 package dynamo
 
 import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"time"
+
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
 	"github.com/aws/aws-sdk-go/service/dynamodb"
 )
 
@@ -12,21 +18,94 @@ type Put struct {
 	returnType string
 
 	item map[string]*dynamodb.AttributeValue
+	src  interface{}
 	subber
-	condition string
+	condition    string
+	condFailItem bool
+
+	// for optimistic locking
+	version     reflect.Value
+	nextVersion int64
 
-	err error
-	cc  *ConsumedCapacity
+	err  error
+	cc   *ConsumedCapacity
+	opts []request.Option
+	icm  *ItemCollectionMetrics
 }
 
 // Put creates a new request to create or replace an item.
+// If the item is larger than DynamoDB's 400KB limit, running the request returns an *ItemTooLargeError
+// without sending it.
+//
+// If item is a struct with an integer field tagged with version, like `dynamo:"Version,version"`,
+// optimistic locking is used: the version stored is one more than the field's current value,
+// and the put only succeeds if its stored version equals the field's current value,
+// or, if the field is zero, if the item doesn't exist yet.
+// Otherwise, the error will match ErrConditionFailed with errors.Is. After a successful Run,
+// the field is updated to the new version if item is a pointer.
 func (table Table) Put(item interface{}) *Put {
 	encoded, err := marshalItem(item)
-	return &Put{
+	p := &Put{
 		table: table,
-		item:  encoded,
+		item:  table.db.emptyStringMode().item(encoded),
+		src:   item,
 		err:   err,
 	}
+	if err == nil {
+		p.lockVersion(item)
+		p.setError(checkItemSize(p.item))
+	}
+	return p
+}
+
+// ItemTooLargeError is returned before sending a request to put an item larger than DynamoDB's 400KB limit.
+// Use errors.Is(err, ErrItemTooLarge) to check for it.
+type ItemTooLargeError struct {
+	// Size is the estimated size of the item in bytes.
+	Size int
+}
+
+func (e *ItemTooLargeError) Error() string {
+	return fmt.Sprintf("%v: %d bytes exceeds the limit of %d bytes", ErrItemTooLarge, e.Size, maxItemSize)
+}
+
+// Is returns true if target is ErrItemTooLarge.
+func (e *ItemTooLargeError) Is(target error) bool {
+	return target == ErrItemTooLarge
+}
+
+func checkItemSize(item map[string]*dynamodb.AttributeValue) error {
+	if size := itemSize(item); size > maxItemSize {
+		return &ItemTooLargeError{Size: size}
+	}
+	return nil
+}
+
+// lockVersion sets up optimistic locking if item has a version field.
+func (p *Put) lockVersion(item interface{}) {
+	name, field, ok, err := versionField(item)
+	if err != nil || !ok {
+		p.setError(err)
+		return
+	}
+
+	var current int64
+	if field.Kind() >= reflect.Uint && field.Kind() <= reflect.Uint64 {
+		current = int64(field.Uint())
+	} else {
+		current = field.Int()
+	}
+	p.nextVersion = current + 1
+	p.item[name] = &dynamodb.AttributeValue{N: aws.String(strconv.FormatInt(p.nextVersion, 10))}
+	if field.CanSet() {
+		p.version = field
+	}
+	if current == 0 {
+		// a new item
+		p.If("attribute_not_exists($)", name)
+		return
+	}
+	p.If("$ = ?", name, current)
 }
 
 // If specifies a conditional expression for this put to succeed.
@@ -45,12 +124,47 @@ func (p *Put) If(expr string, args ...interface{}) *Put {
 	return p
 }
 
+// IfNotExists adds a condition that this put only succeeds if the item doesn't already exist,
+// using the hash key of the item, which must be a struct with a field tagged as the hash key
+// like with CreateTable, such as `dynamo:"ID,hash"`. It is combined with other conditions using AND.
+// If the item exists, the error will match ErrConditionFailed with errors.Is.
+func (p *Put) IfNotExists() *Put {
+	keys, err := structKeys(p.src)
+	if err != nil {
+		p.setError(err)
+		return p
+	}
+	return p.If("attribute_not_exists($)", keys.hashKey)
+}
+
+// IncludeItemInCondCheckFail specifies whether the item should be returned if the condition fails.
+// DynamoDB only supports this for puts in a write transaction.
+// Use TxCondCheckFailed to get the item from the transaction's error.
+func (p *Put) IncludeItemInCondCheckFail(enabled bool) *Put {
+	p.condFailItem = enabled
+	return p
+}
+
 // ConsumedCapacity will measure the throughput capacity consumed by this operation and add it to cc.
 func (p *Put) ConsumedCapacity(cc *ConsumedCapacity) *Put {
 	p.cc = cc
 	return p
 }
 
+// RequestOptions adds options, such as custom request handlers, to every request made by this put.
+// They are passed to the AWS SDK's WithContext methods.
+func (p *Put) RequestOptions(opts ...request.Option) *Put {
+	p.opts = append(p.opts, opts...)
+	return p
+}
+
+// ReturnItemCollectionMetrics will record the estimated size of the item collection affected by this operation in icm.
+// This is only returned for tables with local secondary indexes.
+func (p *Put) ReturnItemCollectionMetrics(icm *ItemCollectionMetrics) *Put {
+	p.icm = icm
+	return p
+}
+
 // Run executes this put.
 func (p *Put) Run() error {
 	ctx, cancel := defaultContext()
@@ -58,7 +172,7 @@ func (p *Put) Run() error {
 	return p.RunWithContext(ctx)
 }
 
-// Run executes this put.
+// RunWithContext executes this put.
 func (p *Put) RunWithContext(ctx aws.Context) error {
 	p.returnType = "NONE"
 	_, err := p.run(ctx)
@@ -84,7 +198,7 @@ func (p *Put) OldValueWithContext(ctx aws.Context, out interface{}) error {
 	case output.Attributes == nil:
 		return ErrNotFound
 	}
-	return unmarshalItem(output.Attributes, out)
+	return p.table.db.decoder().unmarshalItem(output.Attributes, out)
 }
 
 func (p *Put) run(ctx aws.Context) (output *dynamodb.PutItemOutput, err error) {
@@ -93,13 +207,26 @@ func (p *Put) run(ctx aws.Context) (output *dynamodb.PutItemOutput, err error) {
 	}
 
 	req := p.input()
-	retry(ctx, func() error {
-		output, err = p.table.db.client.PutItemWithContext(ctx, req)
+	err = p.table.db.retry(ctx, func() error {
+		var err error
+		start := time.Now()
+		output, err = p.table.db.client.PutItemWithContext(ctx, req, p.opts...)
+		p.table.db.observe(ctx, "PutItem", req, output, err, start)
 		return err
 	})
-	if p.cc != nil {
+	if p.cc != nil && output != nil {
 		addConsumedCapacity(p.cc, output.ConsumedCapacity)
 	}
+	if p.icm != nil && output != nil {
+		addItemCollectionMetrics(p.icm, p.table.name, output.ItemCollectionMetrics)
+	}
+	if err == nil && p.version.IsValid() {
+		if p.version.Kind() >= reflect.Uint && p.version.Kind() <= reflect.Uint64 {
+			p.version.SetUint(uint64(p.nextVersion))
+		} else {
+			p.version.SetInt(p.nextVersion)
+		}
+	}
 	return
 }
 
@@ -109,7 +236,7 @@ func (p *Put) input() *dynamodb.PutItemInput {
 		Item:                      p.item,
 		ReturnValues:              &p.returnType,
 		ExpressionAttributeNames:  p.nameExpr,
-		ExpressionAttributeValues: p.valueExpr,
+		ExpressionAttributeValues: p.table.db.emptyStringMode().values(p.valueExpr),
 	}
 	if p.condition != "" {
 		input.ConditionExpression = &p.condition
@@ -117,6 +244,9 @@ func (p *Put) input() *dynamodb.PutItemInput {
 	if p.cc != nil {
 		input.ReturnConsumedCapacity = aws.String(dynamodb.ReturnConsumedCapacityIndexes)
 	}
+	if p.icm != nil {
+		input.ReturnItemCollectionMetrics = aws.String(dynamodb.ReturnItemCollectionMetricsSize)
+	}
 	return input
 }
 
@@ -132,10 +262,11 @@ func (p *Put) writeTxItem() (*dynamodb.TransactWriteItem, error) {
 			ExpressionAttributeNames:  input.ExpressionAttributeNames,
 			ExpressionAttributeValues: input.ExpressionAttributeValues,
 			ConditionExpression:       input.ConditionExpression,
-			// TODO: add support when aws-sdk-go updates
-			// ReturnValuesOnConditionCheckFailure: aws.String(dynamodb.ReturnValuesOnConditionCheckFailureAllOld),
 		},
 	}
+	if p.condFailItem {
+		item.Put.ReturnValuesOnConditionCheckFailure = aws.String(dynamodb.ReturnValuesOnConditionCheckFailureAllOld)
+	}
 	return item, nil
 }
 
@@ -1,9 +1,15 @@
 package dynamo
 
 import (
+	"errors"
 	"reflect"
 	"testing"
 	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
 )
 
 func TestPut(t *testing.T) {
@@ -49,4 +55,210 @@ func TestPut(t *testing.T) {
 	if !isConditionalCheckErr(err) {
 		t.Error("expected ConditionalCheckFailedException, not", err)
 	}
+	if !errors.Is(err, ErrConditionFailed) {
+		t.Error("expected ErrConditionFailed, not", err)
+	}
+}
+
+func TestPutVersion(t *testing.T) {
+	type document struct {
+		ID      int
+		Body    string
+		Version int `dynamo:",version"`
+	}
+	table := NewFromIface(newMockClient(0)).Table("Test")
+
+	doc := document{ID: 1, Body: "hello", Version: 3}
+	put := table.Put(&doc)
+	if put.err != nil {
+		t.Fatal("unexpected error:", put.err)
+	}
+	in := put.input()
+	if *in.Item["Version"].N != "4" {
+		t.Error("expected version 4 to be stored, got", in.Item["Version"])
+	}
+	expect := "(" + put.subName("Version") + " = :v0)"
+	if in.ConditionExpression == nil || *in.ConditionExpression != expect {
+		t.Errorf("bad condition. %v ≠ %v", in.ConditionExpression, expect)
+	}
+	if *in.ExpressionAttributeValues[":v0"].N != "3" {
+		t.Error("bad old version:", in.ExpressionAttributeValues)
+	}
+
+	if err := put.Run(); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+	if doc.Version != 4 {
+		t.Error("expected version to be updated to 4, got", doc.Version)
+	}
+
+	// non-pointers are left alone
+	if err := table.Put(doc).Run(); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+	if doc.Version != 4 {
+		t.Error("expected version to stay 4, got", doc.Version)
+	}
+
+	// a stale version fails the condition and isn't bumped
+	conflict := NewFromIface(conflictClient{newMockClient(0)}).Table("Test")
+	err := conflict.Put(&doc).Run()
+	if !errors.Is(err, ErrConditionFailed) {
+		t.Error("expected ErrConditionFailed, got", err)
+	}
+	if doc.Version != 4 {
+		t.Error("expected version to stay 4 after a conflict, got", doc.Version)
+	}
+
+	// a zero version means the item must be new
+	in = table.Put(document{ID: 2}).input()
+	if expect := "(attribute_not_exists(" + put.subName("Version") + "))"; in.ConditionExpression == nil || *in.ConditionExpression != expect {
+		t.Errorf("bad condition for a new item. %v ≠ %v", in.ConditionExpression, expect)
+	}
+	if *in.Item["Version"].N != "1" {
+		t.Error("expected version 1 to be stored, got", in.Item["Version"])
+	}
+
+	if _, err := table.Batch("ID").Write().Put(&doc).Run(); err == nil {
+		t.Error("expected error for a versioned item in a batch write")
+	}
+
+	type badVersion struct {
+		ID      int
+		Version string `dynamo:",version"`
+	}
+	if err := table.Put(badVersion{ID: 1}).Run(); err == nil {
+		t.Error("expected error for non-integer version")
+	}
+}
+
+// conflictClient fails every put as if its condition wasn't met.
+type conflictClient struct {
+	*mockClient
+}
+
+func (conflictClient) PutItemWithContext(aws.Context, *dynamodb.PutItemInput, ...request.Option) (*dynamodb.PutItemOutput, error) {
+	return nil, awserr.NewRequestFailure(awserr.New(dynamodb.ErrCodeConditionalCheckFailedException, "The conditional request failed", nil), 400, "")
+}
+
+func TestPutItemCollectionMetrics(t *testing.T) {
+	db := NewFromIface(newMockClient(0))
+	table := db.Table("Test")
+
+	var icm ItemCollectionMetrics
+	if err := table.Put(map[string]int{"Idx": 1}).ReturnItemCollectionMetrics(&icm).Run(); err != nil {
+		t.Fatal(err)
+	}
+	if err := table.Put(map[string]int{"Idx": 1}).ReturnItemCollectionMetrics(&icm).Run(); err != nil {
+		t.Fatal(err)
+	}
+	if len(icm.Collections) != 1 {
+		t.Fatalf("expected 1 collection, got: %v", icm.Collections)
+	}
+	coll := icm.Collections[0]
+	if coll.TableName != "Test" || *coll.Key["Idx"].N != "1" {
+		t.Errorf("bad collection: %#v", coll)
+	}
+
+	// not requested
+	input := table.Put(map[string]int{"Idx": 1}).input()
+	if input.ReturnItemCollectionMetrics != nil {
+		t.Error("unexpected ReturnItemCollectionMetrics:", *input.ReturnItemCollectionMetrics)
+	}
+}
+
+func TestPutItemTooLarge(t *testing.T) {
+	type blob struct {
+		ID   int
+		Data []byte
+	}
+	db := NewFromIface(newMockClient(0))
+	table := db.Table("Test")
+
+	err := table.Put(blob{ID: 1, Data: make([]byte, maxItemSize)}).Run()
+	if !errors.Is(err, ErrItemTooLarge) {
+		t.Fatal("expected ErrItemTooLarge, got:", err)
+	}
+	var tooLarge *ItemTooLargeError
+	if !errors.As(err, &tooLarge) {
+		t.Fatalf("expected *ItemTooLargeError, got: %T", err)
+	}
+	// "ID" + 1 + "Data" + data
+	if want := 2 + 2 + 4 + maxItemSize; tooLarge.Size != want {
+		t.Errorf("bad size. want: %d, got: %d", want, tooLarge.Size)
+	}
+
+	if err := table.Put(blob{ID: 1, Data: make([]byte, maxItemSize-100)}).Run(); err != nil {
+		t.Error("unexpected error:", err)
+	}
+
+	_, err = table.Batch("ID").Write().Put(blob{ID: 1}, blob{ID: 2, Data: make([]byte, maxItemSize)}).Run()
+	if !errors.Is(err, ErrItemTooLarge) {
+		t.Error("expected ErrItemTooLarge from batch write, got:", err)
+	}
+}
+
+func TestPutEmptyStringMode(t *testing.T) {
+	type item struct {
+		ID    int
+		Name  string
+		Tags  []string
+		Attrs map[string]string
+	}
+	in := item{ID: 1, Tags: []string{"a", ""}, Attrs: map[string]string{"x": ""}}
+	null := &dynamodb.AttributeValue{NULL: aws.Bool(true)}
+
+	tests := []struct {
+		mode EmptyStringMode
+		out  map[string]*dynamodb.AttributeValue
+	}{
+		{EmptyStringKeep, map[string]*dynamodb.AttributeValue{
+			"ID":    {N: aws.String("1")},
+			"Name":  {S: aws.String("")},
+			"Tags":  {L: []*dynamodb.AttributeValue{{S: aws.String("a")}, {S: aws.String("")}}},
+			"Attrs": {M: map[string]*dynamodb.AttributeValue{"x": {S: aws.String("")}}},
+		}},
+		{EmptyStringOmit, map[string]*dynamodb.AttributeValue{
+			"ID":    {N: aws.String("1")},
+			"Tags":  {L: []*dynamodb.AttributeValue{{S: aws.String("a")}, null}},
+			"Attrs": {M: map[string]*dynamodb.AttributeValue{}},
+		}},
+		{EmptyStringNull, map[string]*dynamodb.AttributeValue{
+			"ID":    {N: aws.String("1")},
+			"Name":  null,
+			"Tags":  {L: []*dynamodb.AttributeValue{{S: aws.String("a")}, null}},
+			"Attrs": {M: map[string]*dynamodb.AttributeValue{"x": null}},
+		}},
+	}
+	for _, test := range tests {
+		db := NewFromIface(newMockClient(0))
+		db.SetEmptyStringMode(test.mode)
+		got := db.Table("Test").Put(in).input().Item
+		if !reflect.DeepEqual(got, test.out) {
+			t.Errorf("mode %d: bad item. %v ≠ %v", test.mode, got, test.out)
+		}
+	}
+}
+
+func TestPutIfNotExists(t *testing.T) {
+	type user struct {
+		ID  string `dynamo:",hash"`
+		Seq int    `dynamo:",range"`
+		Msg string
+	}
+	table := NewFromIface(newMockClient(0)).Table("Test")
+
+	p := table.Put(user{ID: "abc", Seq: 1}).If("Msg <> ?", "hi").IfNotExists()
+	if p.err != nil {
+		t.Fatal("unexpected error:", p.err)
+	}
+	in := p.input()
+	want := "(Msg <> :v0) AND (attribute_not_exists(" + p.subName("ID") + "))"
+	if got := aws.StringValue(in.ConditionExpression); got != want {
+		t.Errorf("bad condition: %s ≠ %s", got, want)
+	}
+
+	if err := table.Put(map[string]string{"ID": "abc"}).IfNotExists().Run(); err == nil {
+		t.Error("expected error for item without hash key tag")
+	}
 }
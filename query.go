@@ -1,10 +1,15 @@
 package dynamo
 
 import (
+	"bytes"
 	"errors"
+	"fmt"
+	"math/big"
 	"strings"
+	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
 	"github.com/aws/aws-sdk-go/service/dynamodb"
 )
 
@@ -16,6 +21,7 @@ type Query struct {
 	table    Table
 	startKey map[string]*dynamodb.AttributeValue
 	index    string
+	global   bool
 
 	hashKey   string
 	hashValue *dynamodb.AttributeValue
@@ -23,18 +29,24 @@ type Query struct {
 	rangeKey    string
 	rangeValues []*dynamodb.AttributeValue
 	rangeOp     Operator
+	inStrategy  InStrategy
 
 	projection  string
+	projectAll  bool
 	filters     []string
-	consistent  bool
+	consistent  *bool
 	limit       int64
 	searchLimit int64
+	pageSize    int64
+	onUnmarshal func(*UnmarshalError) error
 	order       *Order
 
 	subber
 
-	err error
-	cc  *ConsumedCapacity
+	err   error
+	cc    *ConsumedCapacity
+	stats *QueryStats
+	opts  []request.Option
 }
 
 var (
@@ -42,6 +54,20 @@ var (
 	ErrNotFound = errors.New("dynamo: no item found")
 	// ErrTooMany is returned when one item was requested, but the query returned multiple items.
 	ErrTooMany = errors.New("dynamo: too many items")
+	// ErrConditionFailed is returned when the condition of a Put, Update, or Delete is not met.
+	// The error returned will be a *ConditionFailedError, use errors.Is to check for it.
+	ErrConditionFailed = errors.New("dynamo: condition failed")
+	// ErrTableNotFound is returned when the table of a request doesn't exist.
+	// The error returned will be a *TableNotFoundError, use errors.Is to check for it.
+	// It wraps DynamoDB's ResourceNotFoundException, and is distinct from ErrNotFound,
+	// which means the table exists but no item matched.
+	ErrTableNotFound = errors.New("dynamo: table not found")
+	// ErrConsistentGlobalIndex is returned when a query of a global secondary index uses Consistent(true).
+	// Global secondary indexes only support eventually consistent reads. Local secondary indexes can be read consistently.
+	ErrConsistentGlobalIndex = errors.New("dynamo: consistent reads are not supported on global secondary indexes")
+	// ErrItemTooLarge is returned when an item to be put is larger than DynamoDB's 400KB limit.
+	// The error returned will be an *ItemTooLargeError, use errors.Is to check for it.
+	ErrItemTooLarge = errors.New("dynamo: item too large")
 )
 
 // Operator is an operation to apply in key comparisons.
@@ -57,32 +83,112 @@ const (
 	GreaterOrEqual Operator = "GE"
 	BeginsWith     Operator = "BEGINS_WITH"
 	Between        Operator = "BETWEEN"
+	// In matches any of the given values. Key conditions don't support IN, so queries using it
+	// read the range between the smallest and largest values and filter out the rest,
+	// or get the exact items with BatchGetItem, see Query.Range and Query.InStrategy.
+	In Operator = "IN"
 )
 
+// InStrategy chooses how a query with an In range key condition reads its items.
+type InStrategy int
+
+const (
+	// InRange queries the range between the smallest and largest values with BETWEEN
+	// and drops the items not in the set client-side, so they don't count toward Limit.
+	// It is the default, and works with indexes, filters, ordering, and paging.
+	InRange InStrategy = iota
+	// InBatchGet gets the exact items with BatchGetItem, so only the items found consume read capacity.
+	// It only works for tables, not indexes, and results come back in no particular order.
+	InBatchGet
+)
+
+// maxInValues is the most operands DynamoDB allows for IN.
+const maxInValues = 100
+
+// symbol returns the expression syntax for simple comparison operators.
+func (op Operator) symbol() (string, error) {
+	switch op {
+	case Equal:
+		return "=", nil
+	case NotEqual:
+		return "<>", nil
+	case Less:
+		return "<", nil
+	case LessOrEqual:
+		return "<=", nil
+	case Greater:
+		return ">", nil
+	case GreaterOrEqual:
+		return ">=", nil
+	}
+	return "", fmt.Errorf("dynamo: operator %s can't be used in a comparison", op)
+}
+
 // Order is used for specifying the order of results.
 type Order bool
 
 // Orders for sorting results.
 const (
 	Ascending  Order = true  // ScanIndexForward = true
-	Descending       = false // ScanIndexForward = false
+	Descending Order = false // ScanIndexForward = false
 )
 
 var (
 	selectAllAttributes      = aws.String("ALL_ATTRIBUTES")
+	selectAllProjected       = aws.String("ALL_PROJECTED_ATTRIBUTES")
 	selectCount              = aws.String("COUNT")
 	selectSpecificAttributes = aws.String("SPECIFIC_ATTRIBUTES")
 )
 
 // Get creates a new request to get an item.
 // Name is the name of the hash key (a.k.a. partition key).
+// When querying an index, it is the index's hash key, which may differ from the table's.
 // Value is the value of the hash key.
 func (table Table) Get(name string, value interface{}) *Query {
 	q := &Query{
 		table:   table,
 		hashKey: name,
 	}
-	q.hashValue, q.err = marshal(value, "")
+	q.hashValue, q.err = marshalValue("hash key value", value, "")
+	return q
+}
+
+// GetAll creates a new query for every item with the given hash key value, with no range key condition.
+// It is the same as Get, but makes the intent clearer when used with All or Iter,
+// which page through the whole partition:
+//
+//	err := table.GetAll("UserID", 613).All(&posts)
+func (table Table) GetAll(name string, value interface{}) *Query {
+	return table.Get(name, value)
+}
+
+// GetItem creates a new request to get the item with the primary key of key.
+// Key must be a struct, or a pointer to one, with a field tagged as the hash key
+// and optionally a field tagged as the range key, like with CreateTable:
+//
+//	type UserKey struct {
+//		ID   string    `dynamo:"ID,hash"`
+//		Time time.Time `dynamo:",range"`
+//	}
+//	err := table.GetItem(UserKey{ID: "abc", Time: t}).One(&user)
+//
+// Other fields are ignored, so a domain object with its key fields set can be used as its own key.
+func (table Table) GetItem(key interface{}) *Query {
+	q := &Query{
+		table: table,
+	}
+	keys, err := structKeys(key)
+	if err != nil {
+		q.setError(err)
+		return q
+	}
+	q.hashKey = keys.hashKey
+	q.hashValue = keys.hashValue
+	if keys.rangeKey != "" {
+		q.rangeKey = keys.rangeKey
+		q.rangeOp = Equal
+		q.rangeValues = []*dynamodb.AttributeValue{keys.rangeValue}
+	}
 	return q
 }
 
@@ -90,29 +196,114 @@ func (table Table) Get(name string, value interface{}) *Query {
 // For single item requests using One, op must be Equal.
 // Name is the name of the range key.
 // Op specifies the operator to use when comparing values.
+// Between takes exactly two values, the lower and upper bounds.
+// In takes up to 100 values, all of the same type.
+// BeginsWith takes one string or binary prefix.
+// Other operators take exactly one value.
+//
+// DynamoDB doesn't support In for key conditions, so by default a query using it reads the items
+// between the smallest and largest of the values and drops the others client-side, costing
+// read capacity for every item in that range. To fetch the exact keys from the table
+// itself with BatchGetItem instead, use InStrategy:
+//
+//	table.Get("UserID", 613).Range("Time", dynamo.In, t1, t2).InStrategy(dynamo.InBatchGet).All(&results)
 func (q *Query) Range(name string, op Operator, values ...interface{}) *Query {
 	var err error
 	q.rangeKey = name
 	q.rangeOp = op
-	q.rangeValues, err = marshalSlice(values)
+	q.rangeValues, err = marshalKeySlice("range key value", values)
 	q.setError(err)
+	if op == In {
+		q.setError(checkInValues(name, q.rangeValues))
+		return q
+	}
+	if op != BeginsWith && op != Between {
+		_, err := op.symbol()
+		q.setError(err)
+	}
+	if err == nil {
+		want := 1
+		if op == Between {
+			want = 2
+		}
+		if len(q.rangeValues) != want {
+			q.setError(fmt.Errorf("dynamo: range key %s: operator %s takes %d value(s), got %d (empty or nil values are omitted)",
+				name, op, want, len(q.rangeValues)))
+		}
+	}
+	if op == BeginsWith && len(q.rangeValues) == 1 {
+		if av := q.rangeValues[0]; av.S == nil && av.B == nil {
+			q.setError(fmt.Errorf("dynamo: range key %s: operator %s takes a string or binary prefix, got %s", name, op, avTypeName(av)))
+		}
+	}
+	return q
+}
+
+// RangeBeginsWith specifies the prefix of the range keys to get, such as "ORG#123#" for hierarchical keys.
+// It is the same as Range(name, BeginsWith, prefix).
+// Prefix must be a string or []byte, or a type that marshals to one, matching the type of the range key.
+func (q *Query) RangeBeginsWith(name string, prefix interface{}) *Query {
+	return q.Range(name, BeginsWith, prefix)
+}
+
+// InStrategy chooses how All, Each, and Iter read the items of an In range key condition.
+// The default, InRange, queries the range between the smallest and largest values and drops the rest client-side.
+// InBatchGet gets the exact items with BatchGetItem, which is cheaper when the values are far apart,
+// but can only be combined with Project, Consistent, ConsumedCapacity, and RequestOptions;
+// other options, such as Index, Filter, or Limit, cause an error.
+// One, Count, and Exists always query. Other operators ignore the strategy.
+func (q *Query) InStrategy(strategy InStrategy) *Query {
+	q.inStrategy = strategy
 	return q
 }
 
 // StartFrom makes this query continue from a previous one.
-// Use Query.Iter's LastEvaluatedKey.
+// Use Query.Iter's LastEvaluatedKey or the key returned by AllWithLastEvaluatedKey.
 func (q *Query) StartFrom(key PagingKey) *Query {
 	q.startKey = key
 	return q
 }
 
+// StartFromToken makes this query continue from a previous one,
+// using a token obtained by encoding a PagingKey with MarshalText.
+// An empty token starts from the beginning.
+func (q *Query) StartFromToken(token string) *Query {
+	var key PagingKey
+	q.setError(key.UnmarshalText([]byte(token)))
+	q.startKey = key
+	return q
+}
+
 // Index specifies the name of the index that this query will operate on.
+// The hash key given to Get and the range key given to Range, if any, should be the index's keys.
 func (q *Query) Index(name string) *Query {
 	q.index = name
 	return q
 }
 
+// LocalIndex specifies the name of the local secondary index that this query will operate on.
+// It is the same as Index, but documents that the index can be read consistently.
+func (q *Query) LocalIndex(name string) *Query {
+	q.index = name
+	q.global = false
+	return q
+}
+
+// GlobalIndex specifies the name of the global secondary index that this query will operate on.
+// Global secondary indexes can't be read consistently, so combining this with Consistent(true)
+// is an error that matches ErrConsistentGlobalIndex, returned without making a request.
+func (q *Query) GlobalIndex(name string) *Query {
+	q.index = name
+	q.global = true
+	q.checkConsistent()
+	return q
+}
+
 // Project limits the result attributes to the given paths.
+// Paths are attribute names separated by dots for nested maps, each optionally
+// followed by list indexes, like "Address.Zip" or "Items[0].Name".
+// Names that are reserved words are automatically escaped.
+// Use single quotes to escape complex names like 'User'.'Count'.
 func (q *Query) Project(paths ...string) *Query {
 	var expr string
 	for i, p := range paths {
@@ -124,6 +315,17 @@ func (q *Query) Project(paths ...string) *Query {
 		expr += name
 	}
 	q.projection = expr
+	q.projectAll = false
+	return q
+}
+
+// ProjectAll explicitly requests every attribute, replacing any previous projection.
+// When querying an index, only the attributes projected into the index are returned
+// (ALL_PROJECTED_ATTRIBUTES), so the table itself is never read for missing attributes.
+// Otherwise, all of the item's attributes are returned (ALL_ATTRIBUTES).
+func (q *Query) ProjectAll() *Query {
+	q.projection = ""
+	q.projectAll = true
 	return q
 }
 
@@ -131,10 +333,14 @@ func (q *Query) Project(paths ...string) *Query {
 // Use single quotes to specificy reserved names inline (like 'Count').
 // Use the placeholder ? within the expression to substitute values, and use $ for names.
 // You need to use quoted or placeholder names when the name is a reserved word in DynamoDB.
+// $ placeholders given an int are substituted as a list index, for example:
+//
+//	q.ProjectExpr("$, Items[$], Meta.$", "Name", 0, "CreatedAt")
 func (q *Query) ProjectExpr(expr string, args ...interface{}) *Query {
 	expr, err := q.subExpr(expr, args...)
 	q.setError(err)
 	q.projection = expr
+	q.projectAll = false
 	return q
 }
 
@@ -142,7 +348,11 @@ func (q *Query) ProjectExpr(expr string, args ...interface{}) *Query {
 // Use single quotes to specificy reserved names inline (like 'Count').
 // Use the placeholder ? within the expression to substitute values, and use $ for names.
 // You need to use quoted or placeholder names when the name is a reserved word in DynamoDB.
+// A slice passed as the list of an IN operator, like "ID IN ?" or "ID IN (?)", is expanded into one value per element.
 // Multiple calls to Filter will be combined with AND.
+// Filters may reference any attribute, including the table's keys when querying an index,
+// as long as it is projected into the index. Names and values are substituted consistently with the
+// key condition's, so the same attribute can appear in both.
 func (q *Query) Filter(expr string, args ...interface{}) *Query {
 	expr = wrapExpr(expr)
 	expr, err := q.subExpr(expr, args...)
@@ -151,15 +361,126 @@ func (q *Query) Filter(expr string, args ...interface{}) *Query {
 	return q
 }
 
+// FilterOr is like Filter, but combines expr with any previous filters using OR.
+// Previous filters are grouped together first, so
+//
+//	Filter("A = ?", 1).Filter("B = ?", 2).FilterOr("C = ?", 3)
+//
+// evaluates as ((A = 1) AND (B = 2)) OR (C = 3).
+// Later calls to Filter are combined with the result using AND.
+// When there are no previous filters, it is the same as Filter.
+func (q *Query) FilterOr(expr string, args ...interface{}) *Query {
+	expr = wrapExpr(expr)
+	expr, err := q.subExpr(expr, args...)
+	q.setError(err)
+	q.filters = orFilters(q.filters, expr)
+	return q
+}
+
+// FilterExists filters results to those where path exists.
+// Reserved words and dotted paths are escaped as in Project.
+// It is combined with other filters using AND.
+func (q *Query) FilterExists(path string) *Query {
+	return q.filterFunc("attribute_exists", path)
+}
+
+// FilterNotExists filters results to those where path does not exist.
+// Reserved words and dotted paths are escaped as in Project.
+// It is combined with other filters using AND.
+func (q *Query) FilterNotExists(path string) *Query {
+	return q.filterFunc("attribute_not_exists", path)
+}
+
+// FilterAttributeType filters results to those where path is of the given DynamoDB type,
+// such as "S", "N", "BOOL", "NULL", "L", or "M".
+// It is combined with other filters using AND.
+func (q *Query) FilterAttributeType(path string, t string) *Query {
+	return q.filterFunc("attribute_type", path, t)
+}
+
+// FilterBeginsWith filters results to those where the string at path begins with prefix.
+// It is combined with other filters using AND.
+func (q *Query) FilterBeginsWith(path string, prefix string) *Query {
+	return q.filterFunc("begins_with", path, prefix)
+}
+
+// FilterContains filters results to those where path contains value:
+// a substring of a string, or an element of a set or list.
+// It is combined with other filters using AND.
+func (q *Query) FilterContains(path string, value interface{}) *Query {
+	return q.filterFunc("contains", path, value)
+}
+
+// FilterSize filters results to those where the size of path compares to size using op,
+// which must be Equal, NotEqual, Less, LessOrEqual, Greater, or GreaterOrEqual.
+// Size is the length of a string or binary value, or the number of elements in a set, list, or map.
+// It is combined with other filters using AND.
+func (q *Query) FilterSize(path string, op Operator, size int) *Query {
+	sym, err := op.symbol()
+	q.setError(err)
+	path, err = q.escape(path)
+	q.setError(err)
+	sub, err := q.subValue(size, "")
+	q.setError(err)
+	q.filters = append(q.filters, "(size("+path+") "+sym+" "+sub+")")
+	return q
+}
+
+// filterFunc adds a filter calling the function fn with path and args.
+func (q *Query) filterFunc(fn, path string, args ...interface{}) *Query {
+	path, err := q.escape(path)
+	q.setError(err)
+	expr := fn + "(" + path
+	for _, arg := range args {
+		sub, err := q.subValue(arg, "")
+		q.setError(err)
+		expr += ", " + sub
+	}
+	q.filters = append(q.filters, "("+expr+"))")
+	return q
+}
+
+// OnUnmarshalError sets a function that is called when a result can't be unmarshaled,
+// instead of stopping with an *UnmarshalError. If fn returns nil, the result is skipped
+// and iteration continues without counting it toward Limit; otherwise iteration stops with the returned error.
+// This only applies to Iter and All.
+func (q *Query) OnUnmarshalError(fn func(err *UnmarshalError) error) *Query {
+	q.onUnmarshal = fn
+	return q
+}
+
 // Consistent will, if on is true, make this query a strongly consistent read.
-// Queries are eventually consistent by default.
+// Queries are eventually consistent by default, unless the table's
+// DefaultConsistent is set. This overrides the table's default.
+// Only tables and local secondary indexes can be read consistently. Because Index can't tell
+// a local index from a global one, DynamoDB rejects a consistent query of a global index,
+// and the error will match ErrConsistentGlobalIndex with errors.Is.
+// Use GlobalIndex instead to catch this before making a request.
 // Strongly consistent reads are more resource-heavy than eventually consistent reads.
 func (q *Query) Consistent(on bool) *Query {
-	q.consistent = on
+	q.consistent = &on
+	q.checkConsistent()
 	return q
 }
 
+// checkConsistent sets an error if this is a consistent query of a global index.
+func (q *Query) checkConsistent() {
+	if q.global && q.consistent != nil && *q.consistent {
+		q.setError(ErrConsistentGlobalIndex)
+	}
+}
+
+// isConsistent returns whether this query should use a strongly consistent read.
+// The table's default doesn't apply to index queries.
+func (q *Query) isConsistent() bool {
+	if q.consistent != nil {
+		return *q.consistent
+	}
+	return q.table.consistent && q.index == ""
+}
+
 // Limit specifies the maximum amount of results to return.
+// Results are accumulated across pages until the limit is reached.
 func (q *Query) Limit(limit int64) *Query {
 	q.limit = limit
 	return q
@@ -168,13 +489,24 @@ func (q *Query) Limit(limit int64) *Query {
 // SearchLimit specifies the maximum amount of results to examine.
 // If a filter is not specified, the number of results will be limited.
 // If a filter is specified, the number of results to consider for filtering will be limited.
+// SearchLimit stops after a single page of results; use PageSize to keep paginating.
 func (q *Query) SearchLimit(limit int64) *Query {
 	q.searchLimit = limit
 	return q
 }
 
-// Order specifies the desired result order.
-// Requires a range key (a.k.a. partition key) to be specified.
+// PageSize specifies the maximum amount of results to examine per request.
+// Unlike SearchLimit, All and Iter will keep requesting pages until
+// every result has been retrieved or Limit is reached.
+// This is useful for spreading out throughput consumption over many small requests.
+func (q *Query) PageSize(size int64) *Query {
+	q.pageSize = size
+	return q
+}
+
+// Order specifies the desired result order, sorted by the range key (a.k.a. sort key).
+// The table or index must have a range key, but a Range condition is not required:
+// Order(Descending) with only a hash key will return the whole partition in reverse.
 func (q *Query) Order(order Order) *Query {
 	q.order = &order
 	return q
@@ -186,6 +518,21 @@ func (q *Query) ConsumedCapacity(cc *ConsumedCapacity) *Query {
 	return q
 }
 
+// Stats will record the number of items returned and evaluated by this query,
+// and the capacity it consumed, adding them to stats.
+// Comparing Count to ScannedCount shows how many items filters discard.
+func (q *Query) Stats(stats *QueryStats) *Query {
+	q.stats = stats
+	return q
+}
+
+// RequestOptions adds options, such as custom request handlers, to every request made by this query.
+// They are passed to the AWS SDK's WithContext methods.
+func (q *Query) RequestOptions(opts ...request.Option) *Query {
+	q.opts = append(q.opts, opts...)
+	return q
+}
+
 // One executes this query and retrieves a single result,
 // unmarshaling the result to out.
 func (q *Query) One(out interface{}) error {
@@ -194,6 +541,46 @@ func (q *Query) One(out interface{}) error {
 	return q.OneWithContext(ctx, out)
 }
 
+// OneRaw executes this query and retrieves a single result without unmarshaling it.
+// It returns ErrNotFound if there is no result, and ErrTooMany if more than one item matches the query.
+// Passing a *map[string]*dynamodb.AttributeValue to One does the same.
+func (q *Query) OneRaw() (map[string]*dynamodb.AttributeValue, error) {
+	ctx, cancel := defaultContext()
+	defer cancel()
+	return q.OneRawWithContext(ctx)
+}
+
+// OneRawWithContext executes this query and retrieves a single result without unmarshaling it.
+func (q *Query) OneRawWithContext(ctx aws.Context) (map[string]*dynamodb.AttributeValue, error) {
+	var item map[string]*dynamodb.AttributeValue
+	if err := q.OneWithContext(ctx, &item); err != nil {
+		return nil, err
+	}
+	return item, nil
+}
+
+// OneOrZero executes this query and retrieves a single result if one exists,
+// unmarshaling the result to out. found is false if no result matched,
+// in which case out is left unchanged and err is nil.
+func (q *Query) OneOrZero(out interface{}) (found bool, err error) {
+	ctx, cancel := defaultContext()
+	defer cancel()
+	return q.OneOrZeroWithContext(ctx, out)
+}
+
+// OneOrZeroWithContext executes this query and retrieves a single result if one exists,
+// unmarshaling the result to out. found is false if no result matched,
+// in which case out is left unchanged and err is nil.
+func (q *Query) OneOrZeroWithContext(ctx aws.Context, out interface{}) (found bool, err error) {
+	err = q.OneWithContext(ctx, out)
+	if err == ErrNotFound {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// OneWithContext executes this query and retrieves a single result,
+// unmarshaling the result to out.
 func (q *Query) OneWithContext(ctx aws.Context, out interface{}) error {
 	if q.err != nil {
 		return q.err
@@ -204,9 +591,14 @@ func (q *Query) OneWithContext(ctx aws.Context, out interface{}) error {
 		req := q.getItemInput()
 
 		var res *dynamodb.GetItemOutput
-		err := retry(ctx, func() error {
+		err := q.table.db.retry(ctx, func() error {
+			if err := q.table.db.reads.wait(ctx); err != nil {
+				return err
+			}
 			var err error
-			res, err = q.table.db.client.GetItemWithContext(ctx, req)
+			start := time.Now()
+			res, err = q.table.db.client.GetItemWithContext(ctx, req, q.opts...)
+			q.table.db.observe(ctx, "GetItem", req, res, err, start)
 			if err != nil {
 				return err
 			}
@@ -221,25 +613,34 @@ func (q *Query) OneWithContext(ctx aws.Context, out interface{}) error {
 		if q.cc != nil {
 			addConsumedCapacity(q.cc, res.ConsumedCapacity)
 		}
+		q.stats.add(1, 1, res.ConsumedCapacity)
+		q.table.db.reads.spend(res.ConsumedCapacity)
 
-		return unmarshalItem(res.Item, out)
+		return q.table.db.decoder().unmarshalItem(res.Item, out)
 	}
 
 	// If not, try a Query.
 	req := q.queryInput()
 
 	var res *dynamodb.QueryOutput
-	err := retry(ctx, func() error {
+	var items []map[string]*dynamodb.AttributeValue
+	err := q.table.db.retry(ctx, func() error {
+		if err := q.table.db.reads.wait(ctx); err != nil {
+			return err
+		}
 		var err error
-		res, err = q.table.db.client.QueryWithContext(ctx, req)
+		start := time.Now()
+		res, err = q.table.db.client.QueryWithContext(ctx, req, q.opts...)
+		q.table.db.observe(ctx, "Query", req, res, err, start)
 		if err != nil {
 			return err
 		}
 
+		items = q.inRangeItems(res.Items)
 		switch {
-		case len(res.Items) == 0:
+		case len(items) == 0:
 			return ErrNotFound
-		case len(res.Items) > 1:
+		case len(items) > 1:
 			return ErrTooMany
 		case res.LastEvaluatedKey != nil && q.searchLimit != 0:
 			return ErrTooMany
@@ -253,8 +654,10 @@ func (q *Query) OneWithContext(ctx aws.Context, out interface{}) error {
 	if q.cc != nil {
 		addConsumedCapacity(q.cc, res.ConsumedCapacity)
 	}
+	q.stats.add(aws.Int64Value(res.Count), aws.Int64Value(res.ScannedCount), res.ConsumedCapacity)
+	q.table.db.reads.spend(res.ConsumedCapacity)
 
-	return unmarshalItem(res.Items[0], out)
+	return q.table.db.decoder().unmarshalItem(items[0], out)
 }
 
 // Count executes this request, returning the number of results.
@@ -264,35 +667,201 @@ func (q *Query) Count() (int64, error) {
 	return q.CountWithContext(ctx)
 }
 
+// CountWithContext executes this request, returning the number of results.
+// If ctx is done or a request fails, no more pages are requested and the error is returned
+// along with the count of the pages read so far.
 func (q *Query) CountWithContext(ctx aws.Context) (int64, error) {
+	count, _, err := q.CountDetailedWithContext(ctx)
+	return count, err
+}
+
+// CountPage executes a single page of this request, returning the number of results in it
+// and a key to pass to StartFrom to count the next page. The key is nil when there are no more pages.
+// Use PageSize or SearchLimit to control how many items each page evaluates.
+func (q *Query) CountPage() (count int64, lastKey PagingKey, err error) {
+	ctx, cancel := defaultContext()
+	defer cancel()
+	return q.CountPageWithContext(ctx)
+}
+
+// CountPageWithContext executes a single page of this request, returning the number of results in it
+// and a key to pass to StartFrom to count the next page. The key is nil when there are no more pages.
+func (q *Query) CountPageWithContext(ctx aws.Context) (count int64, lastKey PagingKey, err error) {
 	if q.err != nil {
-		return 0, q.err
+		return 0, nil, q.err
 	}
 
-	var count int64
+	req := q.countInput()
+
 	var res *dynamodb.QueryOutput
+	err = q.table.db.retry(ctx, func() error {
+		if err := q.table.db.reads.wait(ctx); err != nil {
+			return err
+		}
+		var err error
+		start := time.Now()
+		res, err = q.table.db.client.QueryWithContext(ctx, req, q.opts...)
+		q.table.db.observe(ctx, "Query", req, res, err, start)
+		return err
+	})
+	if err != nil {
+		return 0, nil, err
+	}
+	if q.cc != nil {
+		addConsumedCapacity(q.cc, res.ConsumedCapacity)
+	}
+	q.stats.add(aws.Int64Value(res.Count), aws.Int64Value(res.ScannedCount), res.ConsumedCapacity)
+	q.table.db.reads.spend(res.ConsumedCapacity)
+	return q.matched(res), res.LastEvaluatedKey, nil
+}
+
+// Exists executes this request, returning true if any item matches it.
+// Single item requests use GetItem, fetching only the item's keys.
+// Other queries count results instead of fetching them, stopping at the first page with any.
+// Consistent reads are honored.
+func (q *Query) Exists() (bool, error) {
+	ctx, cancel := defaultContext()
+	defer cancel()
+	return q.ExistsWithContext(ctx)
+}
+
+// ExistsWithContext executes this request, returning true if any item matches it.
+// If ctx is done, no more pages are requested and ctx.Err() is returned.
+func (q *Query) ExistsWithContext(ctx aws.Context) (bool, error) {
+	if q.err != nil {
+		return false, q.err
+	}
+
+	if q.canGetItem() {
+		req := q.getItemInput()
+		// project only the keys, without touching this query's substitutions
+		keys := subber{nameExpr: make(map[string]*string, len(q.nameExpr)+2)}
+		for k, v := range q.nameExpr {
+			keys.nameExpr[k] = v
+		}
+		projection := keys.subName(q.hashKey)
+		if q.rangeKey != "" {
+			projection += ", " + keys.subName(q.rangeKey)
+		}
+		req.ProjectionExpression = &projection
+		req.ExpressionAttributeNames = keys.nameExpr
+
+		var res *dynamodb.GetItemOutput
+		err := q.table.db.retry(ctx, func() error {
+			if err := q.table.db.reads.wait(ctx); err != nil {
+				return err
+			}
+			var err error
+			start := time.Now()
+			res, err = q.table.db.client.GetItemWithContext(ctx, req, q.opts...)
+			q.table.db.observe(ctx, "GetItem", req, res, err, start)
+			return err
+		})
+		if err != nil {
+			return false, err
+		}
+		if q.cc != nil {
+			addConsumedCapacity(q.cc, res.ConsumedCapacity)
+		}
+		var found int64
+		if res.Item != nil {
+			found = 1
+		}
+		q.stats.add(found, found, res.ConsumedCapacity)
+		q.table.db.reads.spend(res.ConsumedCapacity)
+		return res.Item != nil, nil
+	}
+
+	req := q.countInput()
 	for {
-		req := q.queryInput()
-		req.Select = selectCount
+		if err := ctx.Err(); err != nil {
+			return false, err
+		}
 
-		err := retry(ctx, func() error {
+		var res *dynamodb.QueryOutput
+		err := q.table.db.retry(ctx, func() error {
+			if err := q.table.db.reads.wait(ctx); err != nil {
+				return err
+			}
 			var err error
-			res, err = q.table.db.client.QueryWithContext(ctx, req)
+			start := time.Now()
+			res, err = q.table.db.client.QueryWithContext(ctx, req, q.opts...)
+			q.table.db.observe(ctx, "Query", req, res, err, start)
+			return err
+		})
+		if err != nil {
+			return false, err
+		}
+		if q.cc != nil {
+			addConsumedCapacity(q.cc, res.ConsumedCapacity)
+		}
+		q.stats.add(aws.Int64Value(res.Count), aws.Int64Value(res.ScannedCount), res.ConsumedCapacity)
+		q.table.db.reads.spend(res.ConsumedCapacity)
+
+		if q.matched(res) > 0 {
+			return true, nil
+		}
+		// filters may leave pages empty
+		if res.LastEvaluatedKey == nil || q.searchLimit > 0 {
+			return false, nil
+		}
+		req.ExclusiveStartKey = res.LastEvaluatedKey
+	}
+}
+
+// CountDetailed executes this request, returning the number of results
+// and the number of items evaluated before filters were applied.
+// Comparing the two shows how many items a filter discards.
+func (q *Query) CountDetailed() (matched int64, scanned int64, err error) {
+	ctx, cancel := defaultContext()
+	defer cancel()
+	return q.CountDetailedWithContext(ctx)
+}
+
+// CountDetailedWithContext executes this request, returning the number of results
+// and the number of items evaluated before filters were applied.
+// If ctx is done or a request fails, no more pages are requested and the error is returned
+// along with the counts of the pages read so far.
+func (q *Query) CountDetailedWithContext(ctx aws.Context) (matched int64, scanned int64, err error) {
+	if q.err != nil {
+		return 0, 0, q.err
+	}
+
+	var count, scannedCount int64
+	var res *dynamodb.QueryOutput
+	for {
+		if err := ctx.Err(); err != nil {
+			return count, scannedCount, err
+		}
+
+		req := q.countInput()
+
+		err := q.table.db.retry(ctx, func() error {
+			if err := q.table.db.reads.wait(ctx); err != nil {
+				return err
+			}
+			var err error
+			start := time.Now()
+			res, err = q.table.db.client.QueryWithContext(ctx, req, q.opts...)
+			q.table.db.observe(ctx, "Query", req, res, err, start)
 			if err != nil {
 				return err
 			}
 			if res.Count == nil {
 				return errors.New("nil count")
 			}
-			count += *res.Count
+			count += q.matched(res)
+			scannedCount += aws.Int64Value(res.ScannedCount)
 			return nil
 		})
 		if err != nil {
-			return 0, err
+			return count, scannedCount, err
 		}
 		if q.cc != nil {
 			addConsumedCapacity(q.cc, res.ConsumedCapacity)
 		}
+		q.stats.add(aws.Int64Value(res.Count), aws.Int64Value(res.ScannedCount), res.ConsumedCapacity)
+		q.table.db.reads.spend(res.ConsumedCapacity)
 
 		q.startKey = res.LastEvaluatedKey
 		if res.LastEvaluatedKey == nil || q.searchLimit > 0 {
@@ -300,7 +869,7 @@ func (q *Query) CountWithContext(ctx aws.Context) (int64, error) {
 		}
 	}
 
-	return count, nil
+	return count, scannedCount, nil
 }
 
 // queryIter is the iterator for Query operations
@@ -310,7 +879,8 @@ type queryIter struct {
 	output *dynamodb.QueryOutput
 	err    error
 	idx    int
-	n      int64
+	n      int64 // results delivered, counted toward the limit
+	seen   int64 // results unmarshaled, including skipped ones
 
 	unmarshal unmarshalFunc
 }
@@ -323,6 +893,8 @@ func (itr *queryIter) Next(out interface{}) bool {
 	return itr.NextWithContext(ctx, out)
 }
 
+// NextWithContext tries to unmarshal the next result into out.
+// Returns false when it is complete or if it runs into an error.
 func (itr *queryIter) NextWithContext(ctx aws.Context, out interface{}) bool {
 	// stop if we have an error
 	if itr.err != nil {
@@ -334,55 +906,83 @@ func (itr *queryIter) NextWithContext(ctx aws.Context, out interface{}) bool {
 		return false
 	}
 
-	// can we use results we already have?
-	if itr.output != nil && itr.idx < len(itr.output.Items) {
-		item := itr.output.Items[itr.idx]
-		itr.err = itr.unmarshal(item, out)
-		itr.idx++
-		itr.n++
-		return itr.err == nil
-	}
+	for {
+		// can we use results we already have?
+		if itr.output != nil && itr.idx < len(itr.output.Items) {
+			if itr.decode(itr.output.Items[itr.idx], out) {
+				continue
+			}
+			return itr.err == nil
+		}
 
-	// new query
-	if itr.input == nil {
-		itr.input = itr.query.queryInput()
-	}
-	if itr.output != nil && itr.idx >= len(itr.output.Items) {
-		// have we exhausted all results?
-		if itr.output.LastEvaluatedKey == nil || itr.query.searchLimit > 0 {
-			return false
+		if itr.output != nil {
+			// have we exhausted all results?
+			if itr.output.LastEvaluatedKey == nil || itr.query.searchLimit > 0 {
+				return false
+			}
+
+			// no, prepare next request and reset index
+			itr.input.ExclusiveStartKey = itr.output.LastEvaluatedKey
+			itr.idx = 0
+			// don't fetch more than needed to reach the limit
+			if remaining := itr.query.limit - itr.n; itr.query.limit > 0 && !itr.query.filtered() &&
+				(itr.input.Limit == nil || remaining < *itr.input.Limit) {
+				itr.input.Limit = &remaining
+			}
+		} else if itr.input == nil {
+			// new query
+			itr.input = itr.query.queryInput()
 		}
 
-		// no, prepare next request and reset index
-		itr.input.ExclusiveStartKey = itr.output.LastEvaluatedKey
-		itr.idx = 0
-	}
+		// stop between pages if ctx is done
+		if itr.err = ctx.Err(); itr.err != nil {
+			return false
+		}
 
-	itr.err = retry(ctx, func() error {
-		var err error
-		itr.output, err = itr.query.table.db.client.QueryWithContext(ctx, itr.input)
-		return err
-	})
+		itr.err = itr.query.table.db.retry(ctx, func() error {
+			if err := itr.query.table.db.reads.wait(ctx); err != nil {
+				return err
+			}
+			var err error
+			start := time.Now()
+			itr.output, err = itr.query.table.db.client.QueryWithContext(ctx, itr.input, itr.query.opts...)
+			itr.query.table.db.observe(ctx, "Query", itr.input, itr.output, err, start)
+			return err
+		})
 
-	if itr.err != nil {
-		return false
-	}
-	if itr.query.cc != nil {
-		addConsumedCapacity(itr.query.cc, itr.output.ConsumedCapacity)
-	}
-	if len(itr.output.Items) == 0 {
-		if itr.output.LastEvaluatedKey != nil {
-			// we need to retry until we get some data
-			return itr.NextWithContext(ctx, out)
+		if itr.err != nil {
+			return false
 		}
-		// we're done
-		return false
+		if itr.query.cc != nil {
+			addConsumedCapacity(itr.query.cc, itr.output.ConsumedCapacity)
+		}
+		itr.query.stats.add(aws.Int64Value(itr.output.Count), aws.Int64Value(itr.output.ScannedCount), itr.output.ConsumedCapacity)
+		itr.query.table.db.reads.spend(itr.output.ConsumedCapacity)
+
+		// pages with no data (e.g. everything was filtered out) are skipped
+		// by the next iteration, until we get some or run out of pages
 	}
+}
 
-	itr.err = itr.unmarshal(itr.output.Items[itr.idx], out)
+// decode unmarshals item into out and advances the iterator.
+// It returns true if item should be skipped, either because it isn't in
+// the In range key set or because it couldn't be unmarshaled and the
+// UnmarshalErrorHandler said to skip it.
+func (itr *queryIter) decode(item map[string]*dynamodb.AttributeValue, out interface{}) (skip bool) {
+	if !itr.query.inRange(item) {
+		// outside of the In range key set; doesn't count toward the limit
+		itr.idx++
+		return true
+	}
+	err := itr.unmarshal(item, out)
 	itr.idx++
+	itr.seen++
+	if err != nil {
+		itr.err = unmarshalError(itr.query.onUnmarshal, itr.seen-1, item, err)
+		return itr.err == nil
+	}
 	itr.n++
-	return itr.err == nil
+	return false
 }
 
 // Err returns the error encountered, if any.
@@ -391,6 +991,8 @@ func (itr *queryIter) Err() error {
 	return itr.err
 }
 
+// LastEvaluatedKey returns a key that can be used to continue this query.
+// Use with SearchLimit for best results.
 func (itr *queryIter) LastEvaluatedKey() PagingKey {
 	if itr.output != nil {
 		return itr.output.LastEvaluatedKey
@@ -399,45 +1001,215 @@ func (itr *queryIter) LastEvaluatedKey() PagingKey {
 }
 
 // All executes this request and unmarshals all results to out, which must be a pointer to a slice.
+// Pages of results are requested until there are no more, or until Limit is reached,
+// so a query without a range key condition reads its entire partition.
 func (q *Query) All(out interface{}) error {
 	ctx, cancel := defaultContext()
 	defer cancel()
 	return q.AllWithContext(ctx, out)
 }
 
+// AllWithContext executes this request and unmarshals all results to out, which must be a pointer to a slice.
+// If ctx is done, no more pages are requested and ctx.Err() is returned.
 func (q *Query) AllWithContext(ctx aws.Context, out interface{}) error {
 	_, err := q.AllWithLastEvaluatedKeyContext(ctx, out)
 	return err
 }
 
+// Each executes this request and calls fn with each result as it arrives, one page at a time.
+// fn is given the raw item, which can be decoded with UnmarshalItem; this is useful for
+// streaming results into a pipeline or choosing the type to decode each item into.
+// If fn returns an error, no more results are requested and that error is returned.
+//
+// For example, with a single-table design where a Type attribute tells items apart:
+//
+//	err := table.Get("PK", "ORG#123").Each(func(item map[string]*dynamodb.AttributeValue) error {
+//		switch aws.StringValue(item["Type"].S) {
+//		case "user":
+//			var u User
+//			if err := dynamo.UnmarshalItem(item, &u); err != nil {
+//				return err
+//			}
+//			users = append(users, u)
+//		case "invoice":
+//			var inv Invoice
+//			if err := dynamo.UnmarshalItem(item, &inv); err != nil {
+//				return err
+//			}
+//			invoices = append(invoices, inv)
+//		}
+//		return nil
+//	})
+func (q *Query) Each(fn func(item map[string]*dynamodb.AttributeValue) error) error {
+	ctx, cancel := defaultContext()
+	defer cancel()
+	return q.EachWithContext(ctx, fn)
+}
+
+// EachWithContext executes this request and calls fn with each result as it arrives, one page at a time.
+// If fn returns an error, no more results are requested and that error is returned.
+// If ctx is done, no more pages are requested and ctx.Err() is returned.
+func (q *Query) EachWithContext(ctx aws.Context, fn func(item map[string]*dynamodb.AttributeValue) error) error {
+	iter := q.iter(unmarshalRaw)
+	var item map[string]*dynamodb.AttributeValue
+	for iter.NextWithContext(ctx, &item) {
+		if err := fn(item); err != nil {
+			return err
+		}
+	}
+	return iter.Err()
+}
+
 // AllWithLastEvaluatedKey executes this request and unmarshals all results to out, which must be a pointer to a slice.
 // This returns a PagingKey you can use with StartFrom to split up results.
+// Combine this with SearchLimit to retrieve a single page of results per call:
+//
+//	next, err := table.Get("UserID", 613).SearchLimit(100).StartFrom(prev).AllWithLastEvaluatedKey(&page)
+//
+// The returned key is nil when there are no more results.
 func (q *Query) AllWithLastEvaluatedKey(out interface{}) (PagingKey, error) {
 	ctx, cancel := defaultContext()
 	defer cancel()
 	return q.AllWithLastEvaluatedKeyContext(ctx, out)
 }
 
+// AllWithLastEvaluatedKeyContext executes this request and unmarshals all results to out, which must be a pointer to a slice.
+// This returns a PagingKey you can use with StartFrom to split up results.
 func (q *Query) AllWithLastEvaluatedKeyContext(ctx aws.Context, out interface{}) (PagingKey, error) {
-	iter := &queryIter{
-		query:     q,
-		unmarshal: unmarshalAppend,
-		err:       q.err,
-	}
+	iter := q.iter(q.table.db.decoder().unmarshalAppend)
 	for iter.NextWithContext(ctx, out) {
 	}
 	return iter.LastEvaluatedKey(), iter.Err()
 }
 
 // Iter returns a results iterator for this request.
+// Results are fetched lazily one page at a time as the iterator advances,
+// so arbitrarily large result sets can be processed with constant memory.
 func (q *Query) Iter() PagingIter {
-	iter := &queryIter{
+	return q.iter(q.table.db.decoder().unmarshalItem)
+}
+
+// iter returns an iterator for this query's results, using BatchGetItem for In with InBatchGet.
+func (q *Query) iter(fn unmarshalFunc) PagingIter {
+	if q.rangeOp == In && q.inStrategy == InBatchGet {
+		bg := q.batchGetIn()
+		return batchGetInIter{newBGIter(bg, fn, bg.err)}
+	}
+	return &queryIter{
 		query:     q,
-		unmarshal: unmarshalItem,
+		unmarshal: fn,
 		err:       q.err,
 	}
+}
+
+// batchGetIn returns a batch get of every key matched by this query's In range key condition.
+func (q *Query) batchGetIn() *BatchGet {
+	bg := &BatchGet{
+		batch:      q.table.Batch(q.hashKey, q.rangeKey),
+		projection: q.projection,
+		consistent: q.isConsistent(),
+		err:        q.err,
+		cc:         q.cc,
+		opts:       q.opts,
+		subber:     q.subber,
+	}
+	if q.index != "" || len(q.filters) > 0 || q.order != nil || q.startKey != nil ||
+		q.limit > 0 || q.searchLimit > 0 || q.pageSize > 0 || q.stats != nil || q.onUnmarshal != nil {
+		bg.setError(fmt.Errorf("dynamo: range key %s: %s with InBatchGet can only be combined with Project, Consistent, ConsumedCapacity, and RequestOptions",
+			q.rangeKey, In))
+	}
+	for i, v := range q.rangeValues {
+		// BatchGetItem rejects duplicate keys
+		dup := false
+		for _, prev := range q.rangeValues[:i] {
+			if cmp, _ := compareKeys(v, prev); cmp == 0 {
+				dup = true
+				break
+			}
+		}
+		if dup {
+			continue
+		}
+		bg.reqs = append(bg.reqs, &Query{
+			table:       q.table,
+			hashKey:     q.hashKey,
+			hashValue:   q.hashValue,
+			rangeKey:    q.rangeKey,
+			rangeOp:     Equal,
+			rangeValues: []*dynamodb.AttributeValue{v},
+		})
+	}
+	return bg
+}
+
+// batchGetInIter is the iterator for In queries using InBatchGet.
+// BatchGetItem doesn't page, so there is never a LastEvaluatedKey.
+type batchGetInIter struct {
+	*bgIter
+}
+
+// LastEvaluatedKey always returns nil.
+func (batchGetInIter) LastEvaluatedKey() PagingKey {
+	return nil
+}
+
+// Validate returns the first error encountered while building this query,
+// such as a malformed expression or the wrong number of arguments for its placeholders,
+// without making any requests.
+func (q *Query) Validate() error {
+	return q.err
+}
+
+// DebugInput returns the requests this query would make, without sending them.
+// It is useful for checking the generated expressions, names, and values.
+// All, Iter, and Count use query. One uses get, which is nil if GetItem can't be used.
+// err is any error encountered while building this query.
+func (q *Query) DebugInput() (query *dynamodb.QueryInput, get *dynamodb.GetItemInput, err error) {
+	query = q.queryInput()
+	if q.canGetItem() {
+		get = q.getItemInput()
+	}
+	return query, get, q.err
+}
+
+// checkInValues validates the values of an In range key condition.
+func checkInValues(name string, values []*dynamodb.AttributeValue) error {
+	if len(values) == 0 || len(values) > maxInValues {
+		return fmt.Errorf("dynamo: range key %s: operator %s takes 1 to %d values, got %d (empty or nil values are omitted)",
+			name, In, maxInValues, len(values))
+	}
+	for _, v := range values[1:] {
+		if _, err := compareKeys(values[0], v); err != nil {
+			return fmt.Errorf("dynamo: range key %s: %v", name, err)
+		}
+	}
+	return nil
+}
+
+// compareKeys compares two key values of the same type the way DynamoDB sorts them,
+// returning -1, 0, or 1 like bytes.Compare:
+// numbers by value, and strings and binary by their bytes.
+func compareKeys(a, b *dynamodb.AttributeValue) (int, error) {
+	switch {
+	case a.S != nil && b.S != nil:
+		return strings.Compare(*a.S, *b.S), nil
+	case a.B != nil && b.B != nil:
+		return bytes.Compare(a.B, b.B), nil
+	case a.N != nil && b.N != nil:
+		x, ok := new(big.Rat).SetString(*a.N)
+		y, ok2 := new(big.Rat).SetString(*b.N)
+		if !ok || !ok2 {
+			return 0, fmt.Errorf("invalid number %s or %s", *a.N, *b.N)
+		}
+		return x.Cmp(y), nil
+	}
+	return 0, fmt.Errorf("can't compare %s and %s key values", avTypeName(a), avTypeName(b))
+}
 
-	return iter
+// filtered returns true if results are filtered after being read,
+// so DynamoDB's Limit can't be used as the number of results.
+func (q *Query) filtered() bool {
+	return len(q.filters) > 0 || q.rangeOp == In
 }
 
 // can we use the get item API?
@@ -447,6 +1219,8 @@ func (q *Query) canGetItem() bool {
 		return false
 	case q.index != "":
 		return false
+	case q.order != nil:
+		return false
 	case len(q.filters) > 0:
 		return false
 	}
@@ -454,26 +1228,41 @@ func (q *Query) canGetItem() bool {
 }
 
 func (q *Query) queryInput() *dynamodb.QueryInput {
+	keyExpr, names, values := q.keyCondition()
 	req := &dynamodb.QueryInput{
 		TableName:                 &q.table.name,
-		KeyConditions:             q.keyConditions(),
+		KeyConditionExpression:    &keyExpr,
 		ExclusiveStartKey:         q.startKey,
-		ExpressionAttributeNames:  q.nameExpr,
-		ExpressionAttributeValues: q.valueExpr,
+		ExpressionAttributeNames:  names,
+		ExpressionAttributeValues: q.table.db.emptyStringMode().values(values),
 	}
-	if q.consistent {
-		req.ConsistentRead = &q.consistent
+	if q.isConsistent() {
+		req.ConsistentRead = aws.Bool(true)
 	}
 	if q.limit > 0 {
-		if len(q.filters) == 0 {
+		if !q.filtered() {
 			req.Limit = &q.limit
 		}
 	}
+	if q.pageSize > 0 && (req.Limit == nil || q.pageSize < *req.Limit) {
+		req.Limit = &q.pageSize
+	}
 	if q.searchLimit > 0 {
 		req.Limit = &q.searchLimit
 	}
 	if q.projection != "" {
 		req.ProjectionExpression = &q.projection
+		if q.rangeOp == In {
+			// the range key is needed to drop items outside of In
+			projection := q.projection + ", " + q.rangeKeyName()
+			req.ProjectionExpression = &projection
+		}
+	}
+	if q.projectAll {
+		req.Select = selectAllAttributes
+		if q.index != "" {
+			req.Select = selectAllProjected
+		}
 	}
 	if len(q.filters) > 0 {
 		filter := strings.Join(q.filters, " AND ")
@@ -485,26 +1274,138 @@ func (q *Query) queryInput() *dynamodb.QueryInput {
 	if q.order != nil {
 		req.ScanIndexForward = (*bool)(q.order)
 	}
-	if q.cc != nil {
-		req.ReturnConsumedCapacity = aws.String(dynamodb.ReturnConsumedCapacityIndexes)
-	}
+	req.ReturnConsumedCapacity = returnConsumedCapacity(q.cc, q.stats != nil || q.table.db.readLimited())
 	return req
 }
 
-func (q *Query) keyConditions() map[string]*dynamodb.Condition {
-	conds := map[string]*dynamodb.Condition{
-		q.hashKey: &dynamodb.Condition{
-			AttributeValueList: []*dynamodb.AttributeValue{q.hashValue},
-			ComparisonOperator: aws.String(string(Equal)),
-		},
+// keyCondition returns this query's key condition expression, along with
+// copies of its name and value substitutions with the key's added.
+// The query itself is left unchanged, so this can be called for every page,
+// and GetItem requests don't receive unused substitutions.
+func (q *Query) keyCondition() (expr string, names map[string]*string, values map[string]*dynamodb.AttributeValue) {
+	keys := subber{
+		nameExpr:  make(map[string]*string, len(q.nameExpr)+2),
+		valueExpr: make(map[string]*dynamodb.AttributeValue, len(q.valueExpr)+len(q.rangeValues)+1),
+	}
+	for k, v := range q.nameExpr {
+		keys.nameExpr[k] = v
+	}
+	for k, v := range q.valueExpr {
+		keys.valueExpr[k] = v
 	}
+
+	expr = keys.subName(q.hashKey) + " = :kh"
+	keys.valueExpr[":kh"] = q.hashValue
 	if q.rangeKey != "" && q.rangeOp != "" {
-		conds[q.rangeKey] = &dynamodb.Condition{
-			AttributeValueList: q.rangeValues,
-			ComparisonOperator: aws.String(string(q.rangeOp)),
+		name := keys.subName(q.rangeKey)
+		if q.rangeOp != In {
+			for i, v := range q.rangeValues {
+				keys.valueExpr[fmt.Sprintf(":kr%d", i)] = v
+			}
+		}
+		switch q.rangeOp {
+		case BeginsWith:
+			expr += " AND begins_with(" + name + ", :kr0)"
+		case Between:
+			expr += " AND " + name + " BETWEEN :kr0 AND :kr1"
+		case In:
+			// read the smallest range covering every value; the rest are dropped by inRange,
+			// because filter expressions can't use key attributes
+			lo, hi := q.inBounds()
+			keys.valueExpr[":kr0"] = lo
+			if cmp, _ := compareKeys(lo, hi); cmp == 0 {
+				// every value is the same
+				expr += " AND " + name + " = :kr0"
+				break
+			}
+			keys.valueExpr[":kr1"] = hi
+			expr += " AND " + name + " BETWEEN :kr0 AND :kr1"
+		default:
+			// Range rejects other operators
+			sym, _ := q.rangeOp.symbol()
+			expr += " AND " + name + " " + sym + " :kr0"
+		}
+	}
+	return expr, keys.nameExpr, keys.valueExpr
+}
+
+// inBounds returns the smallest and largest values of an In range key condition.
+func (q *Query) inBounds() (lo, hi *dynamodb.AttributeValue) {
+	lo, hi = q.rangeValues[0], q.rangeValues[0]
+	for _, v := range q.rangeValues[1:] {
+		if cmp, _ := compareKeys(v, lo); cmp < 0 {
+			lo = v
+		}
+		if cmp, _ := compareKeys(v, hi); cmp > 0 {
+			hi = v
+		}
+	}
+	return lo, hi
+}
+
+// inRange returns false if item is outside of this query's In range key condition,
+// so it should be dropped from the results. Other queries match every item.
+func (q *Query) inRange(item map[string]*dynamodb.AttributeValue) bool {
+	if q.rangeOp != In {
+		return true
+	}
+	av := item[q.rangeKey]
+	if av == nil {
+		return false
+	}
+	for _, v := range q.rangeValues {
+		if cmp, err := compareKeys(av, v); err == nil && cmp == 0 {
+			return true
 		}
 	}
-	return conds
+	return false
+}
+
+// inRangeItems returns the items that match this query's In range key condition, if any.
+func (q *Query) inRangeItems(items []map[string]*dynamodb.AttributeValue) []map[string]*dynamodb.AttributeValue {
+	if q.rangeOp != In {
+		return items
+	}
+	matched := make([]map[string]*dynamodb.AttributeValue, 0, len(items))
+	for _, item := range items {
+		if q.inRange(item) {
+			matched = append(matched, item)
+		}
+	}
+	return matched
+}
+
+// matched returns the number of items in res that match this query.
+// For In, items must be fetched, never just counted, so they can be checked with inRange.
+func (q *Query) matched(res *dynamodb.QueryOutput) int64 {
+	if q.rangeOp != In {
+		return aws.Int64Value(res.Count)
+	}
+	var n int64
+	for _, item := range res.Items {
+		if q.inRange(item) {
+			n++
+		}
+	}
+	return n
+}
+
+// countInput returns the input for counting this query's results.
+// In queries fetch only the range key to check it, instead of counting.
+func (q *Query) countInput() *dynamodb.QueryInput {
+	req := q.queryInput()
+	if q.rangeOp == In {
+		req.Select = nil
+		req.ProjectionExpression = aws.String(q.rangeKeyName())
+		return req
+	}
+	req.Select = selectCount
+	return req
+}
+
+// rangeKeyName returns the substituted name of the range key, as added by keyCondition.
+func (q *Query) rangeKeyName() string {
+	return "#s" + encodeName(q.rangeKey)
 }
 
 func (q *Query) getItemInput() *dynamodb.GetItemInput {
@@ -513,15 +1414,13 @@ func (q *Query) getItemInput() *dynamodb.GetItemInput {
 		Key:                      q.keys(),
 		ExpressionAttributeNames: q.nameExpr,
 	}
-	if q.consistent {
-		req.ConsistentRead = &q.consistent
+	if q.isConsistent() {
+		req.ConsistentRead = aws.Bool(true)
 	}
 	if q.projection != "" {
 		req.ProjectionExpression = &q.projection
 	}
-	if q.cc != nil {
-		req.ReturnConsumedCapacity = aws.String(dynamodb.ReturnConsumedCapacityIndexes)
-	}
+	req.ReturnConsumedCapacity = returnConsumedCapacity(q.cc, q.stats != nil || q.table.db.readLimited())
 	return req
 }
 
@@ -554,11 +1453,13 @@ func (q *Query) keysAndAttribs() *dynamodb.KeysAndAttributes {
 	kas := &dynamodb.KeysAndAttributes{
 		Keys:                     []map[string]*dynamodb.AttributeValue{q.keys()},
 		ExpressionAttributeNames: q.nameExpr,
-		ConsistentRead:           &q.consistent,
 	}
 	if q.projection != "" {
 		kas.ProjectionExpression = &q.projection
 	}
+	if q.isConsistent() {
+		kas.ConsistentRead = aws.Bool(true)
+	}
 	return kas
 }
 
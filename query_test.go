@@ -1,9 +1,19 @@
 package dynamo
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"reflect"
+	"strconv"
+	"strings"
 	"testing"
 	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
 )
 
 func TestGetAllCount(t *testing.T) {
@@ -162,3 +172,1036 @@ func TestQueryPaging(t *testing.T) {
 		itr = table.Get("UserID", 1969).StartFrom(itr.LastEvaluatedKey()).SearchLimit(1).Iter()
 	}
 }
+
+func TestGetAll(t *testing.T) {
+	table := NewFromIface(newMockClient(9)).Table("Test")
+	q := table.GetAll("UserID", 613).PageSize(4)
+	if in := q.queryInput(); in.KeyConditionExpression == nil || strings.Contains(*in.KeyConditionExpression, "AND") {
+		t.Error("expected a hash key condition only, got", aws.StringValue(in.KeyConditionExpression))
+	}
+	var items []map[string]*dynamodb.AttributeValue
+	if err := q.All(&items); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+	if len(items) != 9 {
+		t.Error("expected every item in the partition, got", len(items))
+	}
+}
+
+func TestQueryPageSize(t *testing.T) {
+	table := Table{name: "Test"}
+
+	q := table.Get("UserID", 1969).PageSize(2)
+	if in := q.queryInput(); in.Limit == nil || *in.Limit != 2 {
+		t.Error("expected Limit of 2, got", in.Limit)
+	}
+	// a smaller total limit takes precedence
+	q = table.Get("UserID", 1969).PageSize(5).Limit(3)
+	if in := q.queryInput(); in.Limit == nil || *in.Limit != 3 {
+		t.Error("expected Limit of 3, got", in.Limit)
+	}
+	// limit is ignored with filters, but page size isn't
+	q = table.Get("UserID", 1969).PageSize(5).Limit(3).Filter("Msg = ?", "hello")
+	if in := q.queryInput(); in.Limit == nil || *in.Limit != 5 {
+		t.Error("expected Limit of 5, got", in.Limit)
+	}
+
+	if testDB == nil {
+		t.Skip(offlineSkipMsg)
+	}
+	table = testDB.Table(testTable)
+
+	widgets := []interface{}{
+		widget{UserID: 1970, Time: time.Date(1970, 4, 1, 0, 0, 0, 0, time.UTC)},
+		widget{UserID: 1970, Time: time.Date(1970, 4, 2, 0, 0, 0, 0, time.UTC)},
+		widget{UserID: 1970, Time: time.Date(1970, 4, 3, 0, 0, 0, 0, time.UTC)},
+	}
+	if _, err := table.Batch().Write().Put(widgets...).Run(); err != nil {
+		t.Fatal("couldn't write paging prep data", err)
+	}
+
+	var results []widget
+	err := table.Get("UserID", 1970).PageSize(1).All(&results)
+	if err != nil {
+		t.Error("unexpected error:", err)
+	}
+	if len(results) != len(widgets) {
+		t.Error("expected", len(widgets), "results, got", len(results))
+	}
+}
+
+func TestQueryOrder(t *testing.T) {
+	table := Table{name: "Test"}
+	q := table.Get("UserID", 1969).Order(Descending)
+	if q.canGetItem() {
+		t.Error("ordered query shouldn't use GetItem")
+	}
+	in := q.queryInput()
+	if in.ScanIndexForward == nil || *in.ScanIndexForward {
+		t.Error("expected ScanIndexForward = false, got", in.ScanIndexForward)
+	}
+	if in := table.Get("UserID", 1969).queryInput(); in.ScanIndexForward != nil {
+		t.Error("expected ScanIndexForward to be unset, got", *in.ScanIndexForward)
+	}
+}
+
+func TestQueryLimitAcrossPages(t *testing.T) {
+	type item struct {
+		Idx int
+	}
+
+	client := newMockClient(10)
+	table := NewFromIface(client).Table("Test")
+	var results []item
+	next, err := table.Get("UserID", 1).PageSize(4).Limit(6).AllWithLastEvaluatedKey(&results)
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+	if len(results) != 6 {
+		t.Error("expected 6 results, got", len(results))
+	}
+	// the second page should only ask for what's left
+	if !reflect.DeepEqual(client.limits, []int64{4, 2}) {
+		t.Error("unexpected page limits:", client.limits)
+	}
+	// so we can pick up right where we left off
+	results = nil
+	if err := table.Get("UserID", 1).StartFrom(next).All(&results); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+	if len(results) != 4 || results[0].Idx != 6 {
+		t.Error("bad results after limit:", results)
+	}
+
+	client = newMockClient(10)
+	table = NewFromIface(client).Table("Test")
+	results = nil
+	if err := table.Scan().PageSize(4).Limit(5).All(&results); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+	if len(results) != 5 || !reflect.DeepEqual(client.limits, []int64{4, 1}) {
+		t.Error("unexpected scan results:", results, client.limits)
+	}
+}
+
+type evenInt int
+
+func (e *evenInt) UnmarshalDynamo(av *dynamodb.AttributeValue) error {
+	n, err := strconv.Atoi(*av.N)
+	if err != nil {
+		return err
+	}
+	if n%2 != 0 {
+		return fmt.Errorf("odd number: %d", n)
+	}
+	*e = evenInt(n)
+	return nil
+}
+
+func TestQueryUnmarshalError(t *testing.T) {
+	type item struct {
+		Idx evenInt
+	}
+	table := NewFromIface(newMockClient(5)).Table("Test")
+
+	// results before the error are kept
+	var results []item
+	err := table.Get("UserID", 1).PageSize(2).All(&results)
+	var uerr *UnmarshalError
+	if !errors.As(err, &uerr) {
+		t.Fatal("expected UnmarshalError, got", err)
+	}
+	if uerr.Index != 1 || *uerr.Item["Idx"].N != "1" {
+		t.Error("bad error:", uerr.Index, uerr.Item)
+	}
+	if len(results) != 1 {
+		t.Error("expected 1 result, got", results)
+	}
+
+	// bad results can be skipped
+	var skipped []int64
+	results = nil
+	err = table.Scan().PageSize(2).OnUnmarshalError(func(err *UnmarshalError) error {
+		skipped = append(skipped, err.Index)
+		return nil
+	}).All(&results)
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+	if !reflect.DeepEqual(results, []item{{0}, {2}, {4}}) {
+		t.Error("bad results:", results)
+	}
+	if !reflect.DeepEqual(skipped, []int64{1, 3}) {
+		t.Error("bad skipped indexes:", skipped)
+	}
+
+	// skipped results don't count toward the limit
+	skip := func(err *UnmarshalError) error {
+		skipped = append(skipped, err.Index)
+		return nil
+	}
+	skipped, results = nil, nil
+	if err := table.Get("UserID", 1).Limit(2).OnUnmarshalError(skip).All(&results); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+	if !reflect.DeepEqual(results, []item{{0}, {2}}) || !reflect.DeepEqual(skipped, []int64{1}) {
+		t.Error("bad query results with limit:", results, skipped)
+	}
+	skipped, results = nil, nil
+	if err := table.Scan().Limit(2).OnUnmarshalError(skip).All(&results); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+	if !reflect.DeepEqual(results, []item{{0}, {2}}) || !reflect.DeepEqual(skipped, []int64{1}) {
+		t.Error("bad scan results with limit:", results, skipped)
+	}
+}
+
+func TestQueryPagingLastEvaluatedKey(t *testing.T) {
+	if testDB == nil {
+		t.Skip(offlineSkipMsg)
+	}
+	table := testDB.Table(testTable)
+
+	var all []widget
+	if err := table.Get("UserID", 1969).Consistent(true).All(&all); err != nil {
+		t.Fatal(err)
+	}
+
+	var paged []widget
+	var key PagingKey
+	for {
+		var page []widget
+		next, err := table.Get("UserID", 1969).Consistent(true).SearchLimit(1).StartFrom(key).AllWithLastEvaluatedKey(&page)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(page) > 1 {
+			t.Error("page too large:", len(page))
+		}
+		paged = append(paged, page...)
+		if next == nil {
+			break
+		}
+		key = next
+	}
+	if !reflect.DeepEqual(all, paged) {
+		t.Error("paged results don't match:", all, "≠", paged)
+	}
+}
+
+func TestQueryCancelBetweenPages(t *testing.T) {
+	type item struct {
+		Idx int
+	}
+	db := NewFromIface(newMockClient(10))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var calls int
+	db.SetHook(func(ctx aws.Context, op string, input, output interface{}, err error, d time.Duration) {
+		calls++
+		cancel()
+	})
+
+	var results []item
+	err := db.Table("Test").Get("UserID", 1).PageSize(2).AllWithContext(ctx, &results)
+	if err != context.Canceled {
+		t.Error("expected context.Canceled, got", err)
+	}
+	if calls != 1 || len(results) != 2 {
+		t.Error("expected 1 page of results, got", calls, "pages:", results)
+	}
+
+	calls = 0
+	ctx, cancel = context.WithCancel(context.Background())
+	defer cancel()
+	count, err := db.Table("Test").Scan().PageSize(2).CountWithContext(ctx)
+	if err != context.Canceled {
+		t.Error("expected context.Canceled, got", err)
+	}
+	if calls != 1 || count != 2 {
+		t.Error("expected a partial count of 1 page, got", calls, "pages:", count)
+	}
+
+	// queries also return the partial counts
+	calls = 0
+	ctx, cancel = context.WithCancel(context.Background())
+	defer cancel()
+	matched, scanned, err := db.Table("Test").Get("UserID", 1).PageSize(2).CountDetailedWithContext(ctx)
+	if err != context.Canceled {
+		t.Error("expected context.Canceled, got", err)
+	}
+	if calls != 1 || matched != 2 || scanned != 2 {
+		t.Error("expected partial counts of 1 page, got", calls, "pages:", matched, scanned)
+	}
+}
+
+func TestQueryDebugInput(t *testing.T) {
+	table := Table{name: "Test"}
+	query, get, err := table.Get("UserID", 1).Range("Time", Equal, "x").Project("Count").DebugInput()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if query == nil || *query.TableName != "Test" {
+		t.Error("bad query input:", query)
+	}
+	if get == nil || *get.Key["UserID"].N != "1" || len(get.ExpressionAttributeNames) != 1 {
+		t.Error("bad get input:", get)
+	}
+
+	_, get, err = table.Get("UserID", 1).Filter("'Count' > ?", 1).DebugInput()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if get != nil {
+		t.Error("filtered query shouldn't use GetItem:", get)
+	}
+
+	if _, _, err := table.Get("UserID", 1).Filter("A = ?", make(chan int)).DebugInput(); err == nil {
+		t.Error("expected error")
+	}
+}
+
+func TestQueryDefaultConsistent(t *testing.T) {
+	table := Table{name: "Test"}.DefaultConsistent(true)
+	tests := []struct {
+		name   string
+		query  *Query
+		expect bool
+	}{
+		{"default", table.Get("UserID", 1), true},
+		{"override", table.Get("UserID", 1).Consistent(false), false},
+		{"index", table.Get("Msg", "hi").Index("Msg-index"), false},
+		{"index override", table.Get("Msg", "hi").Index("Msg-index").Consistent(true), true},
+		{"not default", Table{name: "Test"}.Get("UserID", 1), false},
+	}
+	for _, test := range tests {
+		if got := aws.BoolValue(test.query.queryInput().ConsistentRead); got != test.expect {
+			t.Errorf("%s: bad query consistency: %v ≠ %v", test.name, got, test.expect)
+		}
+		if got := aws.BoolValue(test.query.getItemInput().ConsistentRead); got != test.expect {
+			t.Errorf("%s: bad get consistency: %v ≠ %v", test.name, got, test.expect)
+		}
+	}
+}
+
+func TestQueryKeyCondition(t *testing.T) {
+	table := Table{name: "Test"}
+	tests := []struct {
+		op     Operator
+		values []interface{}
+		expect string
+	}{
+		{Equal, []interface{}{1}, "$h = :kh AND $r = :kr0"},
+		{Less, []interface{}{1}, "$h = :kh AND $r < :kr0"},
+		{GreaterOrEqual, []interface{}{1}, "$h = :kh AND $r >= :kr0"},
+		{BeginsWith, []interface{}{"a"}, "$h = :kh AND begins_with($r, :kr0)"},
+		{Between, []interface{}{1, 2}, "$h = :kh AND $r BETWEEN :kr0 AND :kr1"},
+	}
+	for _, test := range tests {
+		q := table.Get("UserID", 1).Range("Time", test.op, test.values...).Filter("Msg = ?", "hi")
+		if q.err != nil {
+			t.Fatal(test.op, "unexpected error:", q.err)
+		}
+		in := q.queryInput()
+		expect := strings.NewReplacer("$h", q.subName("UserID"), "$r", q.subName("Time")).Replace(test.expect)
+		if got := aws.StringValue(in.KeyConditionExpression); got != expect {
+			t.Errorf("%s: bad key condition: %s ≠ %s", test.op, got, expect)
+		}
+		// filter value plus key values
+		if len(in.ExpressionAttributeValues) != len(test.values)+2 {
+			t.Errorf("%s: bad values: %v", test.op, in.ExpressionAttributeValues)
+		}
+	}
+
+	// key substitutions don't leak into the query or GetItem
+	q := table.Get("UserID", 1).Range("Time", Equal, 2)
+	q.queryInput()
+	if len(q.nameExpr) != 0 || len(q.valueExpr) != 0 || q.getItemInput().ExpressionAttributeNames != nil {
+		t.Error("key condition modified the query's substitutions")
+	}
+
+	if err := table.Get("UserID", 1).Range("Time", Operator("NOPE"), 1).Validate(); err == nil {
+		t.Error("expected error for bad operator")
+	}
+}
+
+func TestQueryRangeBeginsWith(t *testing.T) {
+	table := Table{name: "Test"}
+
+	q := table.Get("PK", "ORG#123").RangeBeginsWith("SK", "USER#")
+	if q.err != nil {
+		t.Fatal("unexpected error:", q.err)
+	}
+	in := q.queryInput()
+	if expr, want := aws.StringValue(in.KeyConditionExpression), "begins_with("+q.subName("SK")+", :kr0)"; !strings.HasSuffix(expr, want) {
+		t.Error("bad key condition:", expr)
+	}
+	if v := in.ExpressionAttributeValues[":kr0"]; aws.StringValue(v.S) != "USER#" {
+		t.Error("bad string prefix:", v)
+	}
+
+	q = table.Get("PK", "ORG#123").Range("SK", BeginsWith, []byte{0x01, 0x02})
+	if q.err != nil {
+		t.Fatal("unexpected error:", q.err)
+	}
+	in = q.queryInput()
+	if v := in.ExpressionAttributeValues[":kr0"]; !reflect.DeepEqual(v.B, []byte{0x01, 0x02}) {
+		t.Error("bad binary prefix:", v)
+	}
+	if q.canGetItem() {
+		t.Error("begins_with can't use GetItem")
+	}
+
+	if err := table.Get("PK", "ORG#123").RangeBeginsWith("SK", 123).Validate(); err == nil {
+		t.Error("expected error for number prefix")
+	}
+	if err := table.Get("PK", "ORG#123").RangeBeginsWith("SK", []string{"a"}).Validate(); err == nil {
+		t.Error("expected error for list prefix")
+	}
+}
+
+func TestQueryRangeValueCount(t *testing.T) {
+	table := Table{name: "Test"}
+	tests := []struct {
+		op     Operator
+		values []interface{}
+		ok     bool
+	}{
+		{Equal, []interface{}{1}, true},
+		{Between, []interface{}{1, 2}, true},
+		{Between, []interface{}{1}, false},
+		{BeginsWith, []interface{}{"a", "b"}, false},
+		{Greater, nil, false},
+		// empty strings are omitted
+		{Equal, []interface{}{""}, false},
+	}
+	for _, test := range tests {
+		q := table.Get("UserID", 1).Range("Time", test.op, test.values...)
+		if (q.err == nil) != test.ok {
+			t.Errorf("%s %v: unexpected error: %v", test.op, test.values, q.err)
+		}
+	}
+}
+
+func TestQueryOneOrZero(t *testing.T) {
+	type item struct {
+		Idx int
+	}
+
+	table := NewFromIface(newMockClient(0)).Table("Test")
+	result := item{Idx: -1}
+	found, err := table.Get("UserID", 1).Range("Time", BeginsWith, "2020").OneOrZero(&result)
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+	if found || result.Idx != -1 {
+		t.Error("expected nothing found, got", found, result)
+	}
+
+	table = NewFromIface(newMockClient(1)).Table("Test")
+	found, err = table.Get("UserID", 1).Range("Time", BeginsWith, "2020").OneOrZero(&result)
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+	if !found || result.Idx != 0 {
+		t.Error("expected item to be found, got", found, result)
+	}
+
+	table = NewFromIface(newMockClient(2)).Table("Test")
+	if found, err := table.Get("UserID", 1).Range("Time", BeginsWith, "2020").OneOrZero(&result); err != ErrTooMany || found {
+		t.Error("expected ErrTooMany, got", found, err)
+	}
+}
+
+func TestQueryOneRaw(t *testing.T) {
+	table := NewFromIface(newMockClient(2)).Table("Test")
+
+	// GetItem
+	item, err := table.Get("Idx", 1).OneRaw()
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+	if idx := item["Idx"]; idx == nil || aws.StringValue(idx.N) != "1" {
+		t.Error("bad item:", item)
+	}
+	if _, err := table.Get("Idx", 5).OneRaw(); err != ErrNotFound {
+		t.Error("expected ErrNotFound, got", err)
+	}
+
+	// Query
+	if _, err := table.Get("UserID", 1).Index("UserID-index").OneRaw(); err != ErrTooMany {
+		t.Error("expected ErrTooMany, got", err)
+	}
+	if _, err := NewFromIface(newMockClient(0)).Table("Test").Get("UserID", 1).Index("UserID-index").OneRaw(); err != ErrNotFound {
+		t.Error("expected ErrNotFound, got", err)
+	}
+}
+
+func TestQueryValidate(t *testing.T) {
+	table := Table{name: "Test"}
+	if err := table.Get("UserID", 1).Filter("Msg = ?", "hi").Validate(); err != nil {
+		t.Error("unexpected error:", err)
+	}
+	if err := table.Get("UserID", 1).Filter("$ = ?", "Msg").Validate(); err == nil {
+		t.Error("expected error for missing placeholder argument")
+	}
+	if err := table.Scan().ProjectExpr("$, $", "A").Validate(); err == nil {
+		t.Error("expected error for missing placeholder argument")
+	}
+}
+
+func TestQueryIndexConsistency(t *testing.T) {
+	table := Table{name: "Test"}
+
+	q := table.Get("Msg", "hi").LocalIndex("Msg-local").Consistent(true)
+	if err := q.Validate(); err != nil {
+		t.Error("unexpected error for consistent local index:", err)
+	}
+	if in := q.queryInput(); aws.StringValue(in.IndexName) != "Msg-local" || !aws.BoolValue(in.ConsistentRead) {
+		t.Error("bad local index input:", in)
+	}
+
+	if err := table.Get("Msg", "hi").GlobalIndex("Msg-global").Consistent(true).Validate(); err != ErrConsistentGlobalIndex {
+		t.Error("expected ErrConsistentGlobalIndex, got", err)
+	}
+	if err := table.Get("Msg", "hi").Consistent(true).GlobalIndex("Msg-global").Validate(); err != ErrConsistentGlobalIndex {
+		t.Error("expected ErrConsistentGlobalIndex regardless of order, got", err)
+	}
+	q = table.Get("Msg", "hi").GlobalIndex("Msg-global").Consistent(false)
+	if err := q.Validate(); err != nil {
+		t.Error("unexpected error for eventually consistent global index:", err)
+	}
+	if in := q.queryInput(); aws.StringValue(in.IndexName) != "Msg-global" || aws.BoolValue(in.ConsistentRead) {
+		t.Error("bad global index input:", in)
+	}
+
+	// the table's default doesn't apply to indexes
+	table.consistent = true
+	if err := table.Get("Msg", "hi").GlobalIndex("Msg-global").Validate(); err != nil {
+		t.Error("unexpected error for global index of consistent table:", err)
+	}
+
+	// Index is lenient, leaving it to DynamoDB
+	if err := table.Get("Msg", "hi").Index("Msg-global").Consistent(true).Validate(); err != nil {
+		t.Error("unexpected error for Index:", err)
+	}
+}
+
+func TestQueryCountDetailed(t *testing.T) {
+	table := NewFromIface(newMockClient(9)).Table("Test")
+	matched, scanned, err := table.Get("UserID", 1).Filter("Idx > ?", 0).PageSize(4).CountDetailed()
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+	if matched != 5 || scanned != 9 {
+		t.Error("expected 5 matched of 9 scanned, got", matched, scanned)
+	}
+
+	count, err := table.Get("UserID", 1).Filter("Idx > ?", 0).Count()
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+	if count != matched {
+		t.Error("expected Count to equal matched count, got", count)
+	}
+}
+
+func TestQueryConsumedCapacityLevel(t *testing.T) {
+	table := Table{name: "Test"}
+	if in := table.Get("UserID", 1).queryInput(); in.ReturnConsumedCapacity != nil {
+		t.Error("expected no consumed capacity by default, got", *in.ReturnConsumedCapacity)
+	}
+	if in := table.Get("UserID", 1).Stats(&QueryStats{}).queryInput(); aws.StringValue(in.ReturnConsumedCapacity) != dynamodb.ReturnConsumedCapacityTotal {
+		t.Error("expected TOTAL for stats, got", aws.StringValue(in.ReturnConsumedCapacity))
+	}
+	in := table.Get("UserID", 1).Stats(&QueryStats{}).ConsumedCapacity(&ConsumedCapacity{}).queryInput()
+	if aws.StringValue(in.ReturnConsumedCapacity) != dynamodb.ReturnConsumedCapacityIndexes {
+		t.Error("expected INDEXES for consumed capacity, got", aws.StringValue(in.ReturnConsumedCapacity))
+	}
+	if in := table.Get("UserID", 1).ConsumedCapacity(&ConsumedCapacity{}).getItemInput(); aws.StringValue(in.ReturnConsumedCapacity) != dynamodb.ReturnConsumedCapacityIndexes {
+		t.Error("expected INDEXES for GetItem, got", aws.StringValue(in.ReturnConsumedCapacity))
+	}
+	if in := table.Scan().scanInput(); in.ReturnConsumedCapacity != nil {
+		t.Error("expected no consumed capacity for scan by default, got", *in.ReturnConsumedCapacity)
+	}
+}
+
+func TestQueryStats(t *testing.T) {
+	var inputs []*dynamodb.QueryInput
+	db := NewFromIface(newMockClient(9))
+	db.SetHook(func(_ aws.Context, op string, input, _ interface{}, _ error, _ time.Duration) {
+		inputs = append(inputs, input.(*dynamodb.QueryInput))
+	})
+	table := db.Table("Test")
+
+	var stats QueryStats
+	var items []map[string]*dynamodb.AttributeValue
+	err := table.Get("UserID", 1).Filter("Idx > ?", 0).PageSize(4).Stats(&stats).All(&items)
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+	want := QueryStats{Count: 5, ScannedCount: 9, ConsumedCapacity: 1.5}
+	if stats != want {
+		t.Error("bad stats. want:", want, "got:", stats)
+	}
+	for _, in := range inputs {
+		if rcc := aws.StringValue(in.ReturnConsumedCapacity); rcc != dynamodb.ReturnConsumedCapacityTotal {
+			t.Error("bad ReturnConsumedCapacity:", rcc)
+		}
+	}
+
+	// stats accumulate across queries
+	if _, err := table.Get("UserID", 1).Stats(&stats).Count(); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+	want = QueryStats{Count: 14, ScannedCount: 18, ConsumedCapacity: 2}
+	if stats != want {
+		t.Error("bad accumulated stats. want:", want, "got:", stats)
+	}
+}
+
+func TestQueryCountPage(t *testing.T) {
+	table := NewFromIface(newMockClient(9)).Table("Test")
+	var counts []int64
+	var key PagingKey
+	for {
+		count, next, err := table.Get("UserID", 1).PageSize(4).StartFrom(key).CountPage()
+		if err != nil {
+			t.Fatal("unexpected error:", err)
+		}
+		counts = append(counts, count)
+		if next == nil {
+			break
+		}
+		key = next
+	}
+	if !reflect.DeepEqual(counts, []int64{4, 4, 1}) {
+		t.Error("bad page counts:", counts)
+	}
+}
+
+func TestQueryProjectAll(t *testing.T) {
+	table := Table{name: "Test"}
+	if sel := aws.StringValue(table.Get("UserID", 1).ProjectAll().queryInput().Select); sel != "ALL_ATTRIBUTES" {
+		t.Error("bad select for table:", sel)
+	}
+	in := table.Get("Msg", "hi").Index("Msg-index").Project("Msg").ProjectAll().queryInput()
+	if sel := aws.StringValue(in.Select); sel != "ALL_PROJECTED_ATTRIBUTES" || in.ProjectionExpression != nil {
+		t.Error("bad select for index:", sel, in.ProjectionExpression)
+	}
+	in = table.Get("UserID", 1).ProjectAll().Project("Msg").queryInput()
+	if in.Select != nil || in.ProjectionExpression == nil {
+		t.Error("Project should replace ProjectAll:", in)
+	}
+}
+
+func TestQueryCountIndex(t *testing.T) {
+	table := NewFromIface(newMockClient(9)).Table("Test")
+	q := table.Get("Msg", "hi").Index("Msg-index")
+	input := q.queryInput()
+	if aws.StringValue(input.IndexName) != "Msg-index" || len(input.ExpressionAttributeNames) != 1 {
+		t.Error("bad index query input:", input)
+	}
+	count, err := q.Count()
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+	if count != 9 {
+		t.Error("bad count:", count)
+	}
+}
+
+func TestGetItemStructKey(t *testing.T) {
+	type base struct {
+		UserID int `dynamo:",hash"`
+	}
+	type post struct {
+		base
+		Time time.Time `dynamo:"Time,range,unixtime"`
+		Msg  string
+	}
+	table := Table{name: "Test"}
+	now := time.Unix(1600000000, 0)
+
+	_, get, err := table.GetItem(&post{base: base{UserID: 42}, Time: now, Msg: "ignored"}).DebugInput()
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+	if get == nil || len(get.Key) != 2 || *get.Key["UserID"].N != "42" || *get.Key["Time"].N != "1600000000" {
+		t.Error("bad key:", get)
+	}
+
+	type noHash struct {
+		ID string
+	}
+	if _, _, err := table.GetItem(noHash{ID: "x"}).DebugInput(); err == nil {
+		t.Error("expected error for key without hash field")
+	}
+	type stringKey struct {
+		ID string `dynamo:",hash"`
+	}
+	if _, _, err := table.GetItem(stringKey{}).DebugInput(); err == nil {
+		t.Error("expected error for empty hash key")
+	}
+	if _, _, err := table.GetItem("x").DebugInput(); err == nil {
+		t.Error("expected error for non-struct key")
+	}
+}
+
+func TestQueryIndexFilterBaseKeys(t *testing.T) {
+	table := Table{name: "Test"}
+	q := table.Get("Seq", 7).Index("Seq-ID-index").Range("'User ID'", Greater, "a").
+		Filter("'User ID' <> ? AND $ = ? AND 'Seq' > ?", "b", "Time", "2020", 1)
+	if err := q.Validate(); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+	in := q.queryInput()
+	if aws.StringValue(in.IndexName) != "Seq-ID-index" {
+		t.Error("bad index:", aws.StringValue(in.IndexName))
+	}
+
+	// every placeholder must be defined exactly once and every definition must be used,
+	// or DynamoDB rejects the request
+	exprs := aws.StringValue(in.KeyConditionExpression) + " " + aws.StringValue(in.FilterExpression)
+	used := make(map[string]bool)
+	for _, field := range strings.FieldsFunc(exprs, func(r rune) bool {
+		return r == ' ' || r == '(' || r == ')' || r == ','
+	}) {
+		if strings.HasPrefix(field, "#") || strings.HasPrefix(field, ":") {
+			used[field] = true
+		}
+	}
+	for sub := range used {
+		switch {
+		case strings.HasPrefix(sub, "#") && in.ExpressionAttributeNames[sub] == nil:
+			t.Error("undefined name placeholder:", sub)
+		case strings.HasPrefix(sub, ":") && in.ExpressionAttributeValues[sub] == nil:
+			t.Error("undefined value placeholder:", sub)
+		}
+	}
+	for sub := range in.ExpressionAttributeNames {
+		if !used[sub] {
+			t.Error("unused name placeholder:", sub)
+		}
+	}
+	for sub := range in.ExpressionAttributeValues {
+		if !used[sub] {
+			t.Error("unused value placeholder:", sub)
+		}
+	}
+	if len(in.ExpressionAttributeValues) != 5 {
+		t.Error("expected 2 key values and 3 filter values, got", in.ExpressionAttributeValues)
+	}
+	if name := in.ExpressionAttributeNames[q.subName("User ID")]; aws.StringValue(name) != "User ID" {
+		t.Error("key condition and filter should share the name placeholder for 'User ID', got", in.ExpressionAttributeNames)
+	}
+}
+
+func TestQueryIndexHashOnly(t *testing.T) {
+	// the table's hash key is UserID, but the index's hash key is Msg
+	table := Table{name: "Test"}
+	q := table.Get("Msg", "hello").Index("Msg-index").Filter("UserID > ?", 1)
+	if q.err != nil {
+		t.Fatal("unexpected error:", q.err)
+	}
+	in := q.queryInput()
+	if got := aws.StringValue(in.IndexName); got != "Msg-index" {
+		t.Error("bad index name:", got)
+	}
+	msg := q.subName("Msg")
+	if got, want := aws.StringValue(in.KeyConditionExpression), msg+" = :kh"; got != want {
+		t.Errorf("bad key condition: %s ≠ %s", got, want)
+	}
+	if got := aws.StringValue(in.ExpressionAttributeNames[msg]); got != "Msg" {
+		t.Errorf("bad key name: %s ≠ Msg", got)
+	}
+	if got := aws.StringValue(in.ExpressionAttributeValues[":kh"].S); got != "hello" {
+		t.Errorf("bad key value: %s ≠ hello", got)
+	}
+	if got := aws.StringValue(in.FilterExpression); !strings.Contains(got, "UserID") {
+		t.Errorf("bad filter: %s", got)
+	}
+	if in.Select != nil || in.ConsistentRead != nil {
+		t.Errorf("unexpected select or consistent read: %v %v", in.Select, in.ConsistentRead)
+	}
+}
+
+func TestQueryEach(t *testing.T) {
+	client := newMockClient(9)
+	table := NewFromIface(client).Table("Test")
+
+	var seen []int
+	err := table.Get("UserID", 1).PageSize(4).Each(func(item map[string]*dynamodb.AttributeValue) error {
+		var v struct{ Idx int }
+		if err := UnmarshalItem(item, &v); err != nil {
+			return err
+		}
+		seen = append(seen, v.Idx)
+		return nil
+	})
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+	if !reflect.DeepEqual(seen, []int{0, 1, 2, 3, 4, 5, 6, 7, 8}) {
+		t.Error("bad items:", seen)
+	}
+
+	// an error from fn stops pagination
+	client.limits = nil
+	stop := errors.New("stop")
+	n := 0
+	err = table.Get("UserID", 1).PageSize(4).Each(func(item map[string]*dynamodb.AttributeValue) error {
+		n++
+		if n == 2 {
+			return stop
+		}
+		return nil
+	})
+	if err != stop {
+		t.Error("expected fn's error, got:", err)
+	}
+	if len(client.limits) != 1 {
+		t.Error("expected 1 request, got:", len(client.limits))
+	}
+}
+
+func TestQueryExists(t *testing.T) {
+	db := NewFromIface(newMockClient(9))
+	var inputs []interface{}
+	db.SetHook(func(_ aws.Context, _ string, input, _ interface{}, _ error, _ time.Duration) {
+		inputs = append(inputs, input)
+	})
+	table := db.Table("Test")
+
+	exists, err := table.Get("Idx", 3).Consistent(true).Exists()
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+	if !exists {
+		t.Error("expected item 3 to exist")
+	}
+	get := inputs[0].(*dynamodb.GetItemInput)
+	if proj := aws.StringValue(get.ProjectionExpression); aws.StringValue(get.ExpressionAttributeNames[proj]) != "Idx" {
+		t.Errorf("bad projection: %s %v", proj, get.ExpressionAttributeNames)
+	}
+	if !aws.BoolValue(get.ConsistentRead) {
+		t.Error("expected consistent read")
+	}
+
+	if exists, err := table.Get("Idx", 42).Exists(); err != nil || exists {
+		t.Error("expected item 42 not to exist, got:", exists, err)
+	}
+
+	// odd items are filtered out, so the first page is empty
+	inputs = nil
+	start := PagingKey{"Idx": {N: aws.String("0")}}
+	exists, err = table.Get("UserID", 1).Filter("Idx > ?", 0).PageSize(1).StartFrom(start).Exists()
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+	if !exists {
+		t.Error("expected an item to exist")
+	}
+	if len(inputs) != 2 {
+		t.Fatal("expected 2 requests, got:", len(inputs))
+	}
+	if sel := aws.StringValue(inputs[0].(*dynamodb.QueryInput).Select); sel != "COUNT" {
+		t.Error("bad select:", sel)
+	}
+}
+
+// optionsClient records the request options passed to QueryWithContext.
+type optionsClient struct {
+	*mockClient
+	opts []request.Option
+}
+
+func (c *optionsClient) QueryWithContext(ctx aws.Context, in *dynamodb.QueryInput, opts ...request.Option) (*dynamodb.QueryOutput, error) {
+	c.opts = append(c.opts, opts...)
+	return c.mockClient.QueryWithContext(ctx, in, opts...)
+}
+
+func TestQueryRequestOptions(t *testing.T) {
+	client := &optionsClient{mockClient: newMockClient(4)}
+	table := NewFromIface(client).Table("Test")
+
+	var items []map[string]interface{}
+	opt := request.WithLogLevel(aws.LogDebug)
+	if err := table.Get("UserID", 1).PageSize(2).RequestOptions(opt).All(&items); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+	// both pages' requests get the option
+	if len(client.opts) != 2 {
+		t.Error("expected 2 options, got:", len(client.opts))
+	}
+}
+
+func TestQueryRangeIn(t *testing.T) {
+	table := Table{name: "Test"}
+	q := table.Get("UserID", 1).Range("Seq", In, 5, 1, 10).Filter("Msg = ?", "hi").Limit(2)
+	if q.err != nil {
+		t.Fatal("unexpected error:", q.err)
+	}
+	in := q.queryInput()
+	h, r := q.subName("UserID"), q.subName("Seq")
+	if got, want := aws.StringValue(in.KeyConditionExpression), h+" = :kh AND "+r+" BETWEEN :kr0 AND :kr1"; got != want {
+		t.Errorf("bad key condition: %s ≠ %s", got, want)
+	}
+	if lo, hi := aws.StringValue(in.ExpressionAttributeValues[":kr0"].N), aws.StringValue(in.ExpressionAttributeValues[":kr1"].N); lo != "1" || hi != "10" {
+		t.Error("bad bounds:", lo, hi)
+	}
+	// DynamoDB doesn't allow key attributes in filters
+	if got, want := aws.StringValue(in.FilterExpression), "(Msg = :v0)"; got != want {
+		t.Errorf("bad filter: %s ≠ %s", got, want)
+	}
+	if in.Limit != nil {
+		t.Error("unexpected limit with In:", *in.Limit)
+	}
+	if len(q.filters) != 1 {
+		t.Error("key condition modified the query's filters:", q.filters)
+	}
+
+	// the range key is projected so items can be checked against the set
+	in = table.Get("UserID", 1).Range("Seq", In, 1, 2).Project("Msg").queryInput()
+	if got, want := aws.StringValue(in.ProjectionExpression), "Msg, "+r; got != want {
+		t.Errorf("bad projection: %s ≠ %s", got, want)
+	}
+
+	// strings sort by bytes, and a single value needs no range
+	in = table.Get("UserID", 1).Range("Name", In, "b", "B", "a").queryInput()
+	if lo, hi := aws.StringValue(in.ExpressionAttributeValues[":kr0"].S), aws.StringValue(in.ExpressionAttributeValues[":kr1"].S); lo != "B" || hi != "b" {
+		t.Error("bad string bounds:", lo, hi)
+	}
+	in = table.Get("UserID", 1).Range("Name", In, "x").queryInput()
+	if got := aws.StringValue(in.KeyConditionExpression); !strings.HasSuffix(got, " = :kr0") || in.FilterExpression != nil {
+		t.Error("bad single value key condition:", got, in.FilterExpression)
+	}
+
+	if err := table.Get("UserID", 1).Range("Seq", In, 1, "a").Validate(); err == nil {
+		t.Error("expected error for mixed types")
+	}
+	if err := table.Get("UserID", 1).Range("Seq", In).Validate(); err == nil {
+		t.Error("expected error for no values")
+	}
+}
+
+func TestQueryRangeInDropsOthers(t *testing.T) {
+	type item struct {
+		Idx int
+	}
+	// the mock returns every item in the range, like DynamoDB would for BETWEEN
+	table := NewFromIface(newMockClient(10)).Table("Test")
+
+	var results []item
+	if err := table.Get("ID", 1).Range("Idx", In, 6, 1, 4).Limit(2).All(&results); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+	if !reflect.DeepEqual(results, []item{{1}, {4}}) {
+		t.Error("bad results:", results)
+	}
+
+	count, err := table.Get("ID", 1).Range("Idx", In, 6, 1, 4).Count()
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+	if count != 3 {
+		t.Error("bad count:", count)
+	}
+
+	var one item
+	if err := table.Get("ID", 1).Range("Idx", In, 7).Index("Idx-index").One(&one); err != nil || one.Idx != 7 {
+		t.Error("bad One result:", one, err)
+	}
+	if err := table.Get("ID", 1).Range("Idx", In, 20).Index("Idx-index").One(&one); err != ErrNotFound {
+		t.Error("expected ErrNotFound, got", err)
+	}
+}
+
+func TestQueryRangeInBatchGet(t *testing.T) {
+	type item struct {
+		UserID int
+		Seq    int
+	}
+	client := newMockClient(0)
+	table := NewFromIface(client).Table("Test")
+
+	var results []item
+	err := table.Get("UserID", 1).Range("Seq", In, 5, 1, 10, 1).InStrategy(InBatchGet).Consistent(true).All(&results)
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+	// the mock responds with the requested keys, without the duplicate
+	if !reflect.DeepEqual(results, []item{{1, 5}, {1, 1}, {1, 10}}) {
+		t.Error("bad results:", results)
+	}
+	if !reflect.DeepEqual(client.batchSizes, []int{3}) {
+		t.Error("expected one BatchGetItem request, got", client.batchSizes)
+	}
+
+	itr := table.Get("UserID", 1).Range("Seq", In, 2).InStrategy(InBatchGet).Iter()
+	var one item
+	if !itr.Next(&one) || one != (item{1, 2}) {
+		t.Error("bad iter result:", one, itr.Err())
+	}
+	if itr.Next(&one) || itr.LastEvaluatedKey() != nil {
+		t.Error("expected no more results or paging key")
+	}
+
+	err = table.Get("UserID", 1).Range("Seq", In, 1, 2).InStrategy(InBatchGet).Index("Idx").All(&results)
+	if err == nil {
+		t.Error("expected error using InBatchGet with an index")
+	}
+}
+
+// missingTableClient fails every read as if the table doesn't exist.
+type missingTableClient struct {
+	*mockClient
+}
+
+func (missingTableClient) err() error {
+	return awserr.NewRequestFailure(awserr.New(dynamodb.ErrCodeResourceNotFoundException, "Requested resource not found", nil), 400, "")
+}
+
+func (c missingTableClient) GetItemWithContext(aws.Context, *dynamodb.GetItemInput, ...request.Option) (*dynamodb.GetItemOutput, error) {
+	return nil, c.err()
+}
+
+func (c missingTableClient) QueryWithContext(aws.Context, *dynamodb.QueryInput, ...request.Option) (*dynamodb.QueryOutput, error) {
+	return nil, c.err()
+}
+
+func (c missingTableClient) ScanWithContext(aws.Context, *dynamodb.ScanInput, ...request.Option) (*dynamodb.ScanOutput, error) {
+	return nil, c.err()
+}
+
+func TestQueryTableNotFound(t *testing.T) {
+	table := NewFromIface(missingTableClient{newMockClient(0)}).Table("Typo")
+	var item map[string]*dynamodb.AttributeValue
+	var items []map[string]*dynamodb.AttributeValue
+	checks := map[string]func() error{
+		"GetItem One": func() error { return table.Get("ID", 1).One(&item) },
+		"Query One":   func() error { return table.Get("ID", 1).Index("ID-index").One(&item) },
+		"All":         func() error { return table.Get("ID", 1).All(&items) },
+		"Count":       func() error { _, err := table.Get("ID", 1).Count(); return err },
+		"Iter": func() error {
+			itr := table.Get("ID", 1).Iter()
+			itr.Next(&item)
+			return itr.Err()
+		},
+		"Scan All":   func() error { return table.Scan().All(&items) },
+		"Scan Count": func() error { _, err := table.Scan().Count(); return err },
+	}
+	for name, check := range checks {
+		err := check()
+		if !errors.Is(err, ErrTableNotFound) {
+			t.Errorf("%s: expected ErrTableNotFound, got %v", name, err)
+		}
+		if errors.Is(err, ErrNotFound) {
+			t.Errorf("%s: a missing table shouldn't match ErrNotFound", name)
+		}
+		var ae awserr.RequestFailure
+		if !errors.As(err, &ae) || ae.Code() != dynamodb.ErrCodeResourceNotFoundException {
+			t.Errorf("%s: original error should be wrapped, got %v", name, err)
+		}
+	}
+}
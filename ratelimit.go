@@ -0,0 +1,106 @@
+package dynamo
+
+import (
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+// SetReadRateLimit paces reads made by queries, scans, and batch gets to stay under rcu read capacity units per second,
+// measured by the consumed capacity DynamoDB returns for each request.
+// When the limit is exceeded, the next request waits until enough capacity has been regained.
+// Up to one second of unused capacity may be saved for bursts.
+// The limit is shared by every request this DB makes, including the segments of parallel scans.
+// Passing 0 removes the limit.
+// It should be called before making requests, as it is not safe to change concurrently.
+func (db *DB) SetReadRateLimit(rcu int) {
+	db.reads = newRateLimiter(rcu)
+}
+
+// SetWriteRateLimit paces batch writes to stay under wcu write capacity units per second,
+// measured by the consumed capacity DynamoDB returns for each request.
+// It works the same way as SetReadRateLimit.
+// Passing 0 removes the limit.
+// It should be called before making requests, as it is not safe to change concurrently.
+func (db *DB) SetWriteRateLimit(wcu int) {
+	db.writes = newRateLimiter(wcu)
+}
+
+func (db *DB) readLimited() bool {
+	return db != nil && db.reads != nil
+}
+
+func (db *DB) writeLimited() bool {
+	return db != nil && db.writes != nil
+}
+
+// rateLimiter is a token bucket of capacity units, refilled at a constant rate.
+// Requests are allowed to overdraw it; the debt is paid off by waiting before the next request.
+type rateLimiter struct {
+	mu     sync.Mutex
+	rate   float64 // units per second
+	tokens float64
+	last   time.Time
+	now    func() time.Time
+}
+
+func newRateLimiter(units int) *rateLimiter {
+	if units <= 0 {
+		return nil
+	}
+	return &rateLimiter{
+		rate:   float64(units),
+		tokens: float64(units),
+		last:   time.Now(),
+		now:    time.Now,
+	}
+}
+
+// refill adds the tokens earned since the last refill. The caller must hold rl.mu.
+func (rl *rateLimiter) refill() {
+	now := rl.now()
+	rl.tokens += now.Sub(rl.last).Seconds() * rl.rate
+	if rl.tokens > rl.rate {
+		rl.tokens = rl.rate
+	}
+	rl.last = now
+}
+
+// delay returns how long to wait before the next request.
+func (rl *rateLimiter) delay() time.Duration {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	rl.refill()
+	if rl.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-rl.tokens / rl.rate * float64(time.Second))
+}
+
+// wait blocks until the bucket is out of debt or ctx is done.
+func (rl *rateLimiter) wait(ctx aws.Context) error {
+	if rl == nil {
+		return nil
+	}
+	if d := rl.delay(); d > 0 {
+		return aws.SleepWithContext(ctx, d)
+	}
+	return nil
+}
+
+// spend removes the capacity consumed by a request from the bucket.
+func (rl *rateLimiter) spend(ccs ...*dynamodb.ConsumedCapacity) {
+	if rl == nil {
+		return
+	}
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	rl.refill()
+	for _, cc := range ccs {
+		if cc != nil {
+			rl.tokens -= aws.Float64Value(cc.CapacityUnits)
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package dynamo
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+func TestRateLimiter(t *testing.T) {
+	now := time.Now()
+	rl := newRateLimiter(10)
+	rl.now = func() time.Time { return now }
+	rl.last = now
+
+	if d := rl.delay(); d != 0 {
+		t.Error("expected no delay for a full bucket, got", d)
+	}
+	rl.spend(&dynamodb.ConsumedCapacity{CapacityUnits: aws.Float64(15)}, nil)
+	if d := rl.delay(); d != 500*time.Millisecond {
+		t.Error("expected 500ms delay after overdrawing by 5 units, got", d)
+	}
+	now = now.Add(250 * time.Millisecond)
+	if d := rl.delay(); d != 250*time.Millisecond {
+		t.Error("expected 250ms delay after refilling 2.5 units, got", d)
+	}
+	now = now.Add(time.Hour)
+	if d := rl.delay(); d != 0 {
+		t.Error("expected no delay after refilling, got", d)
+	}
+	if rl.tokens != 10 {
+		t.Error("bucket should be capped at one second of capacity, got", rl.tokens)
+	}
+
+	if newRateLimiter(0) != nil {
+		t.Error("a limit of 0 should disable the limiter")
+	}
+}
+
+func TestQueryReadRateLimit(t *testing.T) {
+	var inputs []*dynamodb.QueryInput
+	db := NewFromIface(newMockClient(9))
+	db.SetHook(func(_ aws.Context, op string, input, _ interface{}, _ error, _ time.Duration) {
+		inputs = append(inputs, input.(*dynamodb.QueryInput))
+	})
+	db.SetReadRateLimit(1)
+
+	var items []map[string]*dynamodb.AttributeValue
+	if err := db.Table("Test").Get("UserID", 1).PageSize(4).All(&items); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+	for _, in := range inputs {
+		if rcc := aws.StringValue(in.ReturnConsumedCapacity); rcc != dynamodb.ReturnConsumedCapacityTotal {
+			t.Error("bad ReturnConsumedCapacity:", rcc)
+		}
+	}
+	// 3 pages of 0.5 units overdraw the 1 unit bucket by half a unit
+	if d := db.reads.delay(); d <= 0 || d > 500*time.Millisecond {
+		t.Error("expected the next read to wait up to 500ms, got", d)
+	}
+}
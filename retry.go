@@ -1,10 +1,14 @@
 package dynamo
 
 import (
+	"errors"
+	"math/rand"
+	"strings"
 	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
 	"github.com/cenkalti/backoff"
 	"golang.org/x/net/context"
 )
@@ -23,41 +27,296 @@ func defaultContext() (aws.Context, context.CancelFunc) {
 	return context.WithDeadline(aws.BackgroundContext(), time.Now().Add(RetryTimeout))
 }
 
-func retry(ctx aws.Context, f func() error) error {
+// RetryConfig controls how failed requests are retried.
+// Requests are only retried for errors that DynamoDB reports as temporary,
+// such as throttling or internal server errors.
+// The zero value of each field uses the default, so RetryConfig{MaxAttempts: 3}
+// still has the default delays and full jitter.
+//
+// If an error (or an error it wraps) has a RetryAfter() time.Duration method returning a positive delay,
+// such as one from a custom client passed to NewFromIface, that delay is used instead, capped at MaxDelay.
+// A retry is never attempted if its delay would pass MaxElapsed or the context's deadline;
+// the last error is returned right away instead.
+type RetryConfig struct {
+	// MaxAttempts is the maximum number of times a request will be tried,
+	// including the first attempt. Zero means no limit other than MaxElapsed, RetryTimeout, or the context deadline.
+	// Set it to 1 to disable retrying, returning the first error right away.
+	MaxAttempts int
+	// BaseDelay is the delay before the first retry. Defaults to 500ms.
+	BaseDelay time.Duration
+	// MaxDelay caps the delay between retries. Defaults to 1 minute.
+	MaxDelay time.Duration
+	// MaxElapsed caps the total time spent retrying a request. Defaults to 15 minutes.
+	MaxElapsed time.Duration
+	// By default, each delay is randomized between zero and its full value ("full jitter"),
+	// which spreads out retries when many clients are throttled at once.
+	// This applies wherever the DB backs off: retried requests, unprocessed batch items,
+	// stream polling, and waiting on tables.
+	//
+	// HalfJitter randomizes each delay by up to ±50% instead.
+	HalfJitter bool
+	// NoJitter disables randomizing delays, so they grow exactly exponentially.
+	// It takes precedence over HalfJitter.
+	NoJitter bool
+}
+
+// DefaultRetryConfig is the retry configuration used by new DBs.
+var DefaultRetryConfig = RetryConfig{
+	BaseDelay:  backoff.DefaultInitialInterval,
+	MaxDelay:   backoff.DefaultMaxInterval,
+	MaxElapsed: backoff.DefaultMaxElapsedTime,
+}
+
+// SetRetryConfig changes how this DB retries failed requests.
+// It should be called before making requests, as it is not safe to change concurrently.
+func (db *DB) SetRetryConfig(cfg RetryConfig) {
+	db.retryCfg = cfg
+}
+
+// jitterBackOff is an exponential backoff that can apply full jitter to each delay.
+type jitterBackOff struct {
+	*backoff.ExponentialBackOff
+	full bool
+}
+
+// NextBackOff returns the next delay, drawn between zero and its full value
+// when using full jitter.
+func (b *jitterBackOff) NextBackOff() time.Duration {
+	next := b.ExponentialBackOff.NextBackOff()
+	if !b.full || next <= 0 {
+		return next
+	}
+	return time.Duration(rand.Int63n(int64(next) + 1))
+}
+
+func (cfg RetryConfig) backoff() *jitterBackOff {
+	b := backoff.NewExponentialBackOff()
+	if cfg.BaseDelay > 0 {
+		b.InitialInterval = cfg.BaseDelay
+	}
+	if cfg.MaxDelay > 0 {
+		b.MaxInterval = cfg.MaxDelay
+	}
+	if cfg.MaxElapsed > 0 {
+		b.MaxElapsedTime = cfg.MaxElapsed
+	}
+	if cfg.NoJitter || !cfg.HalfJitter {
+		b.RandomizationFactor = 0
+	}
+	b.Reset()
+	return &jitterBackOff{ExponentialBackOff: b, full: !cfg.NoJitter && !cfg.HalfJitter}
+}
+
+// ShouldRetry overrides which errors this DB will retry.
+// When fn returns true for an error, the request is retried according to the RetryConfig.
+// By default, throttling errors and internal server errors are retried.
+// Passing nil restores the default behavior.
+// It should be called before making requests, as it is not safe to change concurrently.
+func (db *DB) ShouldRetry(fn func(error) bool) {
+	db.shouldRetry = fn
+}
+
+// Logger receives messages about retried requests.
+// *testing.T satisfies this interface, and LoggerFunc adapts functions like log.Printf.
+type Logger interface {
+	Logf(format string, args ...interface{})
+}
+
+// LoggerFunc is a function that satisfies Logger, such as log.Printf.
+type LoggerFunc func(format string, args ...interface{})
+
+// Logf calls f.
+func (f LoggerFunc) Logf(format string, args ...interface{}) {
+	f(format, args...)
+}
+
+// SetLogger sets a logger that is told about each retry: its attempt number,
+// the delay before the next attempt, and the error that caused it.
+// Passing nil disables logging, which is the default.
+// It should be called before making requests, as it is not safe to change concurrently.
+func (db *DB) SetLogger(logger Logger) {
+	db.logger = logger
+}
+
+type nopLogger struct{}
+
+func (nopLogger) Logf(string, ...interface{}) {}
+
+// retry calls f until it succeeds, returns a non-retryable error,
+// runs out of attempts, or ctx is done.
+func (db *DB) retry(ctx aws.Context, f func() error) error {
+	cfg := DefaultRetryConfig
+	shouldRetry := canRetry
+	var logger Logger = nopLogger{}
+	if db != nil {
+		cfg = db.retryCfg
+		if db.shouldRetry != nil {
+			shouldRetry = db.shouldRetry
+		}
+		if db.logger != nil {
+			logger = db.logger
+		}
+	}
+
 	var err error
 	var next time.Duration
-	b := backoff.WithContext(backoff.NewExponentialBackOff(), ctx)
-	for {
+	exp := cfg.backoff()
+	b := backoff.WithContext(exp, ctx)
+	for attempt := 1; ; attempt++ {
 		if err = f(); err == nil {
 			return nil
 		}
 
-		if !canRetry(err) {
-			return err
+		// ErrNotFound and friends are results, not failures
+		if err == ErrNotFound || !shouldRetry(err) {
+			return wrapError(err)
+		}
+
+		if cfg.MaxAttempts > 0 && attempt >= cfg.MaxAttempts {
+			return wrapError(err)
 		}
 
 		if next = b.NextBackOff(); next == backoff.Stop {
-			return err
+			return wrapError(err)
+		}
+		var ra retryAfterer
+		if errors.As(err, &ra) {
+			if after := ra.RetryAfter(); after > 0 {
+				next = after
+				if after > exp.MaxInterval {
+					next = exp.MaxInterval
+				}
+			}
+		}
+
+		// don't wait for a retry that would be too late
+		if exp.MaxElapsedTime > 0 && exp.GetElapsedTime()+next > exp.MaxElapsedTime {
+			return wrapError(err)
+		}
+		if deadline, ok := ctx.Deadline(); ok && time.Now().Add(next).After(deadline) {
+			return wrapError(err)
 		}
 
+		logger.Logf("dynamo: retrying request (attempt %d) in %v: %v", attempt, next, err)
+
 		if err = aws.SleepWithContext(ctx, next); err != nil {
 			return err
 		}
 	}
 }
 
+// retryAfterer is an error that suggests how long to wait before retrying.
+type retryAfterer interface {
+	RetryAfter() time.Duration
+}
+
 func canRetry(err error) bool {
-	if ae, ok := err.(awserr.RequestFailure); ok {
+	var ae awserr.RequestFailure
+	if errors.As(err, &ae) {
 		switch ae.StatusCode() {
 		case 500, 503:
 			return true
-		case 400:
-			switch ae.Code() {
-			case "ProvisionedThroughputExceededException",
-				"ThrottlingException":
-				return true
-			}
+		}
+	}
+	return IsThrottled(err)
+}
+
+// IsThrottled returns true if err is a DynamoDB throttling error,
+// such as ProvisionedThroughputExceededException or ThrottlingException.
+// Wrapped errors are unwrapped to find it.
+func IsThrottled(err error) bool {
+	var ae awserr.RequestFailure
+	if errors.As(err, &ae) && ae.StatusCode() == 400 {
+		switch ae.Code() {
+		case dynamodb.ErrCodeProvisionedThroughputExceededException,
+			"ThrottlingException":
+			return true
 		}
 	}
 	return false
 }
+
+// ConditionFailedError is returned when a conditional write fails.
+// It wraps the original error from DynamoDB.
+// Use errors.Is(err, ErrConditionFailed) to check for it.
+type ConditionFailedError struct {
+	awserr.RequestFailure
+	item map[string]*dynamodb.AttributeValue
+}
+
+// Item unmarshals the item that failed the condition into out.
+// DynamoDB only returns this item for operations in a write transaction
+// that used IncludeItemInCondCheckFail, see TxCondCheckFailed.
+// Returns ErrNotFound if there is no item.
+func (e *ConditionFailedError) Item(out interface{}) error {
+	if e.item == nil {
+		return ErrNotFound
+	}
+	return UnmarshalItem(e.item, out)
+}
+
+// Is returns true if target is ErrConditionFailed.
+func (e *ConditionFailedError) Is(target error) bool {
+	return target == ErrConditionFailed
+}
+
+// Unwrap returns the original error from DynamoDB.
+func (e *ConditionFailedError) Unwrap() error {
+	return e.RequestFailure
+}
+
+// consistentGlobalIndexError is returned when DynamoDB rejects a consistent read of a global secondary index.
+type consistentGlobalIndexError struct {
+	awserr.RequestFailure
+}
+
+func (e *consistentGlobalIndexError) Error() string {
+	return ErrConsistentGlobalIndex.Error() + ": " + e.RequestFailure.Error()
+}
+
+// Is returns true if target is ErrConsistentGlobalIndex.
+func (e *consistentGlobalIndexError) Is(target error) bool {
+	return target == ErrConsistentGlobalIndex
+}
+
+// Unwrap returns the original error from DynamoDB.
+func (e *consistentGlobalIndexError) Unwrap() error {
+	return e.RequestFailure
+}
+
+// TableNotFoundError is returned when a request's table doesn't exist.
+// It wraps the original error from DynamoDB.
+// Use errors.Is(err, ErrTableNotFound) to check for it.
+type TableNotFoundError struct {
+	awserr.RequestFailure
+}
+
+// Is returns true if target is ErrTableNotFound.
+func (e *TableNotFoundError) Is(target error) bool {
+	return target == ErrTableNotFound
+}
+
+// Unwrap returns the original error from DynamoDB.
+func (e *TableNotFoundError) Unwrap() error {
+	return e.RequestFailure
+}
+
+func isResourceNotFound(err error) bool {
+	return errors.Is(err, ErrTableNotFound)
+}
+
+func wrapError(err error) error {
+	if ae, ok := err.(awserr.RequestFailure); ok {
+		switch ae.Code() {
+		case dynamodb.ErrCodeConditionalCheckFailedException:
+			return &ConditionFailedError{RequestFailure: ae}
+		case dynamodb.ErrCodeResourceNotFoundException:
+			return &TableNotFoundError{ae}
+		case "ValidationException":
+			if strings.Contains(ae.Message(), "Consistent reads are not supported on global secondary indexes") {
+				return &consistentGlobalIndexError{ae}
+			}
+		}
+	}
+	return err
+}
@@ -0,0 +1,325 @@
+package dynamo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+)
+
+func TestRetryConfig(t *testing.T) {
+	db := NewFromIface(nil)
+	db.SetRetryConfig(RetryConfig{
+		MaxAttempts: 3,
+		BaseDelay:   time.Millisecond,
+		MaxDelay:    2 * time.Millisecond,
+	})
+
+	retryable := awserr.NewRequestFailure(awserr.New("InternalServerError", "try again", nil), 500, "")
+	var calls int
+	err := db.retry(aws.BackgroundContext(), func() error {
+		calls++
+		return retryable
+	})
+	if err != retryable {
+		t.Error("unexpected error:", err)
+	}
+	if calls != 3 {
+		t.Error("expected 3 attempts, got", calls)
+	}
+
+	// non-retryable errors return immediately
+	invalid := awserr.NewRequestFailure(awserr.New("ValidationException", "bad", nil), 400, "")
+	calls = 0
+	err = db.retry(aws.BackgroundContext(), func() error {
+		calls++
+		return invalid
+	})
+	if err != invalid {
+		t.Error("unexpected error:", err)
+	}
+	if calls != 1 {
+		t.Error("expected 1 attempt, got", calls)
+	}
+}
+
+func TestShouldRetry(t *testing.T) {
+	throttled := awserr.NewRequestFailure(awserr.New("ThrottlingException", "slow down", nil), 400, "")
+	unavailable := awserr.NewRequestFailure(awserr.New("ServiceUnavailable", "", nil), 503, "")
+	if !IsThrottled(throttled) {
+		t.Error("expected throttling error to be throttled")
+	}
+	if IsThrottled(unavailable) {
+		t.Error("expected 503 error not to be throttled")
+	}
+	if !IsThrottled(fmt.Errorf("wrapped: %w", throttled)) || !canRetry(fmt.Errorf("wrapped: %w", unavailable)) {
+		t.Error("expected wrapped errors to be unwrapped")
+	}
+
+	db := NewFromIface(nil)
+	db.SetRetryConfig(RetryConfig{MaxAttempts: 5, BaseDelay: time.Millisecond})
+	db.ShouldRetry(IsThrottled)
+
+	var calls int
+	err := db.retry(aws.BackgroundContext(), func() error {
+		calls++
+		return unavailable
+	})
+	if err != unavailable {
+		t.Error("unexpected error:", err)
+	}
+	if calls != 1 {
+		t.Error("expected 1 attempt, got", calls)
+	}
+
+	calls = 0
+	err = db.retry(aws.BackgroundContext(), func() error {
+		calls++
+		if calls < 3 {
+			return throttled
+		}
+		return nil
+	})
+	if err != nil {
+		t.Error("unexpected error:", err)
+	}
+	if calls != 3 {
+		t.Error("expected 3 attempts, got", calls)
+	}
+}
+
+func TestRetryLogger(t *testing.T) {
+	throttled := awserr.NewRequestFailure(awserr.New("ThrottlingException", "slow down", nil), 400, "")
+	db := NewFromIface(nil)
+	db.SetRetryConfig(RetryConfig{MaxAttempts: 3, BaseDelay: time.Millisecond})
+
+	var logs []string
+	db.SetLogger(LoggerFunc(func(format string, args ...interface{}) {
+		logs = append(logs, fmt.Sprintf(format, args...))
+	}))
+	err := db.retry(aws.BackgroundContext(), func() error {
+		return throttled
+	})
+	if err != throttled {
+		t.Error("unexpected error:", err)
+	}
+	// the final attempt isn't retried, so it isn't logged
+	if len(logs) != 2 {
+		t.Fatal("expected 2 log messages, got", logs)
+	}
+	for i, msg := range logs {
+		if !strings.Contains(msg, fmt.Sprintf("attempt %d", i+1)) || !strings.Contains(msg, "ThrottlingException") {
+			t.Error("bad log message:", msg)
+		}
+	}
+}
+
+func TestConditionFailedError(t *testing.T) {
+	db := NewFromIface(nil)
+	orig := awserr.NewRequestFailure(awserr.New("ConditionalCheckFailedException", "The conditional request failed", nil), 400, "")
+	err := db.retry(aws.BackgroundContext(), func() error {
+		return orig
+	})
+	if !errors.Is(err, ErrConditionFailed) {
+		t.Error("expected ErrConditionFailed, got", err)
+	}
+	var cfe *ConditionFailedError
+	if !errors.As(err, &cfe) || cfe.Unwrap() != orig {
+		t.Error("expected original error to be wrapped, got", err)
+	}
+	if !isConditionalCheckErr(err) {
+		t.Error("expected awserr.RequestFailure to be preserved, got", err)
+	}
+}
+
+func TestConsistentGlobalIndexError(t *testing.T) {
+	db := NewFromIface(nil)
+	orig := awserr.NewRequestFailure(awserr.New("ValidationException", "Consistent reads are not supported on global secondary indexes", nil), 400, "")
+	err := db.retry(aws.BackgroundContext(), func() error {
+		return orig
+	})
+	if !errors.Is(err, ErrConsistentGlobalIndex) {
+		t.Error("expected ErrConsistentGlobalIndex, got", err)
+	}
+	if !errors.Is(err, orig) {
+		t.Error("expected original error to be wrapped, got", err)
+	}
+
+	other := awserr.NewRequestFailure(awserr.New("ValidationException", "One or more parameter values were invalid", nil), 400, "")
+	err = db.retry(aws.BackgroundContext(), func() error {
+		return other
+	})
+	if err != other {
+		t.Error("expected other validation errors to be unchanged, got", err)
+	}
+}
+
+type retryAfterError struct {
+	awserr.RequestFailure
+	after time.Duration
+}
+
+func (e retryAfterError) RetryAfter() time.Duration {
+	return e.after
+}
+
+func TestRetryDelays(t *testing.T) {
+	throttled := awserr.NewRequestFailure(awserr.New("ThrottlingException", "slow down", nil), 400, "")
+
+	t.Run("full jitter", func(t *testing.T) {
+		db := NewFromIface(nil)
+		db.SetRetryConfig(RetryConfig{MaxAttempts: 6, BaseDelay: time.Millisecond, MaxDelay: 4 * time.Millisecond})
+		var delays []time.Duration
+		db.SetLogger(LoggerFunc(func(format string, args ...interface{}) {
+			delays = append(delays, args[1].(time.Duration))
+		}))
+		db.retry(aws.BackgroundContext(), func() error {
+			return throttled
+		})
+		if len(delays) != 5 {
+			t.Fatal("expected 5 retries, got", len(delays))
+		}
+		for _, d := range delays {
+			if d < 0 || d > 4*time.Millisecond {
+				t.Error("delay out of range:", d)
+			}
+		}
+	})
+
+	t.Run("retry after", func(t *testing.T) {
+		db := NewFromIface(nil)
+		db.SetRetryConfig(RetryConfig{MaxAttempts: 2, BaseDelay: time.Hour})
+		var delay time.Duration
+		db.SetLogger(LoggerFunc(func(format string, args ...interface{}) {
+			delay = args[1].(time.Duration)
+		}))
+		orig := retryAfterError{throttled, time.Millisecond}
+		err := db.retry(aws.BackgroundContext(), func() error {
+			return orig
+		})
+		if err != orig {
+			t.Error("unexpected error:", err)
+		}
+		if delay != time.Millisecond {
+			t.Error("expected suggested delay to be used, got", delay)
+		}
+	})
+
+	t.Run("retry after is capped", func(t *testing.T) {
+		db := NewFromIface(nil)
+		db.SetRetryConfig(RetryConfig{MaxAttempts: 2, BaseDelay: time.Millisecond, MaxDelay: 2 * time.Millisecond})
+		var delay time.Duration
+		db.SetLogger(LoggerFunc(func(format string, args ...interface{}) {
+			delay = args[1].(time.Duration)
+		}))
+		db.retry(aws.BackgroundContext(), func() error {
+			return fmt.Errorf("wrapped: %w", retryAfterError{throttled, time.Hour})
+		})
+		if delay != 2*time.Millisecond {
+			t.Error("expected suggested delay to be capped at MaxDelay, got", delay)
+		}
+	})
+
+	t.Run("max elapsed", func(t *testing.T) {
+		db := NewFromIface(nil)
+		db.SetRetryConfig(RetryConfig{BaseDelay: time.Hour, MaxElapsed: 30 * time.Second, NoJitter: true})
+		var calls int
+		err := db.retry(aws.BackgroundContext(), func() error {
+			calls++
+			return throttled
+		})
+		if err != throttled {
+			t.Error("unexpected error:", err)
+		}
+		if calls != 1 {
+			t.Error("expected 1 attempt, got", calls)
+		}
+	})
+
+	t.Run("deadline", func(t *testing.T) {
+		db := NewFromIface(nil)
+		db.SetRetryConfig(RetryConfig{BaseDelay: time.Hour, NoJitter: true})
+		ctx, cancel := context.WithTimeout(aws.BackgroundContext(), time.Minute)
+		defer cancel()
+		start := time.Now()
+		err := db.retry(ctx, func() error {
+			return throttled
+		})
+		if err != throttled {
+			t.Error("expected last error instead of waiting past the deadline, got", err)
+		}
+		if time.Since(start) > time.Second {
+			t.Error("waited too long:", time.Since(start))
+		}
+	})
+}
+
+func TestBackoffJitter(t *testing.T) {
+	// full jitter is the default, even if the config doesn't start from DefaultRetryConfig
+	b := RetryConfig{MaxAttempts: 3, BaseDelay: time.Second, MaxDelay: time.Second}.backoff()
+	var below bool
+	for i := 0; i < 50; i++ {
+		next := b.NextBackOff()
+		if next < 0 || next > time.Second {
+			t.Fatal("delay out of range:", next)
+		}
+		if next < 500*time.Millisecond {
+			below = true
+		}
+	}
+	if !below {
+		t.Error("expected delays to be fully jittered")
+	}
+
+	for i := 0; i < 50; i++ {
+		next := RetryConfig{BaseDelay: time.Second, HalfJitter: true}.backoff().NextBackOff()
+		if next < 500*time.Millisecond || next > 1500*time.Millisecond {
+			t.Fatal("half jitter delay out of range:", next)
+		}
+	}
+
+	if next := (RetryConfig{BaseDelay: time.Second, HalfJitter: true, NoJitter: true}).backoff().NextBackOff(); next != time.Second {
+		t.Error("expected no jitter, got", next)
+	}
+}
+
+func TestNoRetry(t *testing.T) {
+	db := NewFromIface(newMockClient(1))
+	db.SetRetryConfig(RetryConfig{MaxAttempts: 1, BaseDelay: time.Hour})
+
+	throttled := awserr.NewRequestFailure(awserr.New("ThrottlingException", "slow down", nil), 400, "")
+	var calls int
+	err := db.retry(aws.BackgroundContext(), func() error {
+		calls++
+		return throttled
+	})
+	if err != throttled {
+		t.Error("unexpected error:", err)
+	}
+	if calls != 1 {
+		t.Error("expected 1 attempt, got", calls)
+	}
+
+	// ErrNotFound is never retried, even if ShouldRetry says so
+	db.SetRetryConfig(RetryConfig{MaxAttempts: 3, BaseDelay: time.Hour})
+	db.ShouldRetry(func(error) bool { return true })
+	calls = 0
+	db.SetHook(func(aws.Context, string, interface{}, interface{}, error, time.Duration) {
+		calls++
+	})
+	var item struct{ Idx int }
+	if err := db.Table("Test").Get("Idx", 1).One(&item); err != ErrNotFound {
+		t.Error("expected ErrNotFound, got", err)
+	}
+	if calls != 1 {
+		t.Error("expected 1 attempt, got", calls)
+	}
+	if err := db.Table("Test").Get("Idx", 0).One(&item); err != nil {
+		t.Error("unexpected error:", err)
+	}
+}
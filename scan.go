@@ -1,10 +1,17 @@
 package dynamo
 
 import (
+	"errors"
+	"fmt"
+	"reflect"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
 	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"golang.org/x/net/context"
 )
 
 // Scan is a request to scan all the data in a table.
@@ -19,11 +26,18 @@ type Scan struct {
 	consistent  bool
 	limit       int64
 	searchLimit int64
+	pageSize    int64
+	onUnmarshal func(*UnmarshalError) error
+
+	segment       int64
+	totalSegments int64
 
 	subber
 
-	err error
-	cc  *ConsumedCapacity
+	err   error
+	cc    *ConsumedCapacity
+	stats *QueryStats
+	opts  []request.Option
 }
 
 // Scan creates a new request to scan this table.
@@ -34,12 +48,22 @@ func (table Table) Scan() *Scan {
 }
 
 // StartFrom makes this scan continue from a previous one.
-// Use Scan.Iter's LastEvaluatedKey.
+// Use Scan.Iter's LastEvaluatedKey or the key returned by AllWithLastEvaluatedKey.
 func (s *Scan) StartFrom(key PagingKey) *Scan {
 	s.startKey = key
 	return s
 }
 
+// StartFromToken makes this scan continue from a previous one,
+// using a token obtained by encoding a PagingKey with MarshalText.
+// An empty token starts from the beginning.
+func (s *Scan) StartFromToken(token string) *Scan {
+	var key PagingKey
+	s.setError(key.UnmarshalText([]byte(token)))
+	s.startKey = key
+	return s
+}
+
 // Index specifies the name of the index that Scan will operate on.
 func (s *Scan) Index(name string) *Scan {
 	s.index = name
@@ -47,8 +71,33 @@ func (s *Scan) Index(name string) *Scan {
 }
 
 // Project limits the result attributes to the given paths.
+// Paths are attribute names separated by dots for nested maps, each optionally
+// followed by list indexes, like "Address.Zip" or "Items[0].Name".
+// Names that are reserved words are automatically escaped.
+// Use single quotes to escape complex names like 'User'.'Count'.
 func (s *Scan) Project(paths ...string) *Scan {
-	expr, err := s.subExpr(strings.Join(paths, ", "), nil)
+	var expr string
+	for i, p := range paths {
+		if i != 0 {
+			expr += ", "
+		}
+		name, err := s.escape(p)
+		s.setError(err)
+		expr += name
+	}
+	s.projection = expr
+	return s
+}
+
+// ProjectExpr limits the result attributes to the given expression.
+// Use single quotes to specificy reserved names inline (like 'Count').
+// Use the placeholder ? within the expression to substitute values, and use $ for names.
+// You need to use quoted or placeholder names when the name is a reserved word in DynamoDB.
+// $ placeholders given an int are substituted as a list index, for example:
+//
+//	s.ProjectExpr("$, Items[$], Meta.$", "Name", 0, "CreatedAt")
+func (s *Scan) ProjectExpr(expr string, args ...interface{}) *Scan {
+	expr, err := s.subExpr(expr, args...)
 	s.setError(err)
 	s.projection = expr
 	return s
@@ -58,6 +107,7 @@ func (s *Scan) Project(paths ...string) *Scan {
 // Use single quotes to specificy reserved names inline (like 'Count').
 // Use the placeholder ? within the expression to substitute values, and use $ for names.
 // You need to use quoted or placeholder names when the name is a reserved word in DynamoDB.
+// A slice passed as the list of an IN operator, like "ID IN ?" or "ID IN (?)", is expanded into one value per element.
 // Multiple calls to Filter will be combined with AND.
 func (s *Scan) Filter(expr string, args ...interface{}) *Scan {
 	expr = wrapExpr(expr)
@@ -67,6 +117,94 @@ func (s *Scan) Filter(expr string, args ...interface{}) *Scan {
 	return s
 }
 
+// FilterOr is like Filter, but combines expr with any previous filters using OR.
+// Previous filters are grouped together first, so
+//
+//	Filter("A = ?", 1).Filter("B = ?", 2).FilterOr("C = ?", 3)
+//
+// evaluates as ((A = 1) AND (B = 2)) OR (C = 3).
+// Later calls to Filter are combined with the result using AND.
+// When there are no previous filters, it is the same as Filter.
+func (s *Scan) FilterOr(expr string, args ...interface{}) *Scan {
+	expr = wrapExpr(expr)
+	expr, err := s.subExpr(expr, args...)
+	s.setError(err)
+	s.filters = orFilters(s.filters, expr)
+	return s
+}
+
+// FilterExists filters results to those where path exists.
+// Reserved words and dotted paths are escaped as in Project.
+// It is combined with other filters using AND.
+func (s *Scan) FilterExists(path string) *Scan {
+	return s.filterFunc("attribute_exists", path)
+}
+
+// FilterNotExists filters results to those where path does not exist.
+// Reserved words and dotted paths are escaped as in Project.
+// It is combined with other filters using AND.
+func (s *Scan) FilterNotExists(path string) *Scan {
+	return s.filterFunc("attribute_not_exists", path)
+}
+
+// FilterAttributeType filters results to those where path is of the given DynamoDB type,
+// such as "S", "N", "BOOL", "NULL", "L", or "M".
+// It is combined with other filters using AND.
+func (s *Scan) FilterAttributeType(path string, t string) *Scan {
+	return s.filterFunc("attribute_type", path, t)
+}
+
+// FilterBeginsWith filters results to those where the string at path begins with prefix.
+// It is combined with other filters using AND.
+func (s *Scan) FilterBeginsWith(path string, prefix string) *Scan {
+	return s.filterFunc("begins_with", path, prefix)
+}
+
+// FilterContains filters results to those where path contains value:
+// a substring of a string, or an element of a set or list.
+// It is combined with other filters using AND.
+func (s *Scan) FilterContains(path string, value interface{}) *Scan {
+	return s.filterFunc("contains", path, value)
+}
+
+// FilterSize filters results to those where the size of path compares to size using op,
+// which must be Equal, NotEqual, Less, LessOrEqual, Greater, or GreaterOrEqual.
+// Size is the length of a string or binary value, or the number of elements in a set, list, or map.
+// It is combined with other filters using AND.
+func (s *Scan) FilterSize(path string, op Operator, size int) *Scan {
+	sym, err := op.symbol()
+	s.setError(err)
+	path, err = s.escape(path)
+	s.setError(err)
+	sub, err := s.subValue(size, "")
+	s.setError(err)
+	s.filters = append(s.filters, "(size("+path+") "+sym+" "+sub+")")
+	return s
+}
+
+// filterFunc adds a filter calling the function fn with path and args.
+func (s *Scan) filterFunc(fn, path string, args ...interface{}) *Scan {
+	path, err := s.escape(path)
+	s.setError(err)
+	expr := fn + "(" + path
+	for _, arg := range args {
+		sub, err := s.subValue(arg, "")
+		s.setError(err)
+		expr += ", " + sub
+	}
+	s.filters = append(s.filters, "("+expr+"))")
+	return s
+}
+
+// OnUnmarshalError sets a function that is called when a result can't be unmarshaled,
+// instead of stopping with an *UnmarshalError. If fn returns nil, the result is skipped
+// and iteration continues without counting it toward Limit; otherwise iteration stops with the returned error.
+// This only applies to Iter and All.
+func (s *Scan) OnUnmarshalError(fn func(err *UnmarshalError) error) *Scan {
+	s.onUnmarshal = fn
+	return s
+}
+
 // Consistent will, if on is true, make this scan use a strongly consistent read.
 // Scans are eventually consistent by default.
 // Strongly consistent reads are more resource-heavy than eventually consistent reads.
@@ -76,6 +214,7 @@ func (s *Scan) Consistent(on bool) *Scan {
 }
 
 // Limit specifies the maximum amount of results to return.
+// Results are accumulated across pages until the limit is reached.
 func (s *Scan) Limit(limit int64) *Scan {
 	s.limit = limit
 	return s
@@ -84,22 +223,59 @@ func (s *Scan) Limit(limit int64) *Scan {
 // SearchLimit specifies a maximum amount of results to evaluate.
 // Use this along with StartFrom and Iter's LastEvaluatedKey to split up results.
 // Note that DynamoDB limits result sets to 1MB.
+// SearchLimit stops after a single page of results; use PageSize to keep paginating.
 func (s *Scan) SearchLimit(limit int64) *Scan {
 	s.searchLimit = limit
 	return s
 }
 
+// PageSize specifies the maximum amount of results to examine per request.
+// Unlike SearchLimit, All and Iter will keep requesting pages until
+// every result has been retrieved or Limit is reached.
+// This is useful for spreading out throughput consumption over many small requests.
+func (s *Scan) PageSize(size int64) *Scan {
+	s.pageSize = size
+	return s
+}
+
+// SegmentParallel makes this scan only examine the given segment of the table,
+// which has been split into totalSegments segments numbered from 0 to totalSegments-1.
+// Use this to divide a scan of a large table among multiple workers.
+// See: https://docs.aws.amazon.com/amazondynamodb/latest/developerguide/Scan.html#Scan.ParallelScan
+func (s *Scan) SegmentParallel(segment, totalSegments int64) *Scan {
+	s.segment = segment
+	s.totalSegments = totalSegments
+	return s
+}
+
 // ConsumedCapacity will measure the throughput capacity consumed by this operation and add it to cc.
 func (s *Scan) ConsumedCapacity(cc *ConsumedCapacity) *Scan {
 	s.cc = cc
 	return s
 }
 
+// Stats will record the number of items returned and evaluated by this scan,
+// and the capacity it consumed, adding them to stats.
+// Parallel scans add the stats of every segment.
+func (s *Scan) Stats(stats *QueryStats) *Scan {
+	s.stats = stats
+	return s
+}
+
+// RequestOptions adds options, such as custom request handlers, to every request made by this scan.
+// They are passed to the AWS SDK's WithContext methods.
+func (s *Scan) RequestOptions(opts ...request.Option) *Scan {
+	s.opts = append(s.opts, opts...)
+	return s
+}
+
 // Iter returns a results iterator for this request.
+// Results are fetched lazily one page at a time as the iterator advances,
+// so arbitrarily large result sets can be processed with constant memory.
 func (s *Scan) Iter() PagingIter {
 	return &scanIter{
 		scan:      s,
-		unmarshal: unmarshalItem,
+		unmarshal: s.table.db.decoder().unmarshalItem,
 		err:       s.err,
 	}
 }
@@ -113,13 +289,43 @@ func (s *Scan) All(out interface{}) error {
 }
 
 // AllWithContext executes this request and unmarshals all results to out, which must be a pointer to a slice.
+// If ctx is done, no more pages are requested and ctx.Err() is returned.
 func (s *Scan) AllWithContext(ctx aws.Context, out interface{}) error {
 	_, err := s.AllWithLastEvaluatedKeyContext(ctx, out)
 	return err
 }
 
+// Each executes this request and calls fn with each result as it arrives, one page at a time.
+// fn is given the raw item, which can be decoded with UnmarshalItem. See Query.Each.
+// If fn returns an error, no more results are requested and that error is returned.
+func (s *Scan) Each(fn func(item map[string]*dynamodb.AttributeValue) error) error {
+	ctx, cancel := defaultContext()
+	defer cancel()
+	return s.EachWithContext(ctx, fn)
+}
+
+// EachWithContext executes this request and calls fn with each result as it arrives, one page at a time.
+// If fn returns an error, no more results are requested and that error is returned.
+// If ctx is done, no more pages are requested and ctx.Err() is returned.
+func (s *Scan) EachWithContext(ctx aws.Context, fn func(item map[string]*dynamodb.AttributeValue) error) error {
+	iter := &scanIter{
+		scan:      s,
+		unmarshal: unmarshalRaw,
+		err:       s.err,
+	}
+	var item map[string]*dynamodb.AttributeValue
+	for iter.NextWithContext(ctx, &item) {
+		if err := fn(item); err != nil {
+			return err
+		}
+	}
+	return iter.Err()
+}
+
 // AllWithLastEvaluatedKey executes this request and unmarshals all results to out, which must be a pointer to a slice.
-// It returns a key you can use with StartWith to continue this query.
+// It returns a key you can use with StartFrom to continue this scan.
+// Combine this with SearchLimit to retrieve a single page of results per call.
+// The returned key is nil when there are no more results.
 func (s *Scan) AllWithLastEvaluatedKey(out interface{}) (PagingKey, error) {
 	ctx, cancel := defaultContext()
 	defer cancel()
@@ -127,11 +333,11 @@ func (s *Scan) AllWithLastEvaluatedKey(out interface{}) (PagingKey, error) {
 }
 
 // AllWithLastEvaluatedKeyContext executes this request and unmarshals all results to out, which must be a pointer to a slice.
-// It returns a key you can use with StartWith to continue this query.
+// It returns a key you can use with StartFrom to continue this scan.
 func (s *Scan) AllWithLastEvaluatedKeyContext(ctx aws.Context, out interface{}) (PagingKey, error) {
 	itr := &scanIter{
 		scan:      s,
-		unmarshal: unmarshalAppend,
+		unmarshal: s.table.db.decoder().unmarshalAppend,
 		err:       s.err,
 	}
 	for itr.NextWithContext(ctx, out) {
@@ -139,25 +345,348 @@ func (s *Scan) AllWithLastEvaluatedKeyContext(ctx aws.Context, out interface{})
 	return itr.LastEvaluatedKey(), itr.Err()
 }
 
+// Count executes this request and returns the number of items matching the scan.
+// It takes into account the filter and search limit, but not the limit.
+func (s *Scan) Count() (int64, error) {
+	ctx, cancel := defaultContext()
+	defer cancel()
+	return s.CountWithContext(ctx)
+}
+
+// CountWithContext executes this request and returns the number of items matching the scan.
+// It takes into account the filter and search limit, but not the limit.
+// If ctx is done or a request fails, no more pages are requested and the error is returned
+// along with the count of the pages read so far.
+func (s *Scan) CountWithContext(ctx aws.Context) (int64, error) {
+	if s.err != nil {
+		return 0, s.err
+	}
+	var count int64
+	input := s.scanInput()
+	input.Select = selectCount
+	for {
+		if err := ctx.Err(); err != nil {
+			return count, err
+		}
+
+		var out *dynamodb.ScanOutput
+		err := s.table.db.retry(ctx, func() error {
+			if err := s.table.db.reads.wait(ctx); err != nil {
+				return err
+			}
+			var err error
+			start := time.Now()
+			out, err = s.table.db.client.ScanWithContext(ctx, input, s.opts...)
+			s.table.db.observe(ctx, "Scan", input, out, err, start)
+			return err
+		})
+		if err != nil {
+			return count, err
+		}
+
+		if out.Count == nil {
+			return count, errors.New("nil count")
+		}
+		count += *out.Count
+
+		if s.cc != nil {
+			addConsumedCapacity(s.cc, out.ConsumedCapacity)
+		}
+		s.stats.add(aws.Int64Value(out.Count), aws.Int64Value(out.ScannedCount), out.ConsumedCapacity)
+		s.table.db.reads.spend(out.ConsumedCapacity)
+
+		if out.LastEvaluatedKey == nil || s.searchLimit > 0 {
+			break
+		}
+
+		input.ExclusiveStartKey = out.LastEvaluatedKey
+	}
+	return count, nil
+}
+
+// AllParallel executes this request as a parallel scan split into numWorkers segments,
+// each scanned by its own goroutine, and unmarshals all results to out, which must be a pointer to a slice.
+// The order of results is not deterministic. If any segment fails, the others are canceled.
+// Note that every worker consumes throughput concurrently, so capacity consumed per second
+// scales with numWorkers.
+func (s *Scan) AllParallel(numWorkers int, out interface{}) error {
+	ctx, cancel := defaultContext()
+	defer cancel()
+	return s.AllParallelWithContext(ctx, numWorkers, out)
+}
+
+// AllParallelWithContext executes this request as a parallel scan split into numWorkers segments,
+// each scanned by its own goroutine, and unmarshals all results to out, which must be a pointer to a slice.
+// The order of results is not deterministic. If any segment fails, the others are canceled.
+// Note that every worker consumes throughput concurrently, so capacity consumed per second
+// scales with numWorkers.
+func (s *Scan) AllParallelWithContext(ctx aws.Context, numWorkers int, out interface{}) error {
+	if s.err != nil {
+		return s.err
+	}
+	if numWorkers < 1 {
+		return fmt.Errorf("dynamo: parallel scan: number of workers must be at least 1, got %d", numWorkers)
+	}
+	rv := reflect.ValueOf(out)
+	if rv.Kind() != reflect.Ptr || rv.Elem().Kind() != reflect.Slice {
+		return fmt.Errorf("dynamo: parallel scan: out must be a pointer to a slice, got %T", out)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		wg  sync.WaitGroup
+		mu  sync.Mutex
+		err error
+	)
+	for i := 0; i < numWorkers; i++ {
+		seg := *s
+		seg.SegmentParallel(int64(i), int64(numWorkers))
+		var cc ConsumedCapacity
+		if s.cc != nil {
+			seg.cc = &cc
+		}
+		var stats QueryStats
+		if s.stats != nil {
+			seg.stats = &stats
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results := reflect.New(rv.Elem().Type())
+			segErr := seg.AllWithContext(ctx, results.Interface())
+
+			mu.Lock()
+			defer mu.Unlock()
+			rv.Elem().Set(reflect.AppendSlice(rv.Elem(), results.Elem()))
+			if s.cc != nil {
+				mergeConsumedCapacity(s.cc, &cc)
+			}
+			s.stats.merge(&stats)
+			if segErr != nil && err == nil {
+				err = segErr
+				cancel()
+			}
+		}()
+	}
+	wg.Wait()
+	return err
+}
+
+// IterParallel returns an iterator of the results of a parallel scan split into numWorkers segments,
+// each scanned by its own goroutine. Results from every segment are fanned in to a single iterator
+// as they arrive, so their order is not deterministic. Workers only read ahead of the iterator by one page
+// each, so a whole table can be processed with constant memory.
+// If a segment fails, the others are stopped and Err returns a *SegmentError.
+// If the context given to NextWithContext is done, the scan is stopped and Err returns the context's error.
+// Call Close if you stop iterating before Next returns false.
+func (s *Scan) IterParallel(numWorkers int) ParallelIter {
+	itr := &parallelScanIter{
+		scan:    s,
+		workers: numWorkers,
+		err:     s.err,
+	}
+	if itr.err == nil && numWorkers < 1 {
+		itr.err = fmt.Errorf("dynamo: parallel scan: number of workers must be at least 1, got %d", numWorkers)
+	}
+	return itr
+}
+
+// ParallelIter is an iterator of results from multiple concurrent requests.
+type ParallelIter interface {
+	Iter
+	// Close stops all outstanding requests.
+	// It is called automatically when Next returns false.
+	Close()
+}
+
+// SegmentError is returned by the iterator of a parallel scan when one of its segments fails.
+type SegmentError struct {
+	// Segment is the number of the segment that failed.
+	Segment int64
+	// Err is the error encountered by the segment.
+	Err error
+}
+
+func (e *SegmentError) Error() string {
+	return fmt.Sprintf("dynamo: parallel scan: segment %d: %v", e.Segment, e.Err)
+}
+
+// Unwrap returns the error encountered by the segment.
+func (e *SegmentError) Unwrap() error {
+	return e.Err
+}
+
+// parallelScanIter fans in the results of one goroutine per segment.
+type parallelScanIter struct {
+	scan    *Scan
+	workers int
+	items   chan map[string]*dynamodb.AttributeValue
+	cancel  context.CancelFunc
+	n       int64 // results delivered, counted toward the limit
+	seen    int64 // results unmarshaled, including skipped ones
+	err     error
+
+	mu     sync.Mutex
+	segErr error
+}
+
+// Next tries to unmarshal the next result into out.
+// Returns false when it is complete or if it runs into an error.
+func (itr *parallelScanIter) Next(out interface{}) bool {
+	ctx, cancel := defaultContext()
+	defer cancel()
+	return itr.NextWithContext(ctx, out)
+}
+
+// NextWithContext tries to unmarshal the next result into out.
+// Returns false when it is complete or if it runs into an error.
+// ctx only bounds the wait for this result; the workers keep running until Close.
+func (itr *parallelScanIter) NextWithContext(ctx aws.Context, out interface{}) bool {
+	if itr.err != nil {
+		return false
+	}
+	if itr.scan.limit > 0 && itr.n >= itr.scan.limit {
+		itr.Close()
+		return false
+	}
+	if itr.items == nil {
+		itr.start()
+	}
+
+	for {
+		select {
+		case item, ok := <-itr.items:
+			if !ok {
+				itr.mu.Lock()
+				itr.err = itr.segErr
+				itr.mu.Unlock()
+				itr.Close()
+				return false
+			}
+			itr.seen++
+			if err := itr.scan.table.db.decoder().unmarshalItem(item, out); err != nil {
+				if itr.err = unmarshalError(itr.scan.onUnmarshal, itr.seen-1, item, err); itr.err == nil {
+					// skipped, wait for the next one
+					continue
+				}
+				itr.Close()
+				return false
+			}
+			itr.n++
+			return true
+		case <-ctx.Done():
+			itr.err = ctx.Err()
+			itr.Close()
+			return false
+		}
+	}
+}
+
+func (itr *parallelScanIter) start() {
+	ctx, cancel := context.WithCancel(aws.BackgroundContext())
+	itr.cancel = cancel
+	itr.items = make(chan map[string]*dynamodb.AttributeValue)
+
+	var wg sync.WaitGroup
+	for i := 0; i < itr.workers; i++ {
+		seg := *itr.scan
+		seg.SegmentParallel(int64(i), int64(itr.workers))
+		var cc ConsumedCapacity
+		if itr.scan.cc != nil {
+			seg.cc = &cc
+		}
+		var stats QueryStats
+		if itr.scan.stats != nil {
+			seg.stats = &stats
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			segItr := seg.Iter()
+			var item map[string]*dynamodb.AttributeValue
+			for segItr.NextWithContext(ctx, &item) {
+				select {
+				case itr.items <- item:
+				case <-ctx.Done():
+				}
+				item = nil
+			}
+
+			itr.mu.Lock()
+			defer itr.mu.Unlock()
+			if itr.scan.cc != nil {
+				mergeConsumedCapacity(itr.scan.cc, &cc)
+			}
+			itr.scan.stats.merge(&stats)
+			if err := segItr.Err(); err != nil && ctx.Err() == nil && itr.segErr == nil {
+				itr.segErr = &SegmentError{Segment: seg.segment, Err: err}
+				cancel()
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(itr.items)
+	}()
+}
+
+// Close stops this scan's workers.
+func (itr *parallelScanIter) Close() {
+	if itr.cancel != nil {
+		itr.cancel()
+	}
+}
+
+// Err returns the error encountered, if any.
+// You should check this after Next is finished.
+func (itr *parallelScanIter) Err() error {
+	return itr.err
+}
+
+// Validate returns the first error encountered while building this scan,
+// such as a malformed expression or the wrong number of arguments for its placeholders,
+// without making any requests.
+func (s *Scan) Validate() error {
+	return s.err
+}
+
+// DebugInput returns the request this scan would make, without sending it.
+// It is useful for checking the generated expressions, names, and values.
+// err is any error encountered while building this scan.
+func (s *Scan) DebugInput() (*dynamodb.ScanInput, error) {
+	return s.scanInput(), s.err
+}
+
 func (s *Scan) scanInput() *dynamodb.ScanInput {
 	input := &dynamodb.ScanInput{
 		ExclusiveStartKey:         s.startKey,
 		TableName:                 &s.table.name,
 		ConsistentRead:            &s.consistent,
 		ExpressionAttributeNames:  s.nameExpr,
-		ExpressionAttributeValues: s.valueExpr,
+		ExpressionAttributeValues: s.table.db.emptyStringMode().values(s.valueExpr),
 	}
 	if s.limit > 0 {
 		if len(s.filters) == 0 {
 			input.Limit = &s.limit
 		}
 	}
+	if s.pageSize > 0 && (input.Limit == nil || s.pageSize < *input.Limit) {
+		input.Limit = &s.pageSize
+	}
 	if s.searchLimit > 0 {
 		input.Limit = &s.searchLimit
 	}
 	if s.index != "" {
 		input.IndexName = &s.index
 	}
+	if s.totalSegments > 0 {
+		input.Segment = &s.segment
+		input.TotalSegments = &s.totalSegments
+	}
 	if s.projection != "" {
 		input.ProjectionExpression = &s.projection
 	}
@@ -165,9 +694,7 @@ func (s *Scan) scanInput() *dynamodb.ScanInput {
 		filter := strings.Join(s.filters, " AND ")
 		input.FilterExpression = &filter
 	}
-	if s.cc != nil {
-		input.ReturnConsumedCapacity = aws.String(dynamodb.ReturnConsumedCapacityIndexes)
-	}
+	input.ReturnConsumedCapacity = returnConsumedCapacity(s.cc, s.stats != nil || s.table.db.readLimited())
 	return input
 }
 
@@ -184,7 +711,8 @@ type scanIter struct {
 	output *dynamodb.ScanOutput
 	err    error
 	idx    int
-	n      int64
+	n      int64 // results delivered, counted toward the limit
+	seen   int64 // results unmarshaled, including skipped ones
 
 	unmarshal unmarshalFunc
 }
@@ -197,6 +725,8 @@ func (itr *scanIter) Next(out interface{}) bool {
 	return itr.NextWithContext(ctx, out)
 }
 
+// NextWithContext tries to unmarshal the next result into out.
+// Returns false when it is complete or if it runs into an error.
 func (itr *scanIter) NextWithContext(ctx aws.Context, out interface{}) bool {
 	// stop if we have an error
 	if itr.err != nil {
@@ -208,55 +738,77 @@ func (itr *scanIter) NextWithContext(ctx aws.Context, out interface{}) bool {
 		return false
 	}
 
-	// can we use results we already have?
-	if itr.output != nil && itr.idx < len(itr.output.Items) {
-		item := itr.output.Items[itr.idx]
-		itr.err = itr.unmarshal(item, out)
-		itr.idx++
-		itr.n++
-		return itr.err == nil
-	}
-
-	// new scan
-	if itr.input == nil {
-		itr.input = itr.scan.scanInput()
-	}
-	if itr.output != nil && itr.idx >= len(itr.output.Items) {
-		// have we exhausted all results?
-		if itr.output.LastEvaluatedKey == nil || itr.scan.searchLimit > 0 {
-			return false
+	for {
+		// can we use results we already have?
+		if itr.output != nil && itr.idx < len(itr.output.Items) {
+			if itr.decode(itr.output.Items[itr.idx], out) {
+				continue
+			}
+			return itr.err == nil
 		}
 
-		// no, prepare next request and reset index
-		itr.input.ExclusiveStartKey = itr.output.LastEvaluatedKey
-		itr.idx = 0
-	}
-
-	itr.err = retry(ctx, func() error {
-		var err error
-		itr.output, err = itr.scan.table.db.client.ScanWithContext(ctx, itr.input)
-		return err
-	})
+		if itr.output != nil {
+			// have we exhausted all results?
+			if itr.output.LastEvaluatedKey == nil || itr.scan.searchLimit > 0 {
+				return false
+			}
+
+			// no, prepare next request and reset index
+			itr.input.ExclusiveStartKey = itr.output.LastEvaluatedKey
+			itr.idx = 0
+			// don't fetch more than needed to reach the limit
+			if remaining := itr.scan.limit - itr.n; itr.scan.limit > 0 && len(itr.scan.filters) == 0 &&
+				(itr.input.Limit == nil || remaining < *itr.input.Limit) {
+				itr.input.Limit = &remaining
+			}
+		} else if itr.input == nil {
+			// new scan
+			itr.input = itr.scan.scanInput()
+		}
 
-	if itr.err != nil {
-		return false
-	}
+		// stop between pages if ctx is done
+		if itr.err = ctx.Err(); itr.err != nil {
+			return false
+		}
 
-	if itr.scan.cc != nil {
-		addConsumedCapacity(itr.scan.cc, itr.output.ConsumedCapacity)
-	}
+		itr.err = itr.scan.table.db.retry(ctx, func() error {
+			if err := itr.scan.table.db.reads.wait(ctx); err != nil {
+				return err
+			}
+			var err error
+			start := time.Now()
+			itr.output, err = itr.scan.table.db.client.ScanWithContext(ctx, itr.input, itr.scan.opts...)
+			itr.scan.table.db.observe(ctx, "Scan", itr.input, itr.output, err, start)
+			return err
+		})
+
+		if itr.err != nil {
+			return false
+		}
 
-	if len(itr.output.Items) == 0 {
-		if itr.output.LastEvaluatedKey != nil {
-			return itr.NextWithContext(ctx, out)
+		if itr.scan.cc != nil {
+			addConsumedCapacity(itr.scan.cc, itr.output.ConsumedCapacity)
 		}
-		return false
+		itr.scan.stats.add(aws.Int64Value(itr.output.Count), aws.Int64Value(itr.output.ScannedCount), itr.output.ConsumedCapacity)
+		itr.scan.table.db.reads.spend(itr.output.ConsumedCapacity)
+
+		// pages with no data (e.g. everything was filtered out) are skipped
+		// by the next iteration, until we get some or run out of pages
 	}
+}
 
-	itr.err = itr.unmarshal(itr.output.Items[itr.idx], out)
+// decode unmarshals item into out and advances the iterator.
+// It returns true if item couldn't be unmarshaled but should be skipped.
+func (itr *scanIter) decode(item map[string]*dynamodb.AttributeValue, out interface{}) (skip bool) {
+	err := itr.unmarshal(item, out)
 	itr.idx++
+	itr.seen++
+	if err != nil {
+		itr.err = unmarshalError(itr.scan.onUnmarshal, itr.seen-1, item, err)
+		return itr.err == nil
+	}
 	itr.n++
-	return itr.err == nil
+	return false
 }
 
 // Err returns the error encountered, if any.
@@ -1,9 +1,16 @@
 package dynamo
 
 import (
+	"context"
+	"errors"
 	"reflect"
 	"testing"
 	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
 )
 
 func TestScan(t *testing.T) {
@@ -38,6 +45,14 @@ func TestScan(t *testing.T) {
 	if int(ct) != len(result) {
 		t.Errorf("count and scan don't match. count: %d, scan: %d", ct, len(result))
 	}
+
+	scanCt, err := table.Scan().Filter("UserID = ?", 42).Consistent(true).Count()
+	if err != nil {
+		t.Error("unexpected error:", err)
+	}
+	if int(scanCt) != len(result) {
+		t.Errorf("scan count and scan don't match. count: %d, scan: %d", scanCt, len(result))
+	}
 	if cc.Total == 0 {
 		t.Error("bad consumed capacity", cc)
 	}
@@ -74,3 +89,225 @@ func TestScanPaging(t *testing.T) {
 		itr = table.Scan().StartFrom(itr.LastEvaluatedKey()).SearchLimit(1).Iter()
 	}
 }
+
+func TestScanEach(t *testing.T) {
+	type user struct {
+		Idx  int
+		Name string
+	}
+	type invoice struct {
+		Idx   int
+		Total int
+	}
+	client := newMockClient(4)
+	for i, item := range client.items {
+		if i%2 == 0 {
+			item["Type"] = &dynamodb.AttributeValue{S: aws.String("user")}
+			item["Name"] = &dynamodb.AttributeValue{S: aws.String("Bob")}
+		} else {
+			item["Type"] = &dynamodb.AttributeValue{S: aws.String("invoice")}
+			item["Total"] = &dynamodb.AttributeValue{N: aws.String("100")}
+		}
+	}
+	table := NewFromIface(client).Table("Test")
+
+	var users []user
+	var invoices []invoice
+	err := table.Scan().SearchLimit(3).Each(func(item map[string]*dynamodb.AttributeValue) error {
+		switch aws.StringValue(item["Type"].S) {
+		case "user":
+			var u user
+			if err := UnmarshalItem(item, &u); err != nil {
+				return err
+			}
+			users = append(users, u)
+		case "invoice":
+			var inv invoice
+			if err := UnmarshalItem(item, &inv); err != nil {
+				return err
+			}
+			invoices = append(invoices, inv)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+	if !reflect.DeepEqual(users, []user{{0, "Bob"}, {2, "Bob"}}) {
+		t.Error("bad users:", users)
+	}
+	if !reflect.DeepEqual(invoices, []invoice{{1, 100}}) {
+		t.Error("bad invoices:", invoices)
+	}
+}
+
+func TestScanParallel(t *testing.T) {
+	if testDB == nil {
+		t.Skip(offlineSkipMsg)
+	}
+	table := testDB.Table(testTable)
+
+	var all []widget
+	if err := table.Scan().Filter("UserID = ?", 42).Consistent(true).All(&all); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	var parallel []widget
+	var cc ConsumedCapacity
+	err := table.Scan().Filter("UserID = ?", 42).Consistent(true).ConsumedCapacity(&cc).AllParallel(4, &parallel)
+	if err != nil {
+		t.Error("unexpected error:", err)
+	}
+	if len(parallel) != len(all) {
+		t.Errorf("parallel scan and scan don't match. parallel: %d, scan: %d", len(parallel), len(all))
+	}
+	if cc.Total == 0 {
+		t.Error("bad consumed capacity", cc)
+	}
+}
+
+// failingSegmentClient fails scans of one segment.
+type failingSegmentClient struct {
+	*mockClient
+	segment int64
+}
+
+func (c failingSegmentClient) ScanWithContext(ctx aws.Context, in *dynamodb.ScanInput, opts ...request.Option) (*dynamodb.ScanOutput, error) {
+	if aws.Int64Value(in.Segment) == c.segment {
+		return nil, awserr.NewRequestFailure(awserr.New("ValidationException", "bad segment", nil), 400, "")
+	}
+	return c.mockClient.ScanWithContext(ctx, in, opts...)
+}
+
+func TestScanIterParallel(t *testing.T) {
+	type item struct {
+		Idx int
+	}
+
+	// the mock ignores segments, so every worker sees every item
+	table := NewFromIface(newMockClient(10)).Table("Test")
+	itr := table.Scan().PageSize(3).IterParallel(3)
+	seen := make(map[int]int)
+	var it item
+	for itr.Next(&it) {
+		seen[it.Idx]++
+	}
+	if err := itr.Err(); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+	for i := 0; i < 10; i++ {
+		if seen[i] != 3 {
+			t.Errorf("item %d seen %d times, expected 3", i, seen[i])
+		}
+	}
+
+	var n int
+	itr = table.Scan().PageSize(3).Limit(4).IterParallel(3)
+	for itr.Next(&it) {
+		n++
+	}
+	if err := itr.Err(); err != nil || n != 4 {
+		t.Error("expected 4 results, got", n, err)
+	}
+
+	t.Run("segment error", func(t *testing.T) {
+		table := NewFromIface(failingSegmentClient{newMockClient(10), 1}).Table("Test")
+		itr := table.Scan().PageSize(1).IterParallel(3)
+		for itr.Next(&it) {
+		}
+		var segErr *SegmentError
+		if !errors.As(itr.Err(), &segErr) || segErr.Segment != 1 {
+			t.Error("expected segment 1 to fail, got", itr.Err())
+		}
+	})
+
+	t.Run("canceled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(aws.BackgroundContext())
+		itr := table.Scan().PageSize(1).IterParallel(2)
+		if !itr.NextWithContext(ctx, &it) {
+			t.Fatal("expected a result, got", itr.Err())
+		}
+		cancel()
+		for itr.NextWithContext(ctx, &it) {
+		}
+		if itr.Err() != context.Canceled {
+			t.Error("expected context.Canceled, got", itr.Err())
+		}
+	})
+
+	if err := table.Scan().IterParallel(0).Err(); err == nil {
+		t.Error("expected error for 0 workers")
+	}
+}
+
+func TestScanProjectExpr(t *testing.T) {
+	table := Table{name: "Test"}
+	s := table.Scan().ProjectExpr("$, Items[$], Meta.$", "Name", 0, "CreatedAt")
+	if s.err != nil {
+		t.Fatal(s.err)
+	}
+	in := s.scanInput()
+	expect := s.subName("Name") + ", Items[0], Meta." + s.subName("CreatedAt")
+	if in.ProjectionExpression == nil || *in.ProjectionExpression != expect {
+		t.Errorf("bad projection. %v ≠ %v", in.ProjectionExpression, expect)
+	}
+	if len(in.ExpressionAttributeNames) != 2 {
+		t.Error("bad names:", in.ExpressionAttributeNames)
+	}
+}
+
+func TestScanFilterOr(t *testing.T) {
+	table := Table{name: "Test"}
+	s := table.Scan().FilterOr("A = ?", 1)
+	if in := s.scanInput(); *in.FilterExpression != "(A = :v0)" {
+		t.Error("bad filter:", *in.FilterExpression)
+	}
+
+	s = table.Scan().Filter("A = ?", 1).Filter("B = ?", 2).FilterOr("C = ?", 3).Filter("D = ?", 4)
+	const expect = "(((A = :v0) AND (B = :v1)) OR (C = :v2)) AND (D = :v3)"
+	if in := s.scanInput(); *in.FilterExpression != expect {
+		t.Errorf("bad filter. %v ≠ %v", *in.FilterExpression, expect)
+	}
+}
+
+func TestScanFilterExists(t *testing.T) {
+	table := Table{name: "Test"}
+	s := table.Scan().Filter("A = ?", 1).FilterExists("Meta.Count").FilterNotExists("Deleted")
+	if s.err != nil {
+		t.Fatal(s.err)
+	}
+	expect := "(A = :v0) AND (attribute_exists(Meta." + s.subName("Count") + ")) AND (attribute_not_exists(Deleted))"
+	if in := s.scanInput(); *in.FilterExpression != expect {
+		t.Errorf("bad filter. %v ≠ %v", *in.FilterExpression, expect)
+	}
+
+	q := table.Get("UserID", 1).FilterNotExists("Size")
+	expect = "(attribute_not_exists(" + q.subName("Size") + "))"
+	if in := q.queryInput(); *in.FilterExpression != expect {
+		t.Errorf("bad query filter. %v ≠ %v", *in.FilterExpression, expect)
+	}
+}
+
+func TestScanFilterFuncs(t *testing.T) {
+	table := Table{name: "Test"}
+	s := table.Scan().
+		FilterAttributeType("Meta", "M").
+		FilterBeginsWith("Msg", "hello").
+		FilterContains("Tags", "a").
+		FilterSize("Count", GreaterOrEqual, 2)
+	if s.err != nil {
+		t.Fatal(s.err)
+	}
+	expect := "(attribute_type(Meta, :v0)) AND (begins_with(Msg, :v1)) AND (contains(Tags, :v2)) AND (size(" + s.subName("Count") + ") >= :v3)"
+	in := s.scanInput()
+	if *in.FilterExpression != expect {
+		t.Errorf("bad filter. %v ≠ %v", *in.FilterExpression, expect)
+	}
+	if *in.ExpressionAttributeValues[":v0"].S != "M" || *in.ExpressionAttributeValues[":v3"].N != "2" {
+		t.Error("bad values:", in.ExpressionAttributeValues)
+	}
+
+	if s := table.Scan().FilterSize("Tags", BeginsWith, 1); s.err == nil {
+		t.Error("expected error for BeginsWith size filter")
+	}
+}
@@ -0,0 +1,350 @@
+package dynamo
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodbstreams"
+)
+
+// StreamStart is the position in each shard of a stream where reading begins.
+type StreamStart string
+
+// Stream starting positions.
+const (
+	// TrimHorizon reads every record still retained in the stream, oldest first.
+	TrimHorizon StreamStart = dynamodbstreams.ShardIteratorTypeTrimHorizon
+	// Latest only reads records written after the stream is opened.
+	Latest StreamStart = dynamodbstreams.ShardIteratorTypeLatest
+)
+
+// maxStreamPoll caps the delay between polls of a stream with no new records.
+const maxStreamPoll = 10 * time.Second
+
+// Stream is a request to read the changes recorded in a table's DynamoDB Stream.
+// See: https://docs.aws.amazon.com/amazondynamodb/latest/developerguide/Streams.html
+type Stream struct {
+	table Table
+	arn   string
+	start StreamStart
+}
+
+// Stream begins a new request to read this table's stream.
+// Streams must be enabled for the table, see CreateTable.Stream and UpdateTable.Stream.
+// By default, the table's latest stream is read starting from TrimHorizon.
+func (table Table) Stream() *Stream {
+	return &Stream{
+		table: table,
+		start: TrimHorizon,
+	}
+}
+
+// ARN specifies the stream to read, instead of the table's latest stream.
+func (s *Stream) ARN(arn string) *Stream {
+	s.arn = arn
+	return s
+}
+
+// From specifies where to start reading: TrimHorizon or Latest.
+func (s *Stream) From(start StreamStart) *Stream {
+	s.start = start
+	return s
+}
+
+// Iter returns an iterator of this stream's records.
+// Shards are read parents first, so changes to an item are seen in order.
+// Open shards are polled for new records until the stream is disabled
+// and every shard has been read, or until an error occurs.
+func (s *Stream) Iter() *StreamIter {
+	return &StreamIter{
+		stream: s,
+		arn:    s.arn,
+	}
+}
+
+// StreamRecord describes a change from a stream.
+type StreamRecord struct {
+	// EventID uniquely identifies this record.
+	EventID string
+	// EventName is the type of change: INSERT, MODIFY, or REMOVE.
+	EventName string
+	// SequenceNumber orders this record within its shard.
+	SequenceNumber string
+	// Created is the approximate time the change was made.
+	Created time.Time
+	// Keys holds the primary key of the changed item.
+	Keys map[string]*dynamodb.AttributeValue
+}
+
+// StreamIter is an iterator of stream records.
+type StreamIter struct {
+	stream *Stream
+	arn    string
+
+	active   []*streamShard
+	pending  []*streamShard
+	seen     map[string]bool
+	done     map[string]bool
+	disabled bool
+
+	pos    int
+	polled bool
+	got    bool
+	idle   *jitterBackOff
+
+	records []*dynamodbstreams.Record
+	idx     int
+	record  StreamRecord
+
+	err error
+}
+
+type streamShard struct {
+	id     string
+	parent string
+	start  StreamStart
+	iter   *string
+}
+
+// Next tries to unmarshal the next record's old and new images into old and new.
+// Either may be nil to skip it. If the record has no such image, such as the old image
+// of an INSERT or when the stream's view doesn't include it, the out value is reset to its zero value.
+// Returns false when the stream is finished or if it runs into an error.
+// Because Next uses the default timeout for the whole call, long-running consumers should
+// use NextWithContext instead.
+func (itr *StreamIter) Next(old, new interface{}) bool {
+	ctx, cancel := defaultContext()
+	defer cancel()
+	return itr.NextWithContext(ctx, old, new)
+}
+
+// NextWithContext tries to unmarshal the next record's old and new images into old and new.
+// It blocks while polling for new records until one arrives, the stream is finished, or ctx is done.
+func (itr *StreamIter) NextWithContext(ctx aws.Context, old, new interface{}) bool {
+	if itr.err != nil {
+		return false
+	}
+
+	if itr.seen == nil {
+		if itr.err = itr.init(ctx); itr.err != nil {
+			return false
+		}
+	}
+
+	for itr.idx >= len(itr.records) {
+		var more bool
+		more, itr.err = itr.fetch(ctx)
+		if itr.err != nil || !more {
+			return false
+		}
+	}
+
+	rec := itr.records[itr.idx]
+	itr.idx++
+	itr.record = StreamRecord{
+		EventID:   aws.StringValue(rec.EventID),
+		EventName: aws.StringValue(rec.EventName),
+	}
+	var oldImage, newImage map[string]*dynamodb.AttributeValue
+	if rec.Dynamodb != nil {
+		itr.record.SequenceNumber = aws.StringValue(rec.Dynamodb.SequenceNumber)
+		itr.record.Created = aws.TimeValue(rec.Dynamodb.ApproximateCreationDateTime)
+		itr.record.Keys = rec.Dynamodb.Keys
+		oldImage, newImage = rec.Dynamodb.OldImage, rec.Dynamodb.NewImage
+	}
+	if old != nil {
+		if itr.err = itr.stream.table.db.decoder().unmarshalItem(oldImage, old); itr.err != nil {
+			return false
+		}
+	}
+	if new != nil {
+		if itr.err = itr.stream.table.db.decoder().unmarshalItem(newImage, new); itr.err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// Record returns details of the record last returned by Next.
+func (itr *StreamIter) Record() StreamRecord {
+	return itr.record
+}
+
+// Err returns the error encountered, if any.
+// You should check this after Next is finished.
+func (itr *StreamIter) Err() error {
+	return itr.err
+}
+
+func (itr *StreamIter) init(ctx aws.Context) error {
+	db := itr.stream.table.db
+	if db.streams == nil {
+		return errors.New("dynamo: stream: no streams client, see DB.SetStreamsClient")
+	}
+	if itr.arn == "" {
+		desc, err := itr.stream.table.Describe().RunWithContext(ctx)
+		if err != nil {
+			return err
+		}
+		if desc.LatestStreamARN == "" {
+			return fmt.Errorf("dynamo: stream: streams are not enabled for table %s", itr.stream.table.name)
+		}
+		itr.arn = desc.LatestStreamARN
+	}
+
+	itr.seen = make(map[string]bool)
+	itr.done = make(map[string]bool)
+	itr.idle = db.retryCfg.backoff()
+	itr.idle.MaxElapsedTime = 0
+	if itr.idle.MaxInterval > maxStreamPoll {
+		itr.idle.MaxInterval = maxStreamPoll
+	}
+	return itr.refresh(ctx, itr.stream.start)
+}
+
+// fetch polls the next active shard for records, or at the end of a round of polling,
+// waits if nothing new was found and discovers new shards.
+// It returns false when the stream is finished.
+func (itr *StreamIter) fetch(ctx aws.Context) (more bool, err error) {
+	db := itr.stream.table.db
+
+	if itr.pos >= len(itr.active) {
+		if itr.polled && !itr.got {
+			if err := aws.SleepWithContext(ctx, itr.idle.NextBackOff()); err != nil {
+				return false, err
+			}
+		}
+		itr.pos, itr.polled, itr.got = 0, false, false
+		if err := itr.refresh(ctx, TrimHorizon); err != nil {
+			return false, err
+		}
+		if len(itr.active) == 0 && len(itr.pending) == 0 && itr.disabled {
+			return false, nil
+		}
+		if len(itr.active) == 0 {
+			itr.polled = true
+		}
+		return true, nil
+	}
+
+	shard := itr.active[itr.pos]
+	if shard.iter == nil {
+		input := &dynamodbstreams.GetShardIteratorInput{
+			StreamArn:         aws.String(itr.arn),
+			ShardId:           aws.String(shard.id),
+			ShardIteratorType: aws.String(string(shard.start)),
+		}
+		err := db.retry(ctx, func() error {
+			start := time.Now()
+			out, err := db.streams.GetShardIteratorWithContext(ctx, input)
+			db.observe(ctx, "GetShardIterator", input, out, err, start)
+			if err != nil {
+				return err
+			}
+			shard.iter = out.ShardIterator
+			return nil
+		})
+		if err != nil {
+			return false, err
+		}
+	}
+
+	var out *dynamodbstreams.GetRecordsOutput
+	input := &dynamodbstreams.GetRecordsInput{
+		ShardIterator: shard.iter,
+	}
+	err = db.retry(ctx, func() error {
+		var err error
+		start := time.Now()
+		out, err = db.streams.GetRecordsWithContext(ctx, input)
+		db.observe(ctx, "GetRecords", input, out, err, start)
+		return err
+	})
+	if err != nil {
+		return false, err
+	}
+
+	itr.records, itr.idx = out.Records, 0
+	itr.polled = true
+	if len(out.Records) > 0 {
+		itr.got = true
+		itr.idle.Reset()
+	}
+
+	shard.iter = out.NextShardIterator
+	if shard.iter == nil {
+		// shard is closed and fully read
+		itr.done[shard.id] = true
+		itr.active = append(itr.active[:itr.pos], itr.active[itr.pos+1:]...)
+	} else {
+		itr.pos++
+	}
+	return true, nil
+}
+
+// refresh discovers new shards and activates those whose parents have been read.
+// New shards start from the given position.
+func (itr *StreamIter) refresh(ctx aws.Context, start StreamStart) error {
+	db := itr.stream.table.db
+	input := &dynamodbstreams.DescribeStreamInput{
+		StreamArn: aws.String(itr.arn),
+	}
+	for {
+		var desc *dynamodbstreams.StreamDescription
+		err := db.retry(ctx, func() error {
+			start := time.Now()
+			out, err := db.streams.DescribeStreamWithContext(ctx, input)
+			db.observe(ctx, "DescribeStream", input, out, err, start)
+			if err != nil {
+				return err
+			}
+			desc = out.StreamDescription
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		if desc == nil {
+			break
+		}
+
+		itr.disabled = aws.StringValue(desc.StreamStatus) == dynamodbstreams.StreamStatusDisabled
+		for _, s := range desc.Shards {
+			id := aws.StringValue(s.ShardId)
+			if itr.seen[id] {
+				continue
+			}
+			itr.seen[id] = true
+			closed := s.SequenceNumberRange != nil && s.SequenceNumberRange.EndingSequenceNumber != nil
+			if start == Latest && closed {
+				// nothing new will be written to this shard
+				itr.done[id] = true
+				continue
+			}
+			itr.pending = append(itr.pending, &streamShard{
+				id:     id,
+				parent: aws.StringValue(s.ParentShardId),
+				start:  start,
+			})
+		}
+
+		if desc.LastEvaluatedShardId == nil {
+			break
+		}
+		input.ExclusiveStartShardId = desc.LastEvaluatedShardId
+	}
+
+	pending := itr.pending[:0]
+	for _, shard := range itr.pending {
+		if shard.parent == "" || !itr.seen[shard.parent] || itr.done[shard.parent] {
+			itr.active = append(itr.active, shard)
+			continue
+		}
+		pending = append(pending, shard)
+	}
+	itr.pending = pending
+	return nil
+}
@@ -0,0 +1,199 @@
+package dynamo
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodbstreams"
+	"github.com/aws/aws-sdk-go/service/dynamodbstreams/dynamodbstreamsiface"
+)
+
+// mockStreams is a fake DynamoDB Streams client for offline tests.
+// Each shard's records are returned one per GetRecords call, after which the shard is closed.
+type mockStreams struct {
+	dynamodbstreamsiface.DynamoDBStreamsAPI
+	shards  []*dynamodbstreams.Shard
+	records map[string][]*dynamodbstreams.Record
+	// iterTypes records the iterator type requested for each shard
+	iterTypes map[string]string
+}
+
+func (m *mockStreams) DescribeStreamWithContext(_ aws.Context, in *dynamodbstreams.DescribeStreamInput, _ ...request.Option) (*dynamodbstreams.DescribeStreamOutput, error) {
+	// one shard per page
+	start := 0
+	if in.ExclusiveStartShardId != nil {
+		for i, s := range m.shards {
+			if *s.ShardId == *in.ExclusiveStartShardId {
+				start = i + 1
+			}
+		}
+	}
+	desc := &dynamodbstreams.StreamDescription{
+		StreamArn:    in.StreamArn,
+		StreamStatus: aws.String(dynamodbstreams.StreamStatusDisabled),
+	}
+	if start < len(m.shards) {
+		desc.Shards = m.shards[start : start+1]
+		if start+1 < len(m.shards) {
+			desc.LastEvaluatedShardId = m.shards[start].ShardId
+		}
+	}
+	return &dynamodbstreams.DescribeStreamOutput{StreamDescription: desc}, nil
+}
+
+func (m *mockStreams) GetShardIteratorWithContext(_ aws.Context, in *dynamodbstreams.GetShardIteratorInput, _ ...request.Option) (*dynamodbstreams.GetShardIteratorOutput, error) {
+	if m.iterTypes == nil {
+		m.iterTypes = make(map[string]string)
+	}
+	m.iterTypes[*in.ShardId] = *in.ShardIteratorType
+	return &dynamodbstreams.GetShardIteratorOutput{ShardIterator: in.ShardId}, nil
+}
+
+func (m *mockStreams) GetRecordsWithContext(_ aws.Context, in *dynamodbstreams.GetRecordsInput, _ ...request.Option) (*dynamodbstreams.GetRecordsOutput, error) {
+	id := *in.ShardIterator
+	recs := m.records[id]
+	if len(recs) == 0 {
+		return &dynamodbstreams.GetRecordsOutput{}, nil
+	}
+	m.records[id] = recs[1:]
+	return &dynamodbstreams.GetRecordsOutput{
+		Records:           recs[:1],
+		NextShardIterator: in.ShardIterator,
+	}, nil
+}
+
+func streamRecord(event, seq string, old, new map[string]*dynamodb.AttributeValue) *dynamodbstreams.Record {
+	return &dynamodbstreams.Record{
+		EventID:   aws.String("event-" + seq),
+		EventName: aws.String(event),
+		Dynamodb: &dynamodbstreams.StreamRecord{
+			SequenceNumber: aws.String(seq),
+			OldImage:       old,
+			NewImage:       new,
+		},
+	}
+}
+
+func closedShard(id, parent string) *dynamodbstreams.Shard {
+	shard := &dynamodbstreams.Shard{
+		ShardId: aws.String(id),
+		SequenceNumberRange: &dynamodbstreams.SequenceNumberRange{
+			StartingSequenceNumber: aws.String("0"),
+			EndingSequenceNumber:   aws.String("9"),
+		},
+	}
+	if parent != "" {
+		shard.ParentShardId = aws.String(parent)
+	}
+	return shard
+}
+
+func TestStream(t *testing.T) {
+	type item struct {
+		ID  int
+		Msg string
+	}
+	img := func(id int, msg string) map[string]*dynamodb.AttributeValue {
+		av, _ := marshalItem(item{ID: id, Msg: msg})
+		return av
+	}
+
+	newStreams := func() *mockStreams {
+		return &mockStreams{
+			// child is listed before its parent, but must be read after it
+			shards: []*dynamodbstreams.Shard{
+				closedShard("child", "parent"),
+				closedShard("parent", ""),
+			},
+			records: map[string][]*dynamodbstreams.Record{
+				"parent": {
+					streamRecord(dynamodbstreams.OperationTypeInsert, "1", nil, img(1, "hello")),
+					streamRecord(dynamodbstreams.OperationTypeModify, "2", img(1, "hello"), img(1, "goodbye")),
+				},
+				"child": {
+					streamRecord(dynamodbstreams.OperationTypeRemove, "3", img(1, "goodbye"), nil),
+				},
+			},
+		}
+	}
+
+	t.Run("trim horizon", func(t *testing.T) {
+		streams := newStreams()
+		db := NewFromIface(newMockClient(0))
+		db.SetStreamsClient(streams)
+		db.SetRetryConfig(RetryConfig{BaseDelay: time.Millisecond})
+
+		itr := db.Table("Test").Stream().ARN("arn:test").Iter()
+		var events []string
+		var olds, news []item
+		var old, new item
+		for itr.Next(&old, &new) {
+			events = append(events, itr.Record().EventName+" "+itr.Record().SequenceNumber)
+			olds = append(olds, old)
+			news = append(news, new)
+		}
+		if err := itr.Err(); err != nil {
+			t.Fatal("unexpected error:", err)
+		}
+
+		expectEvents := []string{"INSERT 1", "MODIFY 2", "REMOVE 3"}
+		if !reflect.DeepEqual(events, expectEvents) {
+			t.Errorf("bad events. %v ≠ %v", events, expectEvents)
+		}
+		expectOlds := []item{{}, {1, "hello"}, {1, "goodbye"}}
+		if !reflect.DeepEqual(olds, expectOlds) {
+			t.Errorf("bad old images. %v ≠ %v", olds, expectOlds)
+		}
+		expectNews := []item{{1, "hello"}, {1, "goodbye"}, {}}
+		if !reflect.DeepEqual(news, expectNews) {
+			t.Errorf("bad new images. %v ≠ %v", news, expectNews)
+		}
+		for _, id := range []string{"parent", "child"} {
+			if streams.iterTypes[id] != dynamodbstreams.ShardIteratorTypeTrimHorizon {
+				t.Errorf("bad iterator type for %s: %s", id, streams.iterTypes[id])
+			}
+		}
+	})
+
+	t.Run("latest", func(t *testing.T) {
+		streams := newStreams()
+		// only the open shard is read
+		streams.shards[0].SequenceNumberRange.EndingSequenceNumber = nil
+		db := NewFromIface(newMockClient(0))
+		db.SetStreamsClient(streams)
+		db.SetRetryConfig(RetryConfig{BaseDelay: time.Millisecond})
+
+		itr := db.Table("Test").Stream().ARN("arn:test").From(Latest).Iter()
+		var events []string
+		for itr.Next(nil, nil) {
+			events = append(events, itr.Record().EventName)
+		}
+		if err := itr.Err(); err != nil {
+			t.Fatal("unexpected error:", err)
+		}
+		if !reflect.DeepEqual(events, []string{"REMOVE"}) {
+			t.Error("bad events:", events)
+		}
+		if _, ok := streams.iterTypes["parent"]; ok {
+			t.Error("closed shard was read")
+		}
+		if streams.iterTypes["child"] != dynamodbstreams.ShardIteratorTypeLatest {
+			t.Error("bad iterator type:", streams.iterTypes["child"])
+		}
+	})
+
+	t.Run("no client", func(t *testing.T) {
+		db := NewFromIface(newMockClient(0))
+		itr := db.Table("Test").Stream().ARN("arn:test").Iter()
+		if itr.Next(nil, nil) {
+			t.Error("Next returned true")
+		}
+		if itr.Err() == nil {
+			t.Error("expected error")
+		}
+	})
+}
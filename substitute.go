@@ -5,8 +5,10 @@ import (
 	"encoding"
 	"encoding/base32"
 	"fmt"
+	"reflect"
 	"strconv"
 	"strings"
+	"unicode"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/service/dynamodb"
@@ -55,8 +57,15 @@ func (s *subber) subExpr(expr string, args ...interface{}) (string, error) {
 
 	var buf bytes.Buffer
 	var idx int
-	for _, item := range lexed.Items {
+	for i, item := range lexed.Items {
 		var err error
+		arg := idx
+		switch item.Type {
+		case exprs.ItemNamePlaceholder, exprs.ItemValuePlaceholder, exprs.ItemMagicLiteral:
+			if idx >= len(args) {
+				return "", fmt.Errorf("dynamo: too few arguments for placeholders in expression %q (got %d)", expr, len(args))
+			}
+		}
 		switch item.Type {
 		case exprs.ItemText:
 			_, err = buf.WriteString(item.Val)
@@ -84,8 +93,19 @@ func (s *subber) subExpr(expr string, args ...interface{}) (string, error) {
 			}
 			idx++
 		case exprs.ItemValuePlaceholder:
+			if ok, wrap := inList(buf.Bytes(), lexed.Items[i+1:]); ok && isExpandable(args[idx]) {
+				if wrap {
+					buf.WriteByte('(')
+				}
+				err = s.subList(&buf, args[idx])
+				if wrap {
+					buf.WriteByte(')')
+				}
+				idx++
+				break
+			}
 			var sub string
-			if sub, err = s.subValue(args[idx], ""); err == nil {
+			if sub, err = s.subArg(buf.Bytes(), args[idx]); err == nil {
 				_, err = buf.WriteString(sub)
 			}
 			idx++
@@ -94,13 +114,114 @@ func (s *subber) subExpr(expr string, args ...interface{}) (string, error) {
 			idx++
 		}
 		if err != nil {
-			return "", err
+			return "", fmt.Errorf("dynamo: placeholder %d in expression %q: %s", arg+1, expr, strings.TrimPrefix(err.Error(), "dynamo: "))
 		}
 	}
+	if idx < len(args) {
+		return "", fmt.Errorf("dynamo: too many arguments for placeholders in expression %q (want %d, got %d)", expr, idx, len(args))
+	}
 
 	return buf.String(), nil
 }
 
+// subArg substitutes v for a ? placeholder, checking that it can be used there.
+// prev is the expression up to the placeholder.
+func (s *subber) subArg(prev []byte, v interface{}) (string, error) {
+	sub, err := s.subValue(v, "")
+	if err != nil {
+		return "", err
+	}
+	av := s.valueExpr[sub]
+	if av == nil {
+		delete(s.valueExpr, sub)
+		return "", fmt.Errorf("dynamo: argument of type %T is empty, such as a nil pointer or empty set", v)
+	}
+	if op := compareOp(prev); op != "" && av.N == nil && av.S == nil && av.B == nil {
+		return "", fmt.Errorf("dynamo: argument of type %T (%s) can't be compared with %s, only numbers, strings, and binary can", v, avTypeName(av), op)
+	}
+	return sub, nil
+}
+
+// compareOp returns the ordering comparison operator (<, <=, >, or >=)
+// that prev ends with, or "" if it doesn't end with one.
+func compareOp(prev []byte) string {
+	prev = bytes.TrimRightFunc(prev, unicode.IsSpace)
+	for _, op := range []string{"<=", ">=", "<", ">"} {
+		if bytes.HasSuffix(prev, []byte(op)) {
+			if op == ">" && bytes.HasSuffix(prev, []byte("<>")) {
+				return ""
+			}
+			return op
+		}
+	}
+	return ""
+}
+
+// subList substitutes each element of the slice or array v
+// as a separate value, separated by commas.
+func (s *subber) subList(buf *bytes.Buffer, v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Len() == 0 {
+		return fmt.Errorf("dynamo: empty %T for IN placeholder", v)
+	}
+	for i := 0; i < rv.Len(); i++ {
+		if i != 0 {
+			buf.WriteString(", ")
+		}
+		sub, err := s.subValue(rv.Index(i).Interface(), "")
+		if err != nil {
+			return err
+		}
+		buf.WriteString(sub)
+	}
+	return nil
+}
+
+// inList reports whether a placeholder is used as the list of an IN operator,
+// either bare like "A IN ?" or as the only item in parens like "A IN (?)".
+// wrap is true if the list needs to be wrapped in parens.
+// prev is the expression up to the placeholder and next are
+// the items following it.
+func inList(prev []byte, next []exprs.Item) (ok, wrap bool) {
+	prev = bytes.TrimRightFunc(prev, unicode.IsSpace)
+	wrap = true
+	if bytes.HasSuffix(prev, []byte("(")) {
+		if len(next) == 0 || next[0].Type != exprs.ItemText ||
+			!strings.HasPrefix(strings.TrimLeftFunc(next[0].Val, unicode.IsSpace), ")") {
+			return false, false
+		}
+		prev = bytes.TrimRightFunc(prev[:len(prev)-1], unicode.IsSpace)
+		wrap = false
+	}
+	if len(prev) < 2 || !bytes.EqualFold(prev[len(prev)-2:], []byte("IN")) {
+		return false, false
+	}
+	if len(prev) == 2 {
+		return true, wrap
+	}
+	before := rune(prev[len(prev)-3])
+	if unicode.IsLetter(before) || unicode.IsDigit(before) || before == '_' {
+		return false, false
+	}
+	return true, wrap
+}
+
+// isExpandable returns true if v is a slice or array that
+// should be substituted as a list of values.
+// Byte slices and custom marshalers are left as-is.
+func isExpandable(v interface{}) bool {
+	switch v.(type) {
+	case Marshaler, encoding.TextMarshaler, []byte:
+		return false
+	}
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Slice, reflect.Array:
+		return rv.Type().Elem().Kind() != reflect.Uint8
+	}
+	return false
+}
+
 // encodeName consistently encodes a name.
 // The consistency is important.
 func encodeName(name string) string {
@@ -108,18 +229,74 @@ func encodeName(name string) string {
 	return strings.TrimRight(name, "=")
 }
 
-// escape takes a name and evaluates and substitutes it if needed.
-func (s *subber) escape(name string) (string, error) {
-	// reserved word
-	if upper := strings.ToUpper(name); reserved[upper] {
-		return s.subName(name), nil
+// escape takes an attribute path and substitutes its names if needed.
+// Paths are names separated by dots, each optionally followed by list indexes, like Address.Lines[0].
+// Names that are reserved words or aren't plain identifiers are substituted,
+// as are names in single quotes like 'User'.'Count', which may contain dots and brackets.
+func (s *subber) escape(path string) (string, error) {
+	var buf strings.Builder
+	rest := path
+	for {
+		var name string
+		quoted := strings.HasPrefix(rest, "'")
+		if quoted {
+			end := strings.IndexByte(rest[1:], '\'')
+			if end == -1 {
+				return "", fmt.Errorf("dynamo: unterminated quote in path %q", path)
+			}
+			name, rest = rest[1:end+1], rest[end+2:]
+		} else {
+			end := strings.IndexAny(rest, ".[")
+			if end == -1 {
+				end = len(rest)
+			}
+			name, rest = rest[:end], rest[end:]
+		}
+		switch {
+		case name == "":
+			return "", fmt.Errorf("dynamo: empty name in path %q", path)
+		case quoted || reserved[strings.ToUpper(name)] || !isIdent(name):
+			buf.WriteString(s.subName(name))
+		default:
+			buf.WriteString(name)
+		}
+
+		// list indexes
+		for strings.HasPrefix(rest, "[") {
+			end := strings.IndexByte(rest, ']')
+			if end == -1 {
+				return "", fmt.Errorf("dynamo: unterminated index in path %q", path)
+			}
+			if _, err := strconv.ParseUint(rest[1:end], 10, 64); err != nil {
+				return "", fmt.Errorf("dynamo: invalid index in path %q", path)
+			}
+			buf.WriteString(rest[:end+1])
+			rest = rest[end+1:]
+		}
+
+		if rest == "" {
+			return buf.String(), nil
+		}
+		if rest[0] != '.' {
+			return "", fmt.Errorf("dynamo: invalid path %q", path)
+		}
+		buf.WriteByte('.')
+		rest = rest[1:]
 	}
-	// needs to be parsed
-	if strings.ContainsAny(name, ".[]()'") {
-		return s.subExpr(name, nil)
+}
+
+// isIdent returns true if name can be used in an expression as-is:
+// a letter followed by letters, digits, or underscores.
+func isIdent(name string) bool {
+	for i, r := range name {
+		switch {
+		case unicode.IsLetter(r) && r < unicode.MaxASCII:
+		case i > 0 && (r == '_' || (r >= '0' && r <= '9')):
+		default:
+			return false
+		}
 	}
-	// boring
-	return name, nil
+	return true
 }
 
 // wrapExpr wraps expr in parens if needed
@@ -132,3 +309,12 @@ func wrapExpr(expr string) string {
 	}
 	return "(" + expr + ")"
 }
+
+// orFilters combines filters, which are joined by AND, with expr using OR.
+// The result is wrapped in parens so later filters can be ANDed to it.
+func orFilters(filters []string, expr string) []string {
+	if len(filters) == 0 {
+		return []string{expr}
+	}
+	return []string{"((" + strings.Join(filters, " AND ") + ") OR " + expr + ")"}
+}
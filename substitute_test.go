@@ -21,6 +21,126 @@ func TestSubExpr(t *testing.T) {
 	}
 }
 
+func TestSubExprArgCount(t *testing.T) {
+	s := subber{}
+	if _, err := s.subExpr("$ = ? AND Msg = ?", "Count", 1); err == nil {
+		t.Error("expected error for too few args")
+	}
+	if _, err := s.subExpr("Msg = ?", 1, 2); err == nil {
+		t.Error("expected error for too many args")
+	}
+	if _, err := s.subExpr("attribute_exists(Msg)"); err != nil {
+		t.Error("unexpected error:", err)
+	}
+}
+
+func TestSubExprInList(t *testing.T) {
+	s := subber{}
+	subbed, err := s.subExpr("Msg IN (?) AND UserID in(?) AND Count = ?", []string{"a", "b", "c"}, [2]int{1, 2}, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const expect = "Msg IN (:v0, :v1, :v2) AND UserID in(:v3, :v4) AND Count = :v5"
+	if subbed != expect {
+		t.Errorf("bad subbed expr: %v ≠ %v", subbed, expect)
+	}
+	if len(s.valueExpr) != 6 || *s.valueExpr[":v4"].N != "2" {
+		t.Errorf("bad values: %v", s.valueExpr)
+	}
+
+	// slices outside of IN are a single list value
+	s = subber{}
+	subbed, err = s.subExpr("Tags = ? AND contains(Info, ?)", []string{"a", "b"}, []string{"c"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if subbed != "Tags = :v0 AND contains(Info, :v1)" || s.valueExpr[":v0"].L == nil {
+		t.Errorf("unexpected expansion: %v %v", subbed, s.valueExpr)
+	}
+
+	// multiple placeholders mean one value each
+	s = subber{}
+	subbed, err = s.subExpr("Msg IN (?, ?)", []string{"a"}, []string{"b"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if subbed != "Msg IN (:v0, :v1)" || s.valueExpr[":v0"].L == nil {
+		t.Errorf("unexpected expansion: %v %v", subbed, s.valueExpr)
+	}
+
+	// bare placeholders get wrapped in parens
+	s = subber{}
+	subbed, err = s.subExpr("'Msg' IN ? OR Count IN ?", []string{"a", "b"}, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expect := "#sJVZWO IN (:v0, :v1) OR Count IN :v2"; subbed != expect {
+		t.Errorf("bad subbed expr: %v ≠ %v", subbed, expect)
+	}
+
+	// byte slices are binary values, not lists
+	s = subber{}
+	subbed, err = s.subExpr("Data IN ?", []byte("abc"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if subbed != "Data IN :v0" || s.valueExpr[":v0"].B == nil {
+		t.Errorf("unexpected expansion: %v %v", subbed, s.valueExpr)
+	}
+
+	s = subber{}
+	if _, err := s.subExpr("Msg IN (?)", []string{}); err == nil {
+		t.Error("expected error for empty slice")
+	}
+	s = subber{}
+	if _, err := s.subExpr("Msg IN ?", []string{}); err == nil {
+		t.Error("expected error for empty slice")
+	}
+}
+
+func TestSubExprArgTypes(t *testing.T) {
+	s := subber{}
+	subbed, err := s.subExpr("Price > ? AND Status = ? AND Active = ?", 9.99, "ok", true)
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+	if subbed != "Price > :v0 AND Status = :v1 AND Active = :v2" {
+		t.Error("bad subbed expr:", subbed)
+	}
+	if s.valueExpr[":v0"].N == nil || s.valueExpr[":v1"].S == nil || s.valueExpr[":v2"].BOOL == nil {
+		t.Error("bad value types:", s.valueExpr)
+	}
+
+	type point struct{ X, Y int }
+	tests := []struct {
+		expr string
+		args []interface{}
+		want string
+	}{
+		{"Price > ? AND Status = ?", []interface{}{point{1, 2}, "ok"},
+			`dynamo: placeholder 1 in expression "Price > ? AND Status = ?": argument of type dynamo.point (map) can't be compared with >, only numbers, strings, and binary can`},
+		{"$ <= ?", []interface{}{"Price", true},
+			`dynamo: placeholder 2 in expression "$ <= ?": argument of type bool (boolean) can't be compared with <=, only numbers, strings, and binary can`},
+		{"Price = ? AND Status = ?", []interface{}{1, (*string)(nil)},
+			`dynamo: placeholder 2 in expression "Price = ? AND Status = ?": argument of type *string is empty, such as a nil pointer or empty set`},
+		{"$ = ?", []interface{}{1.5, "x"},
+			`dynamo: placeholder 1 in expression "$ = ?": type of argument for $ must be string, int, or int64 (got float64)`},
+	}
+	for _, test := range tests {
+		s := subber{}
+		_, err := s.subExpr(test.expr, test.args...)
+		if err == nil || err.Error() != test.want {
+			t.Errorf("bad error for %q.\nwant: %s\ngot:  %v", test.expr, test.want, err)
+		}
+	}
+
+	// maps and lists can still be compared for equality
+	s = subber{}
+	if _, err := s.subExpr("Location = ? AND Location <> ?", point{1, 2}, point{3, 4}); err != nil {
+		t.Error("unexpected error:", err)
+	}
+}
+
 func BenchmarkSubExpr(b *testing.B) {
 	const expr = "'User' = ? AND $ > ?"
 	for i := 0; i < b.N; i++ {
@@ -28,3 +148,35 @@ func BenchmarkSubExpr(b *testing.B) {
 		s.subExpr(expr, 613, "Time", "2015-12-04")
 	}
 }
+
+func TestEscape(t *testing.T) {
+	s := subber{}
+	tests := []struct {
+		path   string
+		expect string
+	}{
+		{"Msg", "Msg"},
+		{"Count", s.subName("Count")},
+		{"Address.Zip", "Address.Zip"},
+		{"Address.Zone", "Address." + s.subName("Zone")},
+		{"Meta.Tags[0][1].Name", "Meta.Tags[0][1]." + s.subName("Name")},
+		{"'User'.'a.b'[2]", s.subName("User") + "." + s.subName("a.b") + "[2]"},
+		{"with-dash.x_1", s.subName("with-dash") + ".x_1"},
+	}
+	for _, test := range tests {
+		got, err := s.escape(test.path)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", test.path, err)
+			continue
+		}
+		if got != test.expect {
+			t.Errorf("%s: bad escape: %v ≠ %v", test.path, got, test.expect)
+		}
+	}
+
+	for _, bad := range []string{"", "a..b", "a.", "'open", "a[x]", "a[1", "a[1]b"} {
+		if _, err := s.escape(bad); err == nil {
+			t.Errorf("%q: expected error", bad)
+		}
+	}
+}
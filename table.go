@@ -1,8 +1,13 @@
 package dynamo
 
 import (
+	"fmt"
+	"reflect"
+	"time"
+
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/cenkalti/backoff"
 )
 
 // Status is an enumeration of table and index statuses.
@@ -21,8 +26,9 @@ const (
 
 // Table is a DynamoDB table.
 type Table struct {
-	name string
-	db   *DB
+	name       string
+	db         *DB
+	consistent bool
 }
 
 // Table returns a Table handle specified by name.
@@ -38,6 +44,95 @@ func (table Table) Name() string {
 	return table.name
 }
 
+// DefaultConsistent returns a copy of this table handle whose Get and Query requests
+// use strongly consistent reads when on is true, unless overridden with Query.Consistent.
+// Queries of an index ignore this default, as global secondary indexes can't be read consistently.
+func (table Table) DefaultConsistent(on bool) Table {
+	table.consistent = on
+	return table
+}
+
+// CountAll returns the approximate number of items in this table, as reported by DescribeTable.
+// DynamoDB only updates this count about every six hours, so recent changes might not be reflected.
+// Unlike counting with a scan, it consumes no read capacity.
+func (table Table) CountAll() (int64, error) {
+	ctx, cancel := defaultContext()
+	defer cancel()
+	return table.CountAllWithContext(ctx)
+}
+
+// CountAllWithContext returns the approximate number of items in this table, as reported by DescribeTable.
+// See CountAll for details.
+func (table Table) CountAllWithContext(ctx aws.Context) (int64, error) {
+	desc, err := table.Describe().RunWithContext(ctx)
+	if err != nil {
+		return 0, err
+	}
+	return desc.Items, nil
+}
+
+// Wait blocks until this table's status matches any status provided,
+// or ActiveStatus if none are given. When waiting for ActiveStatus,
+// this also waits for all global secondary indexes to become active.
+func (table Table) Wait(want ...Status) error {
+	ctx, cancel := defaultContext()
+	defer cancel()
+	return table.WaitWithContext(ctx, want...)
+}
+
+// WaitWithContext blocks until this table's status matches any status provided,
+// or ActiveStatus if none are given. When waiting for ActiveStatus,
+// this also waits for all global secondary indexes to become active.
+// The table is polled using the DB's retry backoff until ctx is done.
+// A table that doesn't exist yet is waited for, but a table that is being deleted
+// returns an error unless DeletingStatus is wanted.
+func (table Table) WaitWithContext(ctx aws.Context, want ...Status) error {
+	if len(want) == 0 {
+		want = []Status{ActiveStatus}
+	}
+
+	b := backoff.WithContext(table.db.retryCfg.backoff(), ctx)
+	for {
+		desc, err := table.Describe().RunWithContext(ctx)
+		switch {
+		case err != nil && !isResourceNotFound(err):
+			return err
+		case err == nil && desc.Status == DeletingStatus && !hasStatus(want, DeletingStatus):
+			return fmt.Errorf("dynamo: table %s is being deleted", table.name)
+		case err == nil && hasStatus(want, desc.Status):
+			if desc.Status != ActiveStatus || indexesActive(desc.GSI) {
+				return nil
+			}
+		}
+
+		next := b.NextBackOff()
+		if next == backoff.Stop {
+			return fmt.Errorf("dynamo: gave up waiting for table %s", table.name)
+		}
+		if err := aws.SleepWithContext(ctx, next); err != nil {
+			return err
+		}
+	}
+}
+
+func hasStatus(statuses []Status, status Status) bool {
+	for _, s := range statuses {
+		if s == status {
+			return true
+		}
+	}
+	return false
+}
+
+func indexesActive(indexes []Index) bool {
+	for _, idx := range indexes {
+		if idx.Status != ActiveStatus {
+			return false
+		}
+	}
+	return true
+}
+
 // DeleteTable is a request to delete a table.
 // See: http://docs.aws.amazon.com/amazondynamodb/latest/APIReference/API_DeleteTable.html
 type DeleteTable struct {
@@ -57,14 +152,54 @@ func (dt *DeleteTable) Run() error {
 }
 
 // RunWithContext executes this request and deletes the table.
+// If the table doesn't exist, the error will match ErrTableNotFound with errors.Is.
 func (dt *DeleteTable) RunWithContext(ctx aws.Context) error {
 	input := dt.input()
-	return retry(ctx, func() error {
-		_, err := dt.table.db.client.DeleteTableWithContext(ctx, input)
+	return dt.table.db.retry(ctx, func() error {
+		start := time.Now()
+		out, err := dt.table.db.client.DeleteTableWithContext(ctx, input)
+		dt.table.db.observe(ctx, "DeleteTable", input, out, err, start)
 		return err
 	})
 }
 
+// Wait executes this request and blocks until the table is finished being deleted.
+func (dt *DeleteTable) Wait() error {
+	ctx, cancel := defaultContext()
+	defer cancel()
+	return dt.WaitWithContext(ctx)
+}
+
+// WaitWithContext executes this request and blocks until the table is finished being deleted.
+func (dt *DeleteTable) WaitWithContext(ctx aws.Context) error {
+	if err := dt.RunWithContext(ctx); err != nil {
+		return err
+	}
+	return dt.table.waitGone(ctx)
+}
+
+// waitGone blocks until this table no longer exists.
+func (table Table) waitGone(ctx aws.Context) error {
+	b := backoff.WithContext(table.db.retryCfg.backoff(), ctx)
+	for {
+		_, err := table.Describe().RunWithContext(ctx)
+		switch {
+		case isResourceNotFound(err):
+			return nil
+		case err != nil:
+			return err
+		}
+
+		next := b.NextBackOff()
+		if next == backoff.Stop {
+			return fmt.Errorf("dynamo: gave up waiting for table %s to be deleted", table.name)
+		}
+		if err := aws.SleepWithContext(ctx, next); err != nil {
+			return err
+		}
+	}
+}
+
 func (dt *DeleteTable) input() *dynamodb.DeleteTableInput {
 	name := dt.table.Name()
 	return &dynamodb.DeleteTableInput{
@@ -73,6 +208,11 @@ func (dt *DeleteTable) input() *dynamodb.DeleteTableInput {
 }
 
 // ConsumedCapacity represents the amount of throughput capacity consumed during an operation.
+// Consumed capacity is only requested from DynamoDB when an operation is given a *ConsumedCapacity
+// with its ConsumedCapacity method; by default, ReturnConsumedCapacity is NONE.
+// When it is requested, the INDEXES level is used, so the table and each index affected are broken down
+// in Table, GSI, and LSI, which shows which index is driving the cost of an operation.
+// To only track totals, such as with Query.Stats, the cheaper TOTAL level is used instead.
 type ConsumedCapacity struct {
 	// Total is the total number of capacity units consumed during this operation.
 	Total float64
@@ -82,7 +222,7 @@ type ConsumedCapacity struct {
 	Write float64
 	// GSI is a map of Global Secondary Index names to consumed capacity units.
 	GSI map[string]float64
-	// GSI is a map of Local Secondary Index names to consumed capacity units.
+	// LSI is a map of Local Secondary Index names to consumed capacity units.
 	LSI map[string]float64
 	// Table is the amount of throughput consumed by the table.
 	Table float64
@@ -108,7 +248,7 @@ func addConsumedCapacity(cc *ConsumedCapacity, raw *dynamodb.ConsumedCapacity) {
 			cc.GSI = make(map[string]float64, len(raw.GlobalSecondaryIndexes))
 		}
 		for name, consumed := range raw.GlobalSecondaryIndexes {
-			cc.GSI[name] = cc.GSI[name] + *consumed.CapacityUnits
+			cc.GSI[name] = cc.GSI[name] + aws.Float64Value(consumed.CapacityUnits)
 		}
 	}
 	if len(raw.LocalSecondaryIndexes) > 0 {
@@ -116,13 +256,123 @@ func addConsumedCapacity(cc *ConsumedCapacity, raw *dynamodb.ConsumedCapacity) {
 			cc.LSI = make(map[string]float64, len(raw.LocalSecondaryIndexes))
 		}
 		for name, consumed := range raw.LocalSecondaryIndexes {
-			cc.LSI[name] = cc.LSI[name] + *consumed.CapacityUnits
+			cc.LSI[name] = cc.LSI[name] + aws.Float64Value(consumed.CapacityUnits)
 		}
 	}
 	if raw.Table != nil {
-		cc.Table += *raw.Table.CapacityUnits
+		cc.Table += aws.Float64Value(raw.Table.CapacityUnits)
 	}
 	if raw.TableName != nil {
 		cc.TableName = *raw.TableName
 	}
 }
+
+func mergeConsumedCapacity(dst, src *ConsumedCapacity) {
+	if dst == nil || src == nil {
+		return
+	}
+	dst.Total += src.Total
+	dst.Read += src.Read
+	dst.Write += src.Write
+	if len(src.GSI) > 0 && dst.GSI == nil {
+		dst.GSI = make(map[string]float64, len(src.GSI))
+	}
+	for name, consumed := range src.GSI {
+		dst.GSI[name] = dst.GSI[name] + consumed
+	}
+	if len(src.LSI) > 0 && dst.LSI == nil {
+		dst.LSI = make(map[string]float64, len(src.LSI))
+	}
+	for name, consumed := range src.LSI {
+		dst.LSI[name] = dst.LSI[name] + consumed
+	}
+	dst.Table += src.Table
+	if src.TableName != "" {
+		dst.TableName = src.TableName
+	}
+}
+
+// ItemCollectionMetrics holds the estimated sizes of the item collections affected by write operations.
+// Only tables with local secondary indexes have item collections, each of which is limited to 10GB.
+type ItemCollectionMetrics struct {
+	// Collections is the latest estimate for each item collection affected.
+	Collections []ItemCollection
+}
+
+// ItemCollection is the estimated size of an item collection:
+// the items that share a partition key value in a table with local secondary indexes.
+type ItemCollection struct {
+	// TableName is the name of the table this collection belongs to.
+	TableName string
+	// Key is the partition key value of the items in this collection.
+	Key map[string]*dynamodb.AttributeValue
+	// SizeEstimateRangeGB is the lower and upper bound of this collection's size in gigabytes.
+	SizeEstimateRangeGB [2]float64
+}
+
+func addItemCollectionMetrics(icm *ItemCollectionMetrics, table string, raw *dynamodb.ItemCollectionMetrics) {
+	if icm == nil || raw == nil {
+		return
+	}
+	coll := ItemCollection{
+		TableName: table,
+		Key:       raw.ItemCollectionKey,
+	}
+	for i, size := range raw.SizeEstimateRangeGB {
+		if i >= len(coll.SizeEstimateRangeGB) {
+			break
+		}
+		coll.SizeEstimateRangeGB[i] = aws.Float64Value(size)
+	}
+	// keep only the newest estimate for each collection
+	for i, prev := range icm.Collections {
+		if prev.TableName == table && reflect.DeepEqual(prev.Key, coll.Key) {
+			icm.Collections[i] = coll
+			return
+		}
+	}
+	icm.Collections = append(icm.Collections, coll)
+}
+
+// QueryStats describes the work done by a query or scan, accumulated across every request it makes.
+type QueryStats struct {
+	// Count is the number of items returned, after filters were applied.
+	Count int64
+	// ScannedCount is the number of items evaluated before filters were applied.
+	// A Count much smaller than ScannedCount means filters are discarding most of the items read.
+	ScannedCount int64
+	// ConsumedCapacity is the total number of capacity units consumed.
+	ConsumedCapacity float64
+}
+
+func (stats *QueryStats) add(count, scanned int64, cc *dynamodb.ConsumedCapacity) {
+	if stats == nil {
+		return
+	}
+	stats.Count += count
+	stats.ScannedCount += scanned
+	if cc != nil {
+		stats.ConsumedCapacity += aws.Float64Value(cc.CapacityUnits)
+	}
+}
+
+func (stats *QueryStats) merge(src *QueryStats) {
+	if stats == nil || src == nil {
+		return
+	}
+	stats.Count += src.Count
+	stats.ScannedCount += src.ScannedCount
+	stats.ConsumedCapacity += src.ConsumedCapacity
+}
+
+// returnConsumedCapacity returns the level of consumed capacity to request:
+// INDEXES for cc, TOTAL if only the total is needed, or nil for neither.
+func returnConsumedCapacity(cc *ConsumedCapacity, total bool) *string {
+	switch {
+	case cc != nil:
+		return aws.String(dynamodb.ReturnConsumedCapacityIndexes)
+	case total:
+		return aws.String(dynamodb.ReturnConsumedCapacityTotal)
+	}
+	return nil
+}
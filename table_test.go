@@ -0,0 +1,193 @@
+package dynamo
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+func TestAddConsumedCapacity(t *testing.T) {
+	raw := &dynamodb.ConsumedCapacity{
+		TableName:          aws.String("TestTable"),
+		CapacityUnits:      aws.Float64(9),
+		ReadCapacityUnits:  aws.Float64(4),
+		WriteCapacityUnits: aws.Float64(5),
+		Table:              &dynamodb.Capacity{CapacityUnits: aws.Float64(6)},
+		GlobalSecondaryIndexes: map[string]*dynamodb.Capacity{
+			"TestGSI": {CapacityUnits: aws.Float64(2)},
+		},
+		LocalSecondaryIndexes: map[string]*dynamodb.Capacity{
+			"TestLSI": {CapacityUnits: aws.Float64(1)},
+			// missing units shouldn't panic
+			"Other": {},
+		},
+	}
+	expected := ConsumedCapacity{
+		TableName: "TestTable",
+		Total:     18,
+		Read:      8,
+		Write:     10,
+		Table:     12,
+		GSI:       map[string]float64{"TestGSI": 4},
+		LSI:       map[string]float64{"TestLSI": 2, "Other": 0},
+	}
+
+	// accumulates across calls, like a paginated query
+	var cc ConsumedCapacity
+	addConsumedCapacity(&cc, raw)
+	addConsumedCapacity(&cc, raw)
+	addConsumedCapacity(&cc, nil)
+	if !reflect.DeepEqual(cc, expected) {
+		t.Errorf("bad consumed capacity. %#v ≠ %#v", cc, expected)
+	}
+}
+
+func TestTableWait(t *testing.T) {
+	client := &mockClient{statuses: []string{"UPDATING", "ACTIVE"}}
+	db := NewFromIface(client)
+	db.SetRetryConfig(RetryConfig{BaseDelay: time.Millisecond, MaxDelay: time.Millisecond})
+	table := db.Table("Test")
+	if err := table.Wait(); err != nil {
+		t.Error("unexpected error:", err)
+	}
+
+	client.statuses = []string{"DELETING"}
+	if err := table.Wait(); err == nil {
+		t.Error("expected error waiting for a deleting table to become active")
+	}
+	if err := table.Wait(DeletingStatus); err != nil {
+		t.Error("unexpected error:", err)
+	}
+}
+
+func TestDeleteTableWait(t *testing.T) {
+	client := &mockClient{statuses: []string{"DELETING", "DELETING", ""}}
+	db := NewFromIface(client)
+	db.SetRetryConfig(RetryConfig{BaseDelay: time.Millisecond, MaxDelay: time.Millisecond})
+	table := db.Table("Test")
+	if err := table.DeleteTable().Wait(); err != nil {
+		t.Error("unexpected error:", err)
+	}
+
+	// now it's gone
+	err := table.DeleteTable().Run()
+	if !errors.Is(err, ErrTableNotFound) {
+		t.Error("expected ErrTableNotFound, got", err)
+	}
+}
+
+func TestTableCountAll(t *testing.T) {
+	client := newMockClient(7)
+	client.statuses = []string{"ACTIVE"}
+	table := NewFromIface(client).Table("Test")
+	count, err := table.CountAll()
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+	if count != 7 {
+		t.Error("bad count:", count)
+	}
+
+	client.statuses = []string{""}
+	if _, err := table.CountAll(); !errors.Is(err, ErrTableNotFound) {
+		t.Error("expected ErrTableNotFound, got", err)
+	}
+}
+
+func TestTableLastKeyOf(t *testing.T) {
+	type post struct {
+		UserID string    `dynamo:",hash" index:"Seq-ID-index,range"`
+		Time   time.Time `dynamo:",range" localIndex:"ID-Seq-index,range"`
+		Seq    int64     `localIndex:"ID-Seq-index,range" index:"Seq-ID-index,hash"`
+		Msg    string
+	}
+	table := Table{name: "Test"}
+	item := post{UserID: "abc", Time: time.Date(2020, 1, 2, 0, 0, 0, 0, time.UTC), Seq: 7, Msg: "hello"}
+
+	key, err := table.LastKeyOf(&item)
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+	want := PagingKey{
+		"UserID": {S: aws.String("abc")},
+		"Time":   {S: aws.String("2020-01-02T00:00:00Z")},
+	}
+	if !reflect.DeepEqual(key, want) {
+		t.Error("bad table key:", key)
+	}
+
+	key, err = table.LastKeyOf(item, "Seq-ID-index")
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+	want["Seq"] = &dynamodb.AttributeValue{N: aws.String("7")}
+	if !reflect.DeepEqual(key, want) {
+		t.Error("bad index key:", key)
+	}
+	if q := table.Get("Seq", 7).Index("Seq-ID-index").StartFrom(key); !reflect.DeepEqual(q.queryInput().ExclusiveStartKey, map[string]*dynamodb.AttributeValue(want)) {
+		t.Error("bad ExclusiveStartKey:", q.queryInput().ExclusiveStartKey)
+	}
+
+	if _, err := table.LastKeyOf(item, "Nope-index"); err == nil {
+		t.Error("expected error for unknown index")
+	}
+	if _, err := table.LastKeyOf(post{Time: item.Time}); err == nil {
+		t.Error("expected error for empty hash key")
+	}
+	if _, err := table.LastKeyOf(map[string]interface{}{"UserID": "abc"}); err == nil {
+		t.Error("expected error for non-struct item")
+	}
+}
+
+func TestTableLastKeyOfEmbedded(t *testing.T) {
+	type base struct {
+		ID  string `dynamo:",hash"`
+		Seq int64  `dynamo:",range" index:"Seq-index,hash"`
+		Msg string `index:"Msg-index,hash"`
+	}
+	type post struct {
+		*base
+		ID  string `dynamo:",hash"` // shadows base.ID
+		Msg string // shadows base.Msg, so it isn't an index key
+	}
+	table := Table{name: "Test"}
+
+	key, err := table.LastKeyOf(post{base: &base{ID: "hidden", Seq: 2}, ID: "abc"})
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+	want := PagingKey{
+		"ID":  {S: aws.String("abc")},
+		"Seq": {N: aws.String("2")},
+	}
+	if !reflect.DeepEqual(key, want) {
+		t.Error("bad key:", key)
+	}
+
+	key, err = table.LastKeyOf(post{base: &base{ID: "hidden", Seq: 2}, ID: "abc"}, "Seq-index")
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+	if !reflect.DeepEqual(key, want) {
+		t.Error("bad index key:", key)
+	}
+	if _, err := table.LastKeyOf(post{base: &base{Msg: "hi"}, ID: "abc", Msg: "hi"}, "Msg-index"); err == nil {
+		t.Error("expected error for shadowed index key")
+	}
+
+	// a nil embedded pointer has no range key to give
+	key, err = table.LastKeyOf(post{ID: "abc"})
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+	if !reflect.DeepEqual(key, PagingKey{"ID": {S: aws.String("abc")}}) {
+		t.Error("bad key with nil embedded struct:", key)
+	}
+	if _, err := table.LastKeyOf(post{ID: "abc"}, "Seq-index"); err == nil {
+		t.Error("expected error for index key in nil embedded struct")
+	}
+}
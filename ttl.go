@@ -1,6 +1,8 @@
 package dynamo
 
 import (
+	"time"
+
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/service/dynamodb"
 )
@@ -39,8 +41,10 @@ func (ttl *UpdateTTL) Run() error {
 func (ttl *UpdateTTL) RunWithContext(ctx aws.Context) error {
 	input := ttl.input()
 
-	err := retry(ctx, func() error {
-		_, err := ttl.table.db.client.UpdateTimeToLiveWithContext(ctx, input)
+	err := ttl.table.db.retry(ctx, func() error {
+		start := time.Now()
+		out, err := ttl.table.db.client.UpdateTimeToLiveWithContext(ctx, input)
+		ttl.table.db.observe(ctx, "UpdateTimeToLive", input, out, err, start)
 		return err
 	})
 	return err
@@ -78,9 +82,11 @@ func (d *DescribeTTL) RunWithContext(ctx aws.Context) (TTLDescription, error) {
 	input := d.input()
 
 	var result *dynamodb.DescribeTimeToLiveOutput
-	err := retry(ctx, func() error {
+	err := d.table.db.retry(ctx, func() error {
 		var err error
+		start := time.Now()
 		result, err = d.table.db.client.DescribeTimeToLiveWithContext(ctx, input)
+		d.table.db.observe(ctx, "DescribeTimeToLive", input, result, err, start)
 		return err
 	})
 	if err != nil {
@@ -90,6 +96,9 @@ func (d *DescribeTTL) RunWithContext(ctx aws.Context) (TTLDescription, error) {
 	desc := TTLDescription{
 		Status: TTLDisabled,
 	}
+	if result.TimeToLiveDescription == nil {
+		return desc, nil
+	}
 	if result.TimeToLiveDescription.TimeToLiveStatus != nil {
 		desc.Status = TTLStatus(*result.TimeToLiveDescription.TimeToLiveStatus)
 	}
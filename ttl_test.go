@@ -21,6 +21,30 @@ func TestDescribeTTL(t *testing.T) {
 	}
 }
 
+func TestTTLOffline(t *testing.T) {
+	table := NewFromIface(newMockClient(0)).Table("Test")
+
+	// no description at all means disabled
+	desc, err := table.DescribeTTL().Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if desc.Enabled() || desc.Status != TTLDisabled || desc.Attribute != "" {
+		t.Error("bad description:", desc)
+	}
+
+	if err := table.UpdateTTL("ExpiresAt", true).Run(); err != nil {
+		t.Fatal(err)
+	}
+	desc, err = table.DescribeTTL().Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !desc.Enabled() || desc.Attribute != "ExpiresAt" {
+		t.Error("bad description:", desc)
+	}
+}
+
 // disable until we have local DB tests
 // (AWS doesn't let us change the TTL often enough to test on a real DB)
 
@@ -1,17 +1,25 @@
 package dynamo
 
 import (
+	"fmt"
+	"time"
+
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
 	"github.com/aws/aws-sdk-go/service/dynamodb"
 	"github.com/gofrs/uuid"
 )
 
+// DynamoDB API limit, 25 operations per transaction
+const maxTxOps = 25
+
 type getTxOp interface {
 	getTxItem() (*dynamodb.TransactGetItem, error)
 }
 
 // GetTx is a transaction to retrieve items.
-// It can contain up to 10 operations and works across multiple tables.
+// It can contain up to 25 operations and works across multiple tables.
 // GetTx is analogous to TransactGetItems in DynamoDB's API.
 // See: https://docs.aws.amazon.com/amazondynamodb/latest/APIReference/API_TransactGetItems.html
 type GetTx struct {
@@ -19,6 +27,7 @@ type GetTx struct {
 	items        []getTxOp
 	unmarshalers map[getTxOp]interface{}
 	cc           *ConsumedCapacity
+	opts         []request.Option
 }
 
 // GetTx begins a new get transaction.
@@ -51,6 +60,13 @@ func (tx *GetTx) ConsumedCapacity(cc *ConsumedCapacity) *GetTx {
 	return tx
 }
 
+// RequestOptions adds options, such as custom request handlers, to every request made by this transaction.
+// They are passed to the AWS SDK's WithContext methods.
+func (tx *GetTx) RequestOptions(opts ...request.Option) *GetTx {
+	tx.opts = append(tx.opts, opts...)
+	return tx
+}
+
 // Run executes this transaction and unmarshals everything specified by GetOne.
 func (tx *GetTx) Run() error {
 	ctx, cancel := defaultContext()
@@ -65,9 +81,11 @@ func (tx *GetTx) RunWithContext(ctx aws.Context) error {
 		return err
 	}
 	var resp *dynamodb.TransactGetItemsOutput
-	err = retry(ctx, func() error {
+	err = tx.db.retry(ctx, func() error {
 		var err error
-		resp, err = tx.db.client.TransactGetItemsWithContext(ctx, input)
+		start := time.Now()
+		resp, err = tx.db.client.TransactGetItemsWithContext(ctx, input, tx.opts...)
+		tx.db.observe(ctx, "TransactGetItems", input, resp, err, start)
 		if tx.cc != nil && resp != nil {
 			for _, cc := range resp.ConsumedCapacity {
 				addConsumedCapacity(tx.cc, cc)
@@ -90,7 +108,7 @@ func (tx *GetTx) unmarshal(resp *dynamodb.TransactGetItemsOutput) error {
 			continue
 		}
 		if target := tx.unmarshalers[tx.items[i]]; target != nil {
-			if err := UnmarshalItem(item.Item, target); err != nil {
+			if err := tx.db.decoder().unmarshalItem(item.Item, target); err != nil {
 				return err
 			}
 		}
@@ -112,9 +130,11 @@ func (tx *GetTx) AllWithContext(ctx aws.Context, out interface{}) error {
 		return err
 	}
 	var resp *dynamodb.TransactGetItemsOutput
-	err = retry(ctx, func() error {
+	err = tx.db.retry(ctx, func() error {
 		var err error
-		resp, err = tx.db.client.TransactGetItems(input)
+		start := time.Now()
+		resp, err = tx.db.client.TransactGetItemsWithContext(ctx, input, tx.opts...)
+		tx.db.observe(ctx, "TransactGetItems", input, resp, err, start)
 		if tx.cc != nil && resp != nil {
 			for _, cc := range resp.ConsumedCapacity {
 				addConsumedCapacity(tx.cc, cc)
@@ -135,7 +155,7 @@ func (tx *GetTx) AllWithContext(ctx aws.Context, out interface{}) error {
 		if item.Item == nil {
 			continue
 		}
-		if err := unmarshalAppend(item.Item, out); err != nil {
+		if err := tx.db.decoder().unmarshalAppend(item.Item, out); err != nil {
 			return err
 		}
 	}
@@ -143,6 +163,9 @@ func (tx *GetTx) AllWithContext(ctx aws.Context, out interface{}) error {
 }
 
 func (tx *GetTx) input() (*dynamodb.TransactGetItemsInput, error) {
+	if len(tx.items) > maxTxOps {
+		return nil, fmt.Errorf("dynamo: get tx: too many operations (%d > %d)", len(tx.items), maxTxOps)
+	}
 	input := &dynamodb.TransactGetItemsInput{}
 	for _, item := range tx.items {
 		tgi, err := item.getTxItem()
@@ -162,7 +185,7 @@ type writeTxOp interface {
 }
 
 // WriteTx is a transaction to delete, put, update, and check items.
-// It can contain up to 10 operations and works across multiple tables.
+// It can contain up to 25 operations and works across multiple tables.
 // Two operations cannot target the same item.
 // WriteTx is analogous to TransactWriteItems in DynamoDB's API.
 // See: https://docs.aws.amazon.com/amazondynamodb/latest/APIReference/API_TransactWriteItems.html
@@ -171,6 +194,7 @@ type WriteTx struct {
 	items []writeTxOp
 	token string
 	cc    *ConsumedCapacity
+	opts  []request.Option
 	err   error
 }
 
@@ -229,6 +253,9 @@ func (tx *WriteTx) Idempotent(enabled bool) *WriteTx {
 // Unless you have special circumstances that require a custom token, consider using Idempotent to generate a token for you.
 // An idempotent transaction ran multiple times will have the same effect as being run once.
 // An idempotent request (token) is only good for 10 minutes, after that it will be considered a new request.
+// The token is sent as the ClientRequestToken of TransactWriteItems, and automatic retries reuse it,
+// so a transaction whose response was lost isn't applied twice. To retry a transaction yourself,
+// such as after a timeout, rebuild it with the same token within the 10 minute window.
 func (tx *WriteTx) IdempotentWithToken(token string) *WriteTx {
 	tx.token = token
 	return tx
@@ -240,7 +267,18 @@ func (tx *WriteTx) ConsumedCapacity(cc *ConsumedCapacity) *WriteTx {
 	return tx
 }
 
+// RequestOptions adds options, such as custom request handlers, to every request made by this transaction.
+// They are passed to the AWS SDK's WithContext methods.
+func (tx *WriteTx) RequestOptions(opts ...request.Option) *WriteTx {
+	tx.opts = append(tx.opts, opts...)
+	return tx
+}
+
 // Run executes this transaction.
+// If the transaction is canceled, the error will be a *dynamodb.TransactionCanceledException.
+// Its CancellationReasons field has one entry per operation, in the order they were added,
+// so a failed condition can be matched to the operation that caused it.
+// See TxCondCheckFailed.
 func (tx *WriteTx) Run() error {
 	ctx, cancel := defaultContext()
 	defer cancel()
@@ -248,6 +286,7 @@ func (tx *WriteTx) Run() error {
 }
 
 // RunWithContext executes this transaction.
+// See Run for details on the errors returned by canceled transactions.
 func (tx *WriteTx) RunWithContext(ctx aws.Context) error {
 	if tx.err != nil {
 		return tx.err
@@ -256,8 +295,10 @@ func (tx *WriteTx) RunWithContext(ctx aws.Context) error {
 	if err != nil {
 		return err
 	}
-	err = retry(ctx, func() error {
-		out, err := tx.db.client.TransactWriteItemsWithContext(ctx, input)
+	err = tx.db.retry(ctx, func() error {
+		start := time.Now()
+		out, err := tx.db.client.TransactWriteItemsWithContext(ctx, input, tx.opts...)
+		tx.db.observe(ctx, "TransactWriteItems", input, out, err, start)
 		if tx.cc != nil && out != nil {
 			for _, cc := range out.ConsumedCapacity {
 				addConsumedCapacity(tx.cc, cc)
@@ -268,7 +309,31 @@ func (tx *WriteTx) RunWithContext(ctx aws.Context) error {
 	return err
 }
 
+// TxCondCheckFailed returns the condition failure of the operation at index op
+// (in the order they were added) of the write transaction that returned err.
+// If the operation used IncludeItemInCondCheckFail, its item is available from the error's Item method.
+// Returns nil if err is not a canceled transaction or that operation's condition did not fail.
+func TxCondCheckFailed(err error, op int) *ConditionFailedError {
+	canceled, ok := err.(*dynamodb.TransactionCanceledException)
+	if !ok || op < 0 || op >= len(canceled.CancellationReasons) {
+		return nil
+	}
+	reason := canceled.CancellationReasons[op]
+	if reason == nil || aws.StringValue(reason.Code) != "ConditionalCheckFailed" {
+		return nil
+	}
+	return &ConditionFailedError{
+		RequestFailure: awserr.NewRequestFailure(
+			awserr.New(dynamodb.ErrCodeConditionalCheckFailedException, aws.StringValue(reason.Message), canceled),
+			canceled.StatusCode(), canceled.RequestID()),
+		item: reason.Item,
+	}
+}
+
 func (tx *WriteTx) input() (*dynamodb.TransactWriteItemsInput, error) {
+	if len(tx.items) > maxTxOps {
+		return nil, fmt.Errorf("dynamo: write tx: too many operations (%d > %d)", len(tx.items), maxTxOps)
+	}
 	input := &dynamodb.TransactWriteItemsInput{}
 	for _, item := range tx.items {
 		wti, err := item.writeTxItem()
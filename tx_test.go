@@ -1,12 +1,16 @@
 package dynamo
 
 import (
+	"errors"
 	"github.com/gofrs/uuid"
 	"reflect"
 	"testing"
 	"time"
 
+	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
 )
 
 func TestTx(t *testing.T) {
@@ -159,8 +163,141 @@ func TestTx(t *testing.T) {
 		if err.(awserr.Error).Code() != "TransactionCanceledException" {
 			t.Error("unexpected error:", err)
 		}
+		if canceled, ok := err.(*dynamodb.TransactionCanceledException); !ok {
+			t.Errorf("expected *dynamodb.TransactionCanceledException, got %T", err)
+		} else if len(canceled.CancellationReasons) != 3 ||
+			aws.StringValue(canceled.CancellationReasons[0].Code) != "ConditionalCheckFailed" ||
+			aws.StringValue(canceled.CancellationReasons[1].Code) != "None" {
+			t.Error("unexpected cancellation reasons:", canceled.CancellationReasons)
+		}
 	}
 
 	t.Logf("1: %+v 2: %+v 3: %+v", record1, record2, record3)
 	t.Logf("All: %+v (len: %d)", records, len(records))
 }
+
+func TestTxTooManyOps(t *testing.T) {
+	table := Table{name: "Test"}
+	getTx := (&DB{}).GetTx()
+	writeTx := (&DB{}).WriteTx()
+	for i := 0; i < maxTxOps; i++ {
+		getTx.Get(table.Get("UserID", i))
+		writeTx.Delete(table.Delete("UserID", i))
+	}
+	if _, err := getTx.input(); err != nil {
+		t.Error("unexpected error:", err)
+	}
+	if _, err := writeTx.input(); err != nil {
+		t.Error("unexpected error:", err)
+	}
+
+	getTx.Get(table.Get("UserID", maxTxOps))
+	writeTx.Delete(table.Delete("UserID", maxTxOps))
+	if _, err := getTx.input(); err == nil {
+		t.Error("expected error for too many get tx operations")
+	}
+	if _, err := writeTx.input(); err == nil {
+		t.Error("expected error for too many write tx operations")
+	}
+}
+
+// flakyTxClient fails the first write transaction with a retryable error,
+// recording the token of every attempt.
+type flakyTxClient struct {
+	*mockClient
+	tokens []string
+}
+
+func (c *flakyTxClient) TransactWriteItemsWithContext(_ aws.Context, in *dynamodb.TransactWriteItemsInput, _ ...request.Option) (*dynamodb.TransactWriteItemsOutput, error) {
+	c.tokens = append(c.tokens, aws.StringValue(in.ClientRequestToken))
+	if len(c.tokens) == 1 {
+		return nil, awserr.NewRequestFailure(awserr.New("InternalServerError", "try again", nil), 500, "")
+	}
+	return &dynamodb.TransactWriteItemsOutput{}, nil
+}
+
+func TestTxIdempotentToken(t *testing.T) {
+	client := &flakyTxClient{mockClient: newMockClient(0)}
+	db := NewFromIface(client)
+	db.SetRetryConfig(RetryConfig{BaseDelay: time.Millisecond})
+	table := db.Table("Test")
+
+	err := db.WriteTx().Delete(table.Delete("UserID", 1)).IdempotentWithToken("transfer-42").Run()
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+	if !reflect.DeepEqual(client.tokens, []string{"transfer-42", "transfer-42"}) {
+		t.Error("retries should reuse the token, got", client.tokens)
+	}
+
+	// a generated token is also kept across retries
+	client.tokens = nil
+	if err := db.WriteTx().Delete(table.Delete("UserID", 1)).Idempotent(true).Run(); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+	if len(client.tokens) != 2 || client.tokens[0] == "" || client.tokens[0] != client.tokens[1] {
+		t.Error("bad generated tokens:", client.tokens)
+	}
+
+	in, err := db.WriteTx().Delete(table.Delete("UserID", 1)).IdempotentWithToken("").input()
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+	if in.ClientRequestToken != nil {
+		t.Error("empty token should disable idempotency, got", *in.ClientRequestToken)
+	}
+}
+
+func TestTxCondCheckFailed(t *testing.T) {
+	table := Table{name: "Test"}
+	tx := (&DB{}).WriteTx()
+	tx.Put(table.Put(widget{UserID: 69}).If("attribute_not_exists(UserID)").IncludeItemInCondCheckFail(true))
+	tx.Update(table.Update("UserID", 70).Set("Msg", "hi"))
+	input, err := tx.input()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rv := aws.StringValue(input.TransactItems[0].Put.ReturnValuesOnConditionCheckFailure); rv != dynamodb.ReturnValuesOnConditionCheckFailureAllOld {
+		t.Error("bad return values on condition check failure:", rv)
+	}
+	if rv := input.TransactItems[1].Update.ReturnValuesOnConditionCheckFailure; rv != nil {
+		t.Error("unexpected return values on condition check failure:", *rv)
+	}
+
+	old := widget{UserID: 69, Msg: "existing"}
+	item, err := marshalItem(old)
+	if err != nil {
+		t.Fatal(err)
+	}
+	canceled := &dynamodb.TransactionCanceledException{
+		CancellationReasons: []*dynamodb.CancellationReason{
+			{Code: aws.String("ConditionalCheckFailed"), Message: aws.String("The conditional request failed"), Item: item},
+			{Code: aws.String("None")},
+		},
+	}
+
+	cfe := TxCondCheckFailed(canceled, 0)
+	if cfe == nil {
+		t.Fatal("expected condition failure")
+	}
+	if !errors.Is(cfe, ErrConditionFailed) {
+		t.Error("expected ErrConditionFailed, got", cfe)
+	}
+	var got widget
+	if err := cfe.Item(&got); err != nil {
+		t.Error("unexpected error:", err)
+	}
+	if !reflect.DeepEqual(got, old) {
+		t.Error("bad item:", got, "≠", old)
+	}
+
+	if cfe := TxCondCheckFailed(canceled, 1); cfe != nil {
+		t.Error("expected nil for successful condition, got", cfe)
+	}
+	if cfe := TxCondCheckFailed(nil, 0); cfe != nil {
+		t.Error("expected nil for nil error, got", cfe)
+	}
+	if err := (&ConditionFailedError{}).Item(&got); err != ErrNotFound {
+		t.Error("expected ErrNotFound, got", err)
+	}
+}
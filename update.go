@@ -3,8 +3,10 @@ package dynamo
 import (
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
 	"github.com/aws/aws-sdk-go/service/dynamodb"
 )
 
@@ -26,15 +28,20 @@ type Update struct {
 	del    map[string]string
 	remove map[string]struct{}
 
-	condition string
+	condition    string
+	condFailItem bool
 
 	subber
 
-	err error
-	cc  *ConsumedCapacity
+	err  error
+	cc   *ConsumedCapacity
+	opts []request.Option
+	icm  *ItemCollectionMetrics
 }
 
 // Update creates a new request to modify an existing item.
+// Unlike Put, the size of the resulting item isn't known until DynamoDB applies the update,
+// so updates that would grow an item past the 400KB limit are rejected by DynamoDB itself.
 func (table Table) Update(hashKey string, value interface{}) *Update {
 	u := &Update{
 		table:   table,
@@ -45,7 +52,7 @@ func (table Table) Update(hashKey string, value interface{}) *Update {
 		del:    make(map[string]string),
 		remove: make(map[string]struct{}),
 	}
-	u.hashValue, u.err = marshal(value, "")
+	u.hashValue, u.err = marshalValue("hash key value", value, "")
 	return u
 }
 
@@ -53,22 +60,24 @@ func (table Table) Update(hashKey string, value interface{}) *Update {
 func (u *Update) Range(name string, value interface{}) *Update {
 	var err error
 	u.rangeKey = name
-	u.rangeValue, err = marshal(value, "")
+	u.rangeValue, err = marshalValue("range key value", value, "")
 	u.setError(err)
 	return u
 }
 
 // Set changes path to the given value.
-// If value is an empty string or nil, path will be removed instead.
+// If value is nil, or an empty string and the DB's empty string mode is EmptyStringOmit, path will be removed instead.
 // Paths that are reserved words are automatically escaped.
 // Use single quotes to escape complex values like 'User'.'Count'.
 func (u *Update) Set(path string, value interface{}) *Update {
-	if isNil(value) {
+	av, err := marshal(value, "")
+	if av = u.table.db.emptyStringMode().value(av); av == nil && err == nil {
 		return u.Remove(path)
 	}
-	path, err := u.escape(path)
 	u.setError(err)
-	expr, err := u.subExpr("🝕 = ?", path, value)
+	path, err = u.escape(path)
+	u.setError(err)
+	expr, err := u.subExpr("🝕 = ?", path, av)
 	u.setError(err)
 	u.set = append(u.set, expr)
 	return u
@@ -224,12 +233,34 @@ func (u *Update) If(expr string, args ...interface{}) *Update {
 	return u
 }
 
+// IncludeItemInCondCheckFail specifies whether the item should be returned if the condition fails.
+// DynamoDB only supports this for updates in a write transaction.
+// Use TxCondCheckFailed to get the item from the transaction's error.
+func (u *Update) IncludeItemInCondCheckFail(enabled bool) *Update {
+	u.condFailItem = enabled
+	return u
+}
+
 // ConsumedCapacity will measure the throughput capacity consumed by this operation and add it to cc.
 func (u *Update) ConsumedCapacity(cc *ConsumedCapacity) *Update {
 	u.cc = cc
 	return u
 }
 
+// RequestOptions adds options, such as custom request handlers, to every request made by this update.
+// They are passed to the AWS SDK's WithContext methods.
+func (u *Update) RequestOptions(opts ...request.Option) *Update {
+	u.opts = append(u.opts, opts...)
+	return u
+}
+
+// ReturnItemCollectionMetrics will record the estimated size of the item collection affected by this operation in icm.
+// This is only returned for tables with local secondary indexes.
+func (u *Update) ReturnItemCollectionMetrics(icm *ItemCollectionMetrics) *Update {
+	u.icm = icm
+	return u
+}
+
 // Run executes this update.
 func (u *Update) Run() error {
 	ctx, cancel := defaultContext()
@@ -237,6 +268,7 @@ func (u *Update) Run() error {
 	return u.RunWithContext(ctx)
 }
 
+// RunWithContext executes this update.
 func (u *Update) RunWithContext(ctx aws.Context) error {
 	u.returnType = "NONE"
 	_, err := u.run(ctx)
@@ -250,28 +282,36 @@ func (u *Update) Value(out interface{}) error {
 	return u.ValueWithContext(ctx, out)
 }
 
+// ValueWithContext executes this update, encoding out with the new value.
 func (u *Update) ValueWithContext(ctx aws.Context, out interface{}) error {
 	u.returnType = "ALL_NEW"
 	output, err := u.run(ctx)
 	if err != nil {
 		return err
 	}
-	return unmarshalItem(output.Attributes, out)
+	return u.table.db.decoder().unmarshalItem(output.Attributes, out)
 }
 
 // OldValue executes this update, encoding out with the previous value.
+// Returns ErrNotFound if there was no previous value.
 func (u *Update) OldValue(out interface{}) error {
 	ctx, cancel := defaultContext()
 	defer cancel()
 	return u.OldValueWithContext(ctx, out)
 }
+
+// OldValueWithContext executes this update, encoding out with the previous value.
+// Returns ErrNotFound if there was no previous value.
 func (u *Update) OldValueWithContext(ctx aws.Context, out interface{}) error {
 	u.returnType = "ALL_OLD"
 	output, err := u.run(ctx)
-	if err != nil {
+	switch {
+	case err != nil:
 		return err
+	case output.Attributes == nil:
+		return ErrNotFound
 	}
-	return unmarshalItem(output.Attributes, out)
+	return u.table.db.decoder().unmarshalItem(output.Attributes, out)
 }
 
 func (u *Update) run(ctx aws.Context) (*dynamodb.UpdateItemOutput, error) {
@@ -281,14 +321,19 @@ func (u *Update) run(ctx aws.Context) (*dynamodb.UpdateItemOutput, error) {
 
 	input := u.updateInput()
 	var output *dynamodb.UpdateItemOutput
-	err := retry(ctx, func() error {
+	err := u.table.db.retry(ctx, func() error {
 		var err error
-		output, err = u.table.db.client.UpdateItemWithContext(ctx, input)
+		start := time.Now()
+		output, err = u.table.db.client.UpdateItemWithContext(ctx, input, u.opts...)
+		u.table.db.observe(ctx, "UpdateItem", input, output, err, start)
 		return err
 	})
-	if u.cc != nil {
+	if u.cc != nil && output != nil {
 		addConsumedCapacity(u.cc, output.ConsumedCapacity)
 	}
+	if u.icm != nil && output != nil {
+		addItemCollectionMetrics(u.icm, u.table.name, output.ItemCollectionMetrics)
+	}
 	return output, err
 }
 
@@ -298,7 +343,7 @@ func (u *Update) updateInput() *dynamodb.UpdateItemInput {
 		Key:                       u.key(),
 		UpdateExpression:          u.updateExpr(),
 		ExpressionAttributeNames:  u.nameExpr,
-		ExpressionAttributeValues: u.valueExpr,
+		ExpressionAttributeValues: u.table.db.emptyStringMode().values(u.valueExpr),
 		ReturnValues:              &u.returnType,
 	}
 	if u.condition != "" {
@@ -307,6 +352,9 @@ func (u *Update) updateInput() *dynamodb.UpdateItemInput {
 	if u.cc != nil {
 		input.ReturnConsumedCapacity = aws.String(dynamodb.ReturnConsumedCapacityIndexes)
 	}
+	if u.icm != nil {
+		input.ReturnItemCollectionMetrics = aws.String(dynamodb.ReturnItemCollectionMetricsSize)
+	}
 	return input
 }
 
@@ -323,9 +371,11 @@ func (u *Update) writeTxItem() (*dynamodb.TransactWriteItem, error) {
 			ExpressionAttributeNames:  input.ExpressionAttributeNames,
 			ExpressionAttributeValues: input.ExpressionAttributeValues,
 			ConditionExpression:       input.ConditionExpression,
-			// TODO: return values
 		},
 	}
+	if u.condFailItem {
+		item.Update.ReturnValuesOnConditionCheckFailure = aws.String(dynamodb.ReturnValuesOnConditionCheckFailureAllOld)
+	}
 	return item, nil
 }
 
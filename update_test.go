@@ -2,8 +2,13 @@ package dynamo
 
 import (
 	"reflect"
+	"strings"
 	"testing"
 	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
 )
 
 func TestUpdate(t *testing.T) {
@@ -119,3 +124,84 @@ func TestUpdateNil(t *testing.T) {
 		t.Errorf("bad result. %+v ≠ %+v", result, expected)
 	}
 }
+
+func TestUpdateEmptyStringMode(t *testing.T) {
+	db := NewFromIface(newMockClient(0))
+	update := func() *Update {
+		return db.Table("Test").Update("UserID", 1).Set("Msg", "")
+	}
+
+	in := update().updateInput()
+	if expr := *in.UpdateExpression; !strings.HasPrefix(expr, "SET ") {
+		t.Error("expected SET by default, got:", expr)
+	}
+
+	db.SetEmptyStringMode(EmptyStringOmit)
+	in = update().updateInput()
+	if expr := *in.UpdateExpression; !strings.HasPrefix(expr, "REMOVE ") {
+		t.Error("expected REMOVE with EmptyStringOmit, got:", expr)
+	}
+
+	db.SetEmptyStringMode(EmptyStringNull)
+	in = update().updateInput()
+	if v := in.ExpressionAttributeValues[":v0"]; v == nil || !aws.BoolValue(v.NULL) {
+		t.Error("expected NULL with EmptyStringNull, got:", in.ExpressionAttributeValues)
+	}
+
+	// values in other expressions can't be omitted, so they become NULL
+	db.SetEmptyStringMode(EmptyStringOmit)
+	in = db.Table("Test").Update("UserID", 1).Append("Tags", []string{""}).SetExpr("Note = ?", "").If("Msg <> ?", "").updateInput()
+	for name, v := range in.ExpressionAttributeValues {
+		if v.L != nil {
+			v = v.L[0]
+		}
+		if !aws.BoolValue(v.NULL) {
+			t.Errorf("expected %s to be NULL, got: %v", name, v)
+		}
+	}
+	if len(in.ExpressionAttributeValues) != 3 {
+		t.Error("expected 3 values, got:", in.ExpressionAttributeValues)
+	}
+}
+
+// oldValueClient responds to UpdateItem with old as the previous item.
+type oldValueClient struct {
+	*mockClient
+	old map[string]*dynamodb.AttributeValue
+	in  *dynamodb.UpdateItemInput
+}
+
+func (c *oldValueClient) UpdateItemWithContext(_ aws.Context, in *dynamodb.UpdateItemInput, _ ...request.Option) (*dynamodb.UpdateItemOutput, error) {
+	c.in = in
+	return &dynamodb.UpdateItemOutput{Attributes: c.old}, nil
+}
+
+func TestUpdateOldValue(t *testing.T) {
+	type item struct {
+		UserID int
+		Msg    string
+	}
+	client := &oldValueClient{mockClient: newMockClient(0)}
+	table := NewFromIface(client).Table("Test")
+
+	// no previous item
+	var old item
+	err := table.Update("UserID", 1).Set("Msg", "hi").OldValue(&old)
+	if err != ErrNotFound {
+		t.Error("expected ErrNotFound, got", err)
+	}
+	if got := aws.StringValue(client.in.ReturnValues); got != dynamodb.ReturnValueAllOld {
+		t.Error("bad return values:", got)
+	}
+
+	client.old = map[string]*dynamodb.AttributeValue{
+		"UserID": {N: aws.String("1")},
+		"Msg":    {S: aws.String("hello")},
+	}
+	if err := table.Update("UserID", 1).Set("Msg", "hi").OldValue(&old); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+	if want := (item{UserID: 1, Msg: "hello"}); old != want {
+		t.Errorf("bad old value. %+v ≠ %+v", old, want)
+	}
+}
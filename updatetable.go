@@ -2,6 +2,7 @@ package dynamo
 
 import (
 	"errors"
+	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/service/dynamodb"
@@ -111,6 +112,7 @@ func (ut *UpdateTable) Run() (Description, error) {
 	return ut.RunWithContext(ctx)
 }
 
+// RunWithContext executes this request and describes the table.
 func (ut *UpdateTable) RunWithContext(ctx aws.Context) (Description, error) {
 	if ut.err != nil {
 		return Description{}, ut.err
@@ -119,9 +121,11 @@ func (ut *UpdateTable) RunWithContext(ctx aws.Context) (Description, error) {
 	input := ut.input()
 
 	var result *dynamodb.UpdateTableOutput
-	err := retry(ctx, func() error {
+	err := ut.table.db.retry(ctx, func() error {
 		var err error
+		start := time.Now()
 		result, err = ut.table.db.client.UpdateTableWithContext(ctx, input)
+		ut.table.db.observe(ctx, "UpdateTable", input, result, err, start)
 		return err
 	})
 	if err != nil {
@@ -131,6 +135,26 @@ func (ut *UpdateTable) RunWithContext(ctx aws.Context) (Description, error) {
 	return newDescription(result.TableDescription), nil
 }
 
+// Wait executes this request and blocks until the table and its indexes are active again,
+// then describes the table.
+func (ut *UpdateTable) Wait() (Description, error) {
+	ctx, cancel := defaultContext()
+	defer cancel()
+	return ut.WaitWithContext(ctx)
+}
+
+// WaitWithContext executes this request and blocks until the table and its indexes are active again,
+// then describes the table.
+func (ut *UpdateTable) WaitWithContext(ctx aws.Context) (Description, error) {
+	if _, err := ut.RunWithContext(ctx); err != nil {
+		return Description{}, err
+	}
+	if err := ut.table.WaitWithContext(ctx); err != nil {
+		return Description{}, err
+	}
+	return ut.table.Describe().RunWithContext(ctx)
+}
+
 func (ut *UpdateTable) input() *dynamodb.UpdateTableInput {
 	input := &dynamodb.UpdateTableInput{
 		TableName:            aws.String(ut.table.Name()),
@@ -2,6 +2,7 @@ package dynamo
 
 import (
 	"testing"
+	"time"
 )
 
 // TODO: enable this test
@@ -39,3 +40,16 @@ func _TestUpdateTable(t *testing.T) {
 		t.Error("bad status:", desc.Status, "≠", UpdatingStatus)
 	}
 }
+
+func TestUpdateTableWait(t *testing.T) {
+	client := &mockClient{statuses: []string{"UPDATING", "ACTIVE"}}
+	db := NewFromIface(client)
+	db.SetRetryConfig(RetryConfig{BaseDelay: time.Millisecond, MaxDelay: time.Millisecond})
+	desc, err := db.Table("Test").UpdateTable().Provision(2, 2).Wait()
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+	if !desc.Active() {
+		t.Error("expected active table, got", desc.Status)
+	}
+}